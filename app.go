@@ -1,19 +1,26 @@
 package main
 
 import (
-	"bytes"
-	"encoding/base64"
+	"context"
 	"encoding/json"
-	"image/png"
+	"errors"
+	"image"
 	"log/slog"
+	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	"handy-translate/config"
+	"handy-translate/explain"
 	"handy-translate/history"
+	"handy-translate/historysync"
+	"handy-translate/ocr"
 	"handy-translate/os_api/windows"
+	"handy-translate/state"
 	"handy-translate/translate_service"
 	"handy-translate/utils"
+	"handy-translate/vocabulary"
 	"handy-translate/window/screenshot"
 	"handy-translate/window/toolbar"
 	"handy-translate/window/translate"
@@ -36,16 +43,28 @@ type AppInterface interface {
 // App is a service
 type App struct{}
 
-var currentToolbarMode = "translate" // 当前工具栏模式：translate/explain
-
-// GetToolbarMode 获取工具栏模式
+// GetToolbarMode 获取工具栏模式：translate/explain
 func GetToolbarMode() string {
-	return currentToolbarMode
+	return state.GlobalStateService.ToolbarMode()
 }
 
-// SetToolbarMode 设置工具栏模式
+// SetToolbarMode 设置工具栏模式，保存后下次启动时恢复
 func SetToolbarMode(mode string) {
-	currentToolbarMode = mode
+	if err := state.GlobalStateService.SetToolbarMode(mode); err != nil {
+		slog.Error("SetToolbarMode", slog.String("mode", mode), slog.Any("err", err))
+	}
+}
+
+// SetCaptureMode 设置截图模式：text 在翻译窗口中翻译识别出的文字，overlay 将译文覆盖在截图上
+func (a *App) SetCaptureMode(mode string) error {
+	return state.GlobalStateService.SetCaptureMode(mode)
+}
+
+// setLanguages 设置源语言和目标语言，保存后下次启动时恢复
+func setLanguages(from, to string) {
+	if err := state.GlobalStateService.SetLanguages(from, to); err != nil {
+		slog.Error("保存语言失败", slog.String("fromLang", from), slog.String("toLang", to), slog.Any("err", err))
+	}
 }
 
 // MyFetch URl
@@ -71,7 +90,7 @@ func (a *App) TranslateMeanings(queryText, fromLang, toLang string) string {
 		slog.Any("toLang", toLang),
 		slog.Any("fromLang", fromLang))
 
-	translateWay := translate_service.GetTranslateWay(config.Data.TranslateWay)
+	translateWay := translate_service.GetTranslateWay(config.Get().TranslateWay)
 
 	// 检查是否支持流式输出
 	if streamTranslate, ok := translateWay.(translate_service.StreamTranslate); ok {
@@ -113,8 +132,8 @@ func (a *App) TranslateMeanings(queryText, fromLang, toLang string) string {
 	translateRes := strings.Join(result, "\n")
 
 	// 保存翻译历史记录
-	if config.Data.History.Enabled {
-		go history.GlobalHistoryService.SaveTranslateRecord(queryText, translateRes, fromLang, toLang)
+	if config.Get().History.Enabled {
+		go history.GlobalHistoryService.SaveTranslateRecord(queryText, translateRes, fromLang, toLang, translateWay.GetName())
 	}
 
 	return translateRes
@@ -127,7 +146,7 @@ func (a *App) TranslateStream(queryText, fromLang, toLang string) {
 		slog.Any("toLang", toLang),
 		slog.Any("fromLang", fromLang))
 
-	translateWay := translate_service.GetTranslateWay(config.Data.TranslateWay)
+	translateWay := translate_service.GetTranslateWay(config.Get().TranslateWay)
 
 	// 检查是否支持流式输出
 	if streamTranslate, ok := translateWay.(translate_service.StreamTranslate); ok {
@@ -156,18 +175,24 @@ func (a *App) TranslateStream(queryText, fromLang, toLang string) {
 
 // ExplainStream 流式解释逻辑（仅支持 DeepSeek，支持模板选择）
 func (a *App) ExplainStream(queryText, templateID string) {
+	a.ExplainStreamWithContext(queryText, "", templateID)
+}
+
+// ExplainStreamWithContext 流式解释，contextText 为选中文本前后的内容，对应模板中的 {{.context}}
+func (a *App) ExplainStreamWithContext(queryText, contextText, templateID string) {
 	app.Logger.Info("ExplainStream",
 		slog.Any("queryText", queryText),
 		slog.Any("templateID", templateID))
 
-	translateWay := translate_service.GetTranslateWay(config.Data.TranslateWay)
+	vars := explainVars(queryText, contextText)
+	translateWay := translate_service.GetTranslateWay(config.Get().TranslateWay)
 
 	// 检查是否支持流式输出
 	if streamTranslate, ok := translateWay.(translate_service.StreamTranslate); ok {
 		// 支持流式输出
 		slog.Info("使用流式解释")
 		var streamResult string
-		err := streamTranslate.PostExplainStream(queryText, templateID, func(chunk string) {
+		err := streamTranslate.PostExplainStream(vars, templateID, func(chunk string) {
 			streamResult += chunk
 			// 每次收到数据块时发送事件到前端
 			slog.Info("发送流式解释数据块", slog.String("chunk", chunk), slog.Int("length", len(chunk)))
@@ -183,25 +208,30 @@ func (a *App) ExplainStream(queryText, templateID string) {
 			app.Event.Emit("result_stream_done", "done")
 
 			// 保存解释历史记录
-			if config.Data.History.Enabled {
-				go history.GlobalHistoryService.SaveExplainRecord(queryText, streamResult, templateID)
+			if config.Get().History.Enabled {
+				go history.GlobalHistoryService.SaveExplainRecord(queryText, streamResult, templateID, translateWay.GetName())
 			}
 		}
 	} else {
 		// 不支持流式输出，使用普通解释
-		res := processExplain(queryText, templateID)
+		res := processExplain(vars, templateID)
 		app.Event.Emit("result", res)
 
 		// 保存解释历史记录
-		if config.Data.History.Enabled {
-			go history.GlobalHistoryService.SaveExplainRecord(queryText, res, templateID)
+		if config.Get().History.Enabled {
+			go history.GlobalHistoryService.SaveExplainRecord(queryText, res, templateID, translateWay.GetName())
 		}
 	}
 }
 
-// GetTranslateMap 获取所有翻译配置
+// GetTranslateMap 获取所有翻译配置，密钥已遮盖
 func (a *App) GetTranslateMap() string {
-	translateList := config.Data.Translate
+	translateList := make(map[string]config.Translate, len(config.Get().Translate))
+	for way, t := range config.Get().Translate {
+		t.AppID = config.MaskSecret(t.AppID)
+		t.Key = config.MaskSecret(t.Key)
+		translateList[way] = t
+	}
 	bTranslate, err := json.Marshal(translateList)
 	if err != nil {
 		logrus.WithError(err).Error("Marshal")
@@ -211,37 +241,63 @@ func (a *App) GetTranslateMap() string {
 
 // SetTranslateWay 设置当前翻译服务
 func (a *App) SetTranslateWay(translateWay string) {
-	config.Data.TranslateWay = translateWay
 	translate_service.SetQueryText("")
-	config.Save()
-	slog.Info("SetTranslateList", slog.Any("config.Data.Translate", config.Data.Translate))
+	err := config.Update(func(c *config.Config) error {
+		c.TranslateWay = translateWay
+		return nil
+	})
+	if err != nil {
+		slog.Error("保存配置失败", slog.Any("err", err))
+	}
+	slog.Info("SetTranslateList", slog.String("translateWay", translateWay))
 }
 
 // GetTranslateWay 获取当前翻译的服务
 func (a *App) GetTranslateWay() string {
-	return config.Data.TranslateWay
+	return config.Get().TranslateWay
+}
+
+// GetAppState 获取后端当前的语言（from_lang、to_lang）、工具栏模式（toolbar_mode）、截图模式（capture_mode）、翻译服务和配置方案，
+// 前端启动时据此恢复界面状态
+func (a *App) GetAppState() string {
+	st := state.GlobalStateService.Get()
+	c := config.Get()
+	return marshalJSON(map[string]interface{}{
+		"from_lang":     st.FromLang,
+		"to_lang":       st.ToLang,
+		"toolbar_mode":  st.ToolbarMode,
+		"capture_mode":  st.CaptureMode,
+		"translate_way": c.TranslateWay,
+		"profile":       c.Profile,
+	})
 }
 
 // GetExplainTemplates 获取所有解释模板
 func (a *App) GetExplainTemplates() string {
 	templates := make(map[string]map[string]interface{})
+	explainTemplates := config.Get().ExplainTemplates
 
 	// 如果配置为空，返回空结果
-	if len(config.Data.ExplainTemplates.Templates) == 0 {
+	if len(explainTemplates.Templates) == 0 {
 		return "{}"
 	}
 
 	// 构建返回数据
-	for id, template := range config.Data.ExplainTemplates.Templates {
+	for id, template := range explainTemplates.Templates {
 		templates[id] = map[string]interface{}{
 			"id":          id,
 			"name":        template.Name,
 			"description": template.Description,
+			"system":      template.System,
+			"template":    template.Template,
+			"examples":    template.Examples,
+			"temperature": template.Temperature,
+			"max_tokens":  template.MaxTokens,
 		}
 	}
 
 	result := map[string]interface{}{
-		"default_template": config.Data.ExplainTemplates.DefaultTemplate,
+		"default_template": explainTemplates.DefaultTemplate,
 		"templates":        templates,
 	}
 
@@ -255,11 +311,338 @@ func (a *App) GetExplainTemplates() string {
 
 // SetDefaultExplainTemplate 设置默认解释模板
 func (a *App) SetDefaultExplainTemplate(templateID string) {
-	config.Data.ExplainTemplates.DefaultTemplate = templateID
-	config.Save()
+	err := config.Update(func(c *config.Config) error {
+		c.ExplainTemplates.DefaultTemplate = templateID
+		return nil
+	})
+	if err != nil {
+		slog.Error("保存配置失败", slog.Any("err", err))
+	}
 	slog.Info("SetDefaultExplainTemplate", slog.String("templateID", templateID))
 }
 
+// CreateExplainTemplate 新建解释模板，id 只能包含字母、数字、下划线和连字符
+func (a *App) CreateExplainTemplate(id string, template explain.Template) error {
+	err := config.AddTemplate(id, template)
+	if err != nil {
+		slog.Error("CreateExplainTemplate", slog.String("id", id), slog.Any("err", err))
+	}
+	return err
+}
+
+// UpdateExplainTemplate 修改解释模板
+func (a *App) UpdateExplainTemplate(id string, template explain.Template) error {
+	err := config.UpdateTemplate(id, template)
+	if err != nil {
+		slog.Error("UpdateExplainTemplate", slog.String("id", id), slog.Any("err", err))
+	}
+	return err
+}
+
+// DeleteExplainTemplate 删除解释模板，不能删除默认模板
+func (a *App) DeleteExplainTemplate(id string) error {
+	err := config.DeleteTemplate(id)
+	if err != nil {
+		slog.Error("DeleteExplainTemplate", slog.String("id", id), slog.Any("err", err))
+	}
+	return err
+}
+
+// DuplicateExplainTemplate 复制解释模板，newID 为空时自动生成，返回新模板的 ID
+func (a *App) DuplicateExplainTemplate(id, newID string) (string, error) {
+	newID, err := config.DuplicateTemplate(id, newID)
+	if err != nil {
+		slog.Error("DuplicateExplainTemplate", slog.String("id", id), slog.Any("err", err))
+	}
+	return newID, err
+}
+
+// PreviewExplainTemplate 使用示例变量渲染模板，返回将要发送给模型的对话消息（role、content），不会请求模型。
+// sampleText 为空时使用 explain.SampleText
+func (a *App) PreviewExplainTemplate(template explain.Template, sampleText string) (string, error) {
+	messages, err := template.Preview(explain.SampleVars(sampleText))
+	if err != nil {
+		return "", err
+	}
+	return marshalJSON(messages), nil
+}
+
+// ImportExplainTemplates 从 TOML 或 JSON 模板包导入解释模板，返回导入后的模板 ID。
+// 模板 ID 已存在时，overwrite 为 true 则覆盖，否则以新的 ID 导入
+func (a *App) ImportExplainTemplates(path string, overwrite bool) (string, error) {
+	pack, err := explain.ReadPack(path)
+	if err != nil {
+		slog.Error("ImportExplainTemplates", slog.String("path", path), slog.Any("err", err))
+		return "", err
+	}
+	ids, err := config.ImportTemplates(pack, overwrite)
+	if err != nil {
+		slog.Error("ImportExplainTemplates", slog.String("path", path), slog.Any("err", err))
+		return "", err
+	}
+	slog.Info("ImportExplainTemplates", slog.String("path", path), slog.Any("ids", ids))
+	return marshalJSON(ids), nil
+}
+
+// ExportExplainTemplates 将解释模板导出为模板包，按扩展名（.toml 或 .json）选择格式，ids 为空时导出全部模板
+func (a *App) ExportExplainTemplates(path string, ids []string) error {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	pack, err := config.ExportTemplates(name, ids)
+	if err == nil {
+		err = explain.WritePack(path, pack)
+	}
+	if err != nil {
+		slog.Error("ExportExplainTemplates", slog.String("path", path), slog.Any("err", err))
+	}
+	return err
+}
+
+// GetProfiles 获取配置方案，返回当前方案 ID（current）和按 ID 排序的方案列表（profiles）
+func (a *App) GetProfiles() string {
+	c := config.Get()
+	profiles := make([]map[string]interface{}, 0, len(c.Profiles))
+	for _, id := range c.ProfileIDs() {
+		p := c.Profiles[id]
+		profiles = append(profiles, map[string]interface{}{
+			"id":            id,
+			"name":          p.Name,
+			"translate_way": p.TranslateWay,
+			"from":          p.From,
+			"to":            p.To,
+			"mode":          p.Mode,
+			"template":      p.Template,
+		})
+	}
+	return marshalJSON(map[string]interface{}{
+		"current":  c.Profile,
+		"profiles": profiles,
+	})
+}
+
+// GetOCRModelSets 获取模型目录中的 OCR 模型组及当前选择的模型组
+func (a *App) GetOCRModelSets() (string, error) {
+	sets, err := ocr.ModelSets()
+	if err != nil {
+		return "", err
+	}
+	return marshalJSON(map[string]interface{}{
+		"current": config.Get().OCR.ModelSet,
+		"sets":    sets,
+	}), nil
+}
+
+// SwitchProfile 切换配置方案，切换后发送 profile_changed 事件
+func (a *App) SwitchProfile(id string) error {
+	return switchProfile(id)
+}
+
+// AddVocabulary 收藏单词到单词本，返回单词条目
+func (a *App) AddVocabulary(word, translation, fromLang, toLang string) (string, error) {
+	entry, err := vocabulary.GlobalVocabularyService.Add(word, translation, fromLang, toLang, "toolbar")
+	if err != nil {
+		slog.Error("AddVocabulary", slog.Any("err", err))
+		return "", err
+	}
+	return marshalJSON(entry), nil
+}
+
+// RemoveVocabulary 从单词本删除单词
+func (a *App) RemoveVocabulary(id string) error {
+	return vocabulary.GlobalVocabularyService.Remove(id)
+}
+
+// GetVocabulary 获取单词本全部单词
+func (a *App) GetVocabulary() (string, error) {
+	entries, err := vocabulary.GlobalVocabularyService.List()
+	if err != nil {
+		return "", err
+	}
+	return marshalJSON(entries), nil
+}
+
+// GetDueVocabulary 获取今天需要复习的单词
+func (a *App) GetDueVocabulary() (string, error) {
+	entries, err := vocabulary.GlobalVocabularyService.Due(time.Now())
+	if err != nil {
+		return "", err
+	}
+	return marshalJSON(entries), nil
+}
+
+// ReviewVocabulary 提交复习评分（0~5），返回更新后的单词条目
+func (a *App) ReviewVocabulary(id string, grade int) (string, error) {
+	entry, err := vocabulary.GlobalVocabularyService.Review(id, grade, time.Now())
+	if err != nil {
+		slog.Error("ReviewVocabulary", slog.Any("err", err))
+		return "", err
+	}
+	return marshalJSON(entry), nil
+}
+
+// ImportHistoryToVocabulary 将翻译历史记录导入单词本，返回导入数量；历史记录加密存储时拒绝导入
+func (a *App) ImportHistoryToVocabulary() (int, error) {
+	if history.GlobalHistoryService.Encrypted() {
+		return 0, vocabulary.ErrEncryptedHistory
+	}
+	records, err := history.GlobalHistoryService.LoadRecords("translate")
+	if err != nil {
+		return 0, err
+	}
+	return vocabulary.GlobalVocabularyService.ImportHistory(records)
+}
+
+// GetHistoryStats 获取历史记录统计，topN 为高频词数量，查询次数超过 candidateThreshold 的词语标记为单词本候选
+func (a *App) GetHistoryStats(topN, candidateThreshold int) (string, error) {
+	stats, err := history.GlobalHistoryService.Stats(topN, candidateThreshold)
+	if err != nil {
+		slog.Error("GetHistoryStats", slog.Any("err", err))
+		return "", err
+	}
+	return marshalJSON(stats), nil
+}
+
+// DedupHistory 整理已有历史记录文件，合并重复记录，返回被合并掉的记录数
+func (a *App) DedupHistory() (int, error) {
+	removed, err := history.GlobalHistoryService.Dedup()
+	if err != nil {
+		slog.Error("DedupHistory", slog.Any("err", err))
+		return removed, err
+	}
+	slog.Info("DedupHistory", slog.Int("removed", removed))
+	return removed, nil
+}
+
+// UnlockHistory 使用口令解锁加密的历史记录，每次启动后调用一次
+func (a *App) UnlockHistory(passphrase string) error {
+	if err := history.GlobalHistoryService.Unlock(passphrase); err != nil {
+		slog.Error("UnlockHistory", slog.Any("err", err))
+		return err
+	}
+	slog.Info("UnlockHistory 历史记录已解锁")
+	return nil
+}
+
+// IsHistoryLocked 历史记录是否加密且尚未解锁
+func (a *App) IsHistoryLocked() bool {
+	return history.GlobalHistoryService.Locked()
+}
+
+// SyncHistory 立即与远端同步历史记录，返回同步结果
+func (a *App) SyncHistory() (string, error) {
+	syncer := historysync.Global()
+	if syncer == nil {
+		return "", errors.New("未开启历史记录同步，请在 config.toml 中配置 [sync]")
+	}
+
+	result, err := syncer.Sync(context.Background())
+	if err != nil {
+		slog.Error("SyncHistory", slog.Any("err", err))
+		return "", err
+	}
+	return marshalJSON(result), nil
+}
+
+// AdoptHistorySyncKey 以口令改用远端历史记录的密钥，同步返回密钥不一致的错误时调用
+func (a *App) AdoptHistorySyncKey(passphrase string) error {
+	syncer := historysync.Global()
+	if syncer == nil {
+		return errors.New("未开启历史记录同步，请在 config.toml 中配置 [sync]")
+	}
+
+	if err := syncer.AdoptRemoteKey(context.Background(), passphrase); err != nil {
+		slog.Error("AdoptHistorySyncKey", slog.Any("err", err))
+		return err
+	}
+	slog.Info("AdoptHistorySyncKey 已改用远端的密钥")
+	return nil
+}
+
+// GetHistorySyncStatus 获取同步状态：是否开启和上次同步时间
+func (a *App) GetHistorySyncStatus() string {
+	syncer := historysync.Global()
+	status := map[string]interface{}{
+		"enabled": syncer != nil,
+	}
+	if syncer != nil {
+		if last, err := syncer.LastSync(); err == nil && !last.IsZero() {
+			status["last_sync"] = last
+		}
+	}
+	return marshalJSON(status)
+}
+
+// GetSupportedProviders 获取支持的翻译服务，用于添加翻译服务
+func (a *App) GetSupportedProviders() string {
+	return marshalJSON(config.Providers())
+}
+
+// AddProvider 添加翻译服务
+func (a *App) AddProvider(way, name, appID, key string) error {
+	err := config.AddProvider(way, config.Translate{Name: name, AppID: appID, Key: key})
+	if err != nil {
+		slog.Error("AddProvider", slog.String("way", way), slog.Any("err", err))
+	}
+	return err
+}
+
+// UpdateProvider 修改翻译服务，appID、key 传入 GetTranslateMap 返回的遮盖值时保持不变
+func (a *App) UpdateProvider(way, name, appID, key string) error {
+	err := config.UpdateProvider(way, config.Translate{Name: name, AppID: appID, Key: key})
+	if err != nil {
+		slog.Error("UpdateProvider", slog.String("way", way), slog.Any("err", err))
+	}
+	return err
+}
+
+// DeleteProvider 删除翻译服务
+func (a *App) DeleteProvider(way string) error {
+	err := config.DeleteProvider(way)
+	if err != nil {
+		slog.Error("DeleteProvider", slog.String("way", way), slog.Any("err", err))
+	}
+	return err
+}
+
+// TestProvider 使用当前配置翻译一段固定文本，返回结果和耗时（latency_ms）；
+// 失败时错误的 cause 中包含 kind（not_configured、auth、network、timeout 等）和 message
+func (a *App) TestProvider(way string) (string, error) {
+	result, err := translate_service.Probe(context.Background(), way)
+	if err != nil {
+		slog.Error("TestProvider", slog.String("way", way), slog.Any("err", err))
+		return "", err
+	}
+	return marshalJSON(result), nil
+}
+
+// SetProviderSecret 设置翻译服务的密钥，field 为 "appID" 或 "key"。
+// 密钥保存在系统密钥环（不可用时为配置目录下的 secrets 目录），配置文件中只记录引用
+func (a *App) SetProviderSecret(way, field, value string) error {
+	if err := config.SetSecret(way, field, value); err != nil {
+		slog.Error("SetProviderSecret", slog.String("way", way), slog.String("field", field), slog.Any("err", err))
+		return err
+	}
+	return nil
+}
+
+// GetConfigDiagnostics 校验当前配置，返回问题列表（level、field、message），没有问题时返回 []
+func (a *App) GetConfigDiagnostics() string {
+	diags := config.Diagnostics()
+	if diags == nil {
+		diags = []config.Diagnostic{}
+	}
+	return marshalJSON(diags)
+}
+
+// marshalJSON 序列化为 JSON 字符串返回给前端
+func marshalJSON(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		logrus.WithError(err).Error("Marshal")
+		return ""
+	}
+	return string(b)
+}
+
 // Show 通过名字控制窗口事件
 func (a *App) Show(windowName string) {
 	var win *application.WebviewWindow
@@ -389,42 +772,94 @@ func processToolbarShow() {
 	slog.Info("工具栏已显示并标记")
 }
 
+// cursorPoint 鼠标的物理坐标，用于选择截图的显示器
+func cursorPoint() image.Point {
+	pos := windows.GetCursorPos()
+	return image.Pt(int(pos.X), int(pos.Y))
+}
+
 // ResetToolbarState 重置工具栏状态（在窗口隐藏时调用）
 func ResetToolbarState() {
 	toolbarIsShowing = false
 	slog.Info("工具栏状态已重置")
 }
 
-// CaptureSelectedScreen 截取选中的区域
-func (a *App) CaptureSelectedScreen(startX, startY, width, height float64) {
-	croppedImg := screenshot.CaptureSelectedScreen(int(startX), int(startY), int(width), int(height))
-	if croppedImg == nil {
+// screenshotPayload screenshotBase64 事件的数据
+type screenshotPayload struct {
+	SessionID string  `json:"session_id"`
+	Image     string  `json:"image"` // Base64 编码的 PNG
+	Scale     float64 `json:"scale"` // 物理像素 / CSS 像素
+}
+
+// showScreenshotError 截图窗口隐藏时的错误（截图失败、文字模式下识别失败）无法在截图窗口中显示，
+// 显示在翻译窗口中
+func showScreenshotError(msg string) {
+	app.Event.Emit("result_stream_error", msg)
+	translate.Window.Show()
+	translate.Window.Focus()
+}
+
+// startScreenshot 截图并创建截图会话，通过 screenshotBase64 事件将截图发给截图窗口，失败时在翻译窗口中显示错误
+func startScreenshot() {
+	session, err := screenshot.NewSession(cursorPoint())
+	if err != nil {
+		slog.Error("截图失败", slog.Any("err", err))
+		showScreenshotError("截图失败: " + err.Error())
 		return
 	}
-
-	var buf bytes.Buffer
-	err := png.Encode(&buf, croppedImg)
+	encoded, err := screenshot.EncodeBase64(session.Capture.Image)
 	if err != nil {
-		slog.Error("png.Encode", slog.Any("err", err))
+		slog.Error("截图编码失败", slog.Any("err", err))
+		screenshot.Sessions.Delete(session.ID)
+		showScreenshotError("截图编码失败: " + err.Error())
 		return
 	}
+	app.Event.Emit("screenshotBase64", screenshotPayload{SessionID: session.ID, Image: encoded, Scale: session.Capture.Scale})
+}
 
-	filename := "screenshot.png" // 保存的文件名
-	base64String := base64.StdEncoding.EncodeToString(buf.Bytes())
+// CloseScreenshotSession 结束截图会话并释放截图，截图窗口关闭时调用
+func (a *App) CloseScreenshotSession(sessionID string) {
+	screenshot.Sessions.Delete(sessionID)
+}
 
-	err = saveBase64Image(base64String, filename)
+// CaptureSelectedScreen 裁剪截图会话中选中的区域并识别文字，选区为截图窗口中两个角的 CSS 像素坐标，
+// 会话不存在、已过期、选区为空或识别失败时返回错误。截图模式为 overlay 时截图窗口保持显示，返回各文字块的截图坐标和原文（JSON），
+// 之后按批翻译，通过 overlay_result 事件发送译文；为 text 时截图窗口已隐藏，返回空，识别出的文字合并后在工具栏中翻译，
+// 错误同时显示在翻译窗口中
+func (a *App) CaptureSelectedScreen(sessionID string, startX, startY, endX, endY float64) (string, error) {
+	mode := state.GlobalStateService.CaptureMode()
+	_, croppedImg, err := cropSession(sessionID, startX, startY, endX, endY)
 	if err != nil {
-		slog.Error("saveBase64Image", slog.Any("err", err))
+		if mode != state.CaptureOverlay {
+			showScreenshotError("截图翻译失败: " + err.Error())
+		}
+		return "", err
 	}
 
-	// OCR解析文本
-	queryText := ExecOCR(".\\RapidOCR-json.exe", filename)
+	// OCR解析文本，ocr.model_set 为 auto 时按源语言选择模型组
+	fromLang, _ := state.GlobalStateService.Languages()
+	result, err := ocr.Recognize(ocr.WithLanguage(context.Background(), fromLang), croppedImg)
+	if err != nil {
+		slog.Error("OCR 识别失败", slog.Any("err", err))
+		if mode != state.CaptureOverlay {
+			showScreenshotError("OCR 识别失败: " + err.Error())
+		}
+		return "", err
+	}
+
+	if mode == state.CaptureOverlay {
+		blocks := overlayBlocks(result, config.Get().OCR.MinScore, croppedImg.Bounds().Min)
+		response := marshalJSON(blocks)
+		go translateOverlay(sessionID, blocks)
+		return response, nil
+	}
+	queryText := result.LayoutText(config.Get().OCR.MinScore)
 
 	// 重置工具栏状态，准备新的翻译
 	ResetToolbarState()
 
 	// 检查是否使用了流式翻译
-	translateWay := translate_service.GetTranslateWay(config.Data.TranslateWay)
+	translateWay := translate_service.GetTranslateWay(config.Get().TranslateWay)
 
 	// 无论是流式还是普通翻译，都先发送 query 事件让前端准备
 	sendQueryText(queryText)
@@ -438,11 +873,13 @@ func (a *App) CaptureSelectedScreen(startX, startY, width, height float64) {
 		translateRes := processTranslate(queryText)
 		sendResult(translateRes, "")
 	}
+	return "", nil
 }
 
 // 翻译处理
 func processTranslate(queryText string) string {
-	translateWay := translate_service.GetTranslateWay(config.Data.TranslateWay)
+	fromLang, toLang := state.GlobalStateService.Languages()
+	translateWay := translate_service.GetTranslateWay(config.Get().TranslateWay)
 
 	// 检查是否支持流式输出
 	if streamTranslate, ok := translateWay.(translate_service.StreamTranslate); ok {
@@ -469,8 +906,8 @@ func processTranslate(queryText string) string {
 			slog.String("translateWay", translateWay.GetName()))
 
 		// 保存翻译历史记录
-		if config.Data.History.Enabled {
-			go history.GlobalHistoryService.SaveTranslateRecord(queryText, streamResult, fromLang, toLang)
+		if config.Get().History.Enabled {
+			go history.GlobalHistoryService.SaveTranslateRecord(queryText, streamResult, fromLang, toLang, translateWay.GetName())
 		}
 
 		return streamResult
@@ -489,23 +926,36 @@ func processTranslate(queryText string) string {
 	translateRes := strings.Join(result, "\n")
 
 	// 保存翻译历史记录
-	if config.Data.History.Enabled {
-		go history.GlobalHistoryService.SaveTranslateRecord(queryText, translateRes, fromLang, toLang)
+	if config.Get().History.Enabled {
+		go history.GlobalHistoryService.SaveTranslateRecord(queryText, translateRes, fromLang, toLang, translateWay.GetName())
 	}
 
 	return translateRes
 }
 
+// explainVars 解释模板变量：语言为当前选择的语言，应用为选中文本时的前台窗口
+func explainVars(queryText, contextText string) explain.Vars {
+	fromLang, toLang := state.GlobalStateService.Languages()
+	return explain.Vars{
+		Text:    queryText,
+		Context: contextText,
+		From:    fromLang,
+		To:      toLang,
+		App:     translate_service.GetQueryApp(),
+	}
+}
+
 // 解释处理（支持模板选择）
-func processExplain(queryText, templateID string) string {
-	translateWay := translate_service.GetTranslateWay(config.Data.TranslateWay)
+func processExplain(vars explain.Vars, templateID string) string {
+	queryText := vars.Text
+	translateWay := translate_service.GetTranslateWay(config.Get().TranslateWay)
 
 	// 检查是否支持流式输出
 	if streamTranslate, ok := translateWay.(translate_service.StreamTranslate); ok {
 		// 支持流式输出
 		slog.Info("使用流式解释")
 		var streamResult string
-		err := streamTranslate.PostExplainStream(queryText, templateID, func(chunk string) {
+		err := streamTranslate.PostExplainStream(vars, templateID, func(chunk string) {
 			streamResult += chunk
 			// 每次收到数据块时发送事件到前端
 			slog.Info("发送流式解释数据块", slog.String("chunk", chunk), slog.Int("length", len(chunk)))
@@ -525,8 +975,8 @@ func processExplain(queryText, templateID string) string {
 			slog.String("translateWay", translateWay.GetName()))
 
 		// 保存解释历史记录
-		if config.Data.History.Enabled {
-			go history.GlobalHistoryService.SaveExplainRecord(queryText, streamResult, templateID)
+		if config.Get().History.Enabled {
+			go history.GlobalHistoryService.SaveExplainRecord(queryText, streamResult, templateID, translateWay.GetName())
 		}
 
 		return streamResult
@@ -562,6 +1012,8 @@ func processHook() {
 			}
 
 			queryText := result
+			translate_service.SetQueryApp(windows.GetForegroundWindowTitle())
+			fromLang, toLang := state.GlobalStateService.Languages()
 
 			app.Logger.Info("processHook GetQueryText",
 				slog.String("queryText", queryText),
@@ -578,7 +1030,7 @@ func processHook() {
 				translate_service.SetQueryText(queryText)
 
 				// 检查是否使用了流式翻译
-				translateWay := translate_service.GetTranslateWay(config.Data.TranslateWay)
+				translateWay := translate_service.GetTranslateWay(config.Get().TranslateWay)
 
 				// 无论是流式还是普通翻译，都先发送 query 事件让前端准备
 				sendQueryText(queryText)
@@ -603,9 +1055,7 @@ func processHook() {
 				}
 			}
 		case "screenshot":
-			screenshot.ScreenshotFullScreen()
-			base64Image := screenshot.ScreenshotFullScreen()
-			app.Event.Emit("screenshotBase64", base64Image)
+			startScreenshot()
 		default:
 			app.Logger.Error("processHook", slog.String("msg", msg))
 		}
@@ -0,0 +1,102 @@
+// Package capture 截图会话：每次截图创建一个会话并持有这次截图，之后对选区的操作（识别、复制、保存等）
+// 都通过会话 ID 引用同一张截图，会话过期后释放截图
+package capture
+
+import (
+	"errors"
+	"image"
+	"sync"
+	"time"
+
+	"handy-translate/display"
+
+	"github.com/google/uuid"
+)
+
+// DefaultTTL 截图会话的有效期
+const DefaultTTL = 5 * time.Minute
+
+var (
+	// ErrSessionNotFound 截图会话不存在或已过期
+	ErrSessionNotFound = errors.New("capture: session not found or expired")
+	// ErrEmptySelection 选区为空或不在截图范围内
+	ErrEmptySelection = errors.New("capture: empty selection")
+)
+
+// Capture 一次截图，Image 的原点为 (0, 0)，Transform 用于换算截图窗口中的选区
+type Capture struct {
+	Image *image.RGBA
+	display.Transform
+}
+
+// Session 一次截图会话
+type Session struct {
+	ID      string
+	Capture *Capture
+	Created time.Time
+}
+
+// Crop 裁剪截图窗口中选中的区域，坐标为截图窗口中的 CSS 像素，返回的图片范围为截图中的像素坐标
+func (s *Session) Crop(x0, y0, x1, y1 float64) (image.Image, error) {
+	rect := s.Capture.FromCSS(x0, y0, x1, y1)
+	if rect.Empty() {
+		return nil, ErrEmptySelection
+	}
+	return s.Capture.Image.SubImage(rect), nil
+}
+
+// Store 保存截图会话，会话在 ttl 后自动删除
+type Store struct {
+	ttl      time.Duration
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+// NewStore 创建会话存储
+func NewStore(ttl time.Duration) *Store {
+	return &Store{ttl: ttl, sessions: map[string]*Session{}}
+}
+
+// Add 为截图创建会话
+func (s *Store) Add(c *Capture) *Session {
+	session := &Session{ID: uuid.NewString(), Capture: c, Created: time.Now()}
+
+	s.mu.Lock()
+	s.sessions[session.ID] = session
+	s.mu.Unlock()
+
+	time.AfterFunc(s.ttl, func() { s.Delete(session.ID) })
+	return session
+}
+
+// Get 获取会话，不存在或已过期时返回 ErrSessionNotFound
+func (s *Store) Get(id string) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[id]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	return session, nil
+}
+
+// Delete 结束会话并释放截图
+func (s *Store) Delete(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+}
+
+// Clear 结束所有会话并释放截图
+func (s *Store) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.sessions)
+}
+
+// Len 当前的会话数
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
@@ -0,0 +1,123 @@
+package capture
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"handy-translate/display"
+)
+
+func newCapture() *Capture {
+	return &Capture{
+		Image:     image.NewRGBA(image.Rect(0, 0, 200, 100)),
+		Transform: display.Transform{Origin: image.Pt(-200, 0), Size: image.Pt(200, 100), Scale: 2},
+	}
+}
+
+func TestStore(t *testing.T) {
+	s := NewStore(50 * time.Millisecond)
+	a, b := s.Add(newCapture()), s.Add(newCapture())
+	if a.ID == "" || a.ID == b.ID {
+		t.Fatalf("会话 ID 应唯一: %q %q", a.ID, b.ID)
+	}
+	if got, err := s.Get(a.ID); err != nil || got != a {
+		t.Errorf("Get() = %v, %v", got, err)
+	}
+
+	s.Delete(a.ID)
+	if _, err := s.Get(a.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("结束的会话应返回 ErrSessionNotFound，实际 %v", err)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	if _, err := s.Get(b.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("过期的会话应返回 ErrSessionNotFound，实际 %v", err)
+	}
+	if s.Len() != 0 {
+		t.Errorf("过期的会话应释放，实际还有 %d 个", s.Len())
+	}
+
+	s.Add(newCapture())
+	s.Add(newCapture())
+	if s.Clear(); s.Len() != 0 {
+		t.Errorf("Clear() 后应释放所有会话，实际还有 %d 个", s.Len())
+	}
+}
+
+func TestCrop(t *testing.T) {
+	session := NewStore(time.Minute).Add(newCapture())
+
+	img, err := session.Crop(30, 20, 10, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := img.Bounds(); got != image.Rect(20, 10, 60, 40) {
+		t.Errorf("裁剪范围 = %v，应为截图中的像素坐标", got)
+	}
+	if _, err := session.Crop(10, 10, 10, 30); !errors.Is(err, ErrEmptySelection) {
+		t.Errorf("空选区应返回 ErrEmptySelection，实际 %v", err)
+	}
+	if _, err := session.Crop(200, 100, 300, 200); !errors.Is(err, ErrEmptySelection) {
+		t.Errorf("截图范围外的选区应返回 ErrEmptySelection，实际 %v", err)
+	}
+}
+
+func TestSave(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "shots")
+	now := time.Date(2024, 5, 6, 7, 8, 9, 0, time.Local)
+	img := image.NewRGBA(image.Rect(10, 10, 30, 20))
+
+	first, err := Save(img, dir, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Save(img, dir, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(first) != "handy-translate-20240506-070809.png" || filepath.Base(second) != "handy-translate-20240506-070809-1.png" {
+		t.Errorf("文件名 = %s, %s", filepath.Base(first), filepath.Base(second))
+	}
+
+	f, err := os.Open(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	saved, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Bounds().Dx() != 20 || saved.Bounds().Dy() != 10 {
+		t.Errorf("保存的图片大小 = %v", saved.Bounds())
+	}
+}
+
+func TestEncodeDIB(t *testing.T) {
+	img := image.NewRGBA(image.Rect(5, 5, 7, 7))
+	img.Set(5, 5, color.RGBA{R: 1, G: 2, B: 3, A: 255}) // 左上角
+	img.Set(6, 6, color.RGBA{R: 4, G: 5, B: 6, A: 255}) // 右下角
+
+	data := EncodeDIB(img)
+	if len(data) != 40+2*2*4 {
+		t.Fatalf("DIB 长度 = %d", len(data))
+	}
+	if w, h, bits := binary.LittleEndian.Uint32(data[4:]), binary.LittleEndian.Uint32(data[8:]), binary.LittleEndian.Uint16(data[14:]); w != 2 || h != 2 || bits != 32 {
+		t.Errorf("DIB 头: %dx%d %d 位", w, h, bits)
+	}
+	// 自下而上：第一行为图片的最后一行
+	pix := data[40:]
+	if got := pix[4:8]; got[0] != 6 || got[1] != 5 || got[2] != 4 {
+		t.Errorf("右下角像素 = %v，应为 BGRA", got)
+	}
+	if got := pix[8:12]; got[0] != 3 || got[1] != 2 || got[2] != 1 {
+		t.Errorf("左上角像素 = %v，应为 BGRA", got)
+	}
+}
@@ -0,0 +1,69 @@
+package capture
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Save 将截图保存为 dir 中以时间命名的 PNG 文件（handy-translate-20060102-150405.png），
+// 同一秒内多次保存时在文件名后加序号，返回文件路径
+func Save(img image.Image, dir string, now time.Time) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	base := "handy-translate-" + now.Format("20060102-150405")
+	for i := 0; ; i++ {
+		name := base + ".png"
+		if i > 0 {
+			name = fmt.Sprintf("%s-%d.png", base, i)
+		}
+		path := filepath.Join(dir, name)
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if err := png.Encode(f, img); err != nil {
+			f.Close()
+			os.Remove(path)
+			return "", err
+		}
+		return path, f.Close()
+	}
+}
+
+// EncodeDIB 将图片编码为 32 位 DIB（BITMAPINFOHEADER 加自下而上的 BGRA 像素），用于写入剪贴板的 CF_DIB 格式
+func EncodeDIB(img image.Image) []byte {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	const headerSize = 40
+	data := make([]byte, headerSize+w*h*4)
+	binary.LittleEndian.PutUint32(data[0:], headerSize)
+	binary.LittleEndian.PutUint32(data[4:], uint32(w))
+	binary.LittleEndian.PutUint32(data[8:], uint32(h)) // 高度为正表示自下而上
+	binary.LittleEndian.PutUint16(data[12:], 1)        // biPlanes
+	binary.LittleEndian.PutUint16(data[14:], 32)       // biBitCount
+	binary.LittleEndian.PutUint32(data[20:], uint32(w*h*4))
+
+	pix := data[headerSize:]
+	for y := 0; y < h; y++ {
+		row := pix[(h-1-y)*w*4:]
+		for x := 0; x < w; x++ {
+			r, g, bl, a := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			row[x*4+0] = uint8(bl >> 8)
+			row[x*4+1] = uint8(g >> 8)
+			row[x*4+2] = uint8(r >> 8)
+			row[x*4+3] = uint8(a >> 8)
+		}
+	}
+	return data
+}
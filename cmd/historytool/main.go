@@ -0,0 +1,84 @@
+// historytool 历史记录维护工具
+//
+//	historytool -storage ./data dedup
+//	historytool -storage ./data rekey
+//	historytool -storage ./data decrypt -out ./export
+//
+// 口令从环境变量 HANDY_TRANSLATE_PASSPHRASE、HANDY_TRANSLATE_NEW_PASSPHRASE 读取，未设置时从标准输入读取
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"handy-translate/history"
+)
+
+var stdin = bufio.NewReader(os.Stdin)
+
+func main() {
+	storagePath := flag.String("storage", "./data", "历史记录存储目录，对应 config.toml 中的 history.storage_path")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "用法: %s [-storage 目录] <命令>\n\n命令:\n", os.Args[0])
+		fmt.Fprintln(flag.CommandLine.Output(), "  dedup               合并历史记录文件中的重复记录")
+		fmt.Fprintln(flag.CommandLine.Output(), "  rekey               使用新口令重新加密历史记录（未加密的记录会被加密）")
+		fmt.Fprintln(flag.CommandLine.Output(), "  decrypt -out 目录    解密并导出历史记录")
+		fmt.Fprintln(flag.CommandLine.Output())
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	service := history.NewHistoryServiceWithPath(*storagePath)
+
+	switch flag.Arg(0) {
+	case "dedup":
+		if passphrase := os.Getenv("HANDY_TRANSLATE_PASSPHRASE"); passphrase != "" {
+			exitOnError("解锁历史记录失败", service.Unlock(passphrase))
+		}
+		removed, err := service.Dedup()
+		exitOnError("整理历史记录失败", err)
+		fmt.Printf("整理完成，合并了 %d 条重复记录\n", removed)
+	case "rekey":
+		oldPassphrase := readPassphrase("HANDY_TRANSLATE_PASSPHRASE", "当前口令（未加密时直接回车）: ")
+		newPassphrase := readPassphrase("HANDY_TRANSLATE_NEW_PASSPHRASE", "新口令: ")
+		exitOnError("重新加密失败", service.Rekey(oldPassphrase, newPassphrase))
+		fmt.Println("重新加密完成，请在 config.toml 中设置 history.encrypted = true")
+	case "decrypt":
+		fs := flag.NewFlagSet("decrypt", flag.ExitOnError)
+		out := fs.String("out", "./history-export", "导出目录")
+		fs.Parse(flag.Args()[1:])
+
+		passphrase := readPassphrase("HANDY_TRANSLATE_PASSPHRASE", "口令: ")
+		exported, err := service.ExportPlain(passphrase, *out)
+		exitOnError("导出失败", err)
+		fmt.Printf("已导出 %d 个文件到 %s\n", exported, *out)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// readPassphrase 优先从环境变量读取口令，否则提示从标准输入读取
+func readPassphrase(env, prompt string) string {
+	if v, ok := os.LookupEnv(env); ok {
+		return v
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	line, _ := stdin.ReadString('\n')
+	return strings.TrimRight(line, "\r\n")
+}
+
+func exitOnError(msg string, err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, msg+":", err)
+		os.Exit(1)
+	}
+}
@@ -1,26 +1,34 @@
 package config
 
 import (
+	_ "embed"
+	"errors"
 	"fmt"
-	"io"
 	"os"
-	"strings"
+	"path/filepath"
+	"sync"
 
+	"handy-translate/explain"
+
+	"github.com/adrg/xdg"
 	"github.com/pelletier/go-toml/v2"
 	"github.com/sirupsen/logrus"
 )
 
-// Data config
-var Data config
-
 type (
-	config struct {
-		Appname         string                    `toml:"appname"`
-		Keyboards       map[string][]string       `toml:"keyboards"`
-		TranslateWay    string                    `toml:"translate_way"`
-		Translate       map[string]Translate      `toml:"translate"`
-		ExplainTemplates ExplainTemplatesConfig   `toml:"explain_templates"`
-		History         HistoryConfig             `toml:"history"`
+	// Config 配置文件的内容，通过 Get 获取当前快照，快照只读，修改通过 Update 进行
+	Config struct {
+		Appname          string                 `toml:"appname"`
+		Keyboards        map[string][]string    `toml:"keyboards"`
+		TranslateWay     string                 `toml:"translate_way"`
+		Profile          string                 `toml:"profile,omitempty"` // 当前使用的配置方案
+		Translate        map[string]Translate   `toml:"translate"`
+		ExplainTemplates ExplainTemplatesConfig `toml:"explain_templates"`
+		Profiles         map[string]Profile     `toml:"profiles,omitempty"`
+		History          HistoryConfig          `toml:"history"`
+		Sync             SyncConfig             `toml:"sync"`
+		OCR              OCRConfig              `toml:"ocr"`
+		Screenshot       ScreenshotConfig       `toml:"screenshot"`
 	}
 
 	Translate struct {
@@ -30,77 +38,247 @@ type (
 	}
 
 	ExplainTemplatesConfig struct {
-		DefaultTemplate string                    `toml:"default_template"`
+		DefaultTemplate string                     `toml:"default_template"`
 		Templates       map[string]ExplainTemplate `toml:"templates"`
 	}
 
-	ExplainTemplate struct {
-		Name        string `toml:"name" json:"name"`
-		Description string `toml:"description" json:"description"`
-		Template    string `toml:"template" json:"template"`
+	// ExplainTemplate 解释模板，定义在 explain 包中
+	ExplainTemplate = explain.Template
+
+	// Profile 配置方案，切换时一起修改翻译服务、语言、工具栏模式和默认解释模板，为空的项保持不变
+	Profile struct {
+		Name         string `toml:"name" json:"name"`
+		TranslateWay string `toml:"translate_way,omitempty" json:"translate_way,omitempty"`
+		From         string `toml:"from,omitempty" json:"from,omitempty"`
+		To           string `toml:"to,omitempty" json:"to,omitempty"`
+		Mode         string `toml:"mode,omitempty" json:"mode,omitempty"` // "translate" 或 "explain"
+		Template     string `toml:"template,omitempty" json:"template,omitempty"`
 	}
 
 	HistoryConfig struct {
 		Enabled     bool   `toml:"enabled"`
 		StoragePath string `toml:"storage_path"`
+		Encrypted   bool   `toml:"encrypted"` // 加密存储，需要每次启动后通过 UnlockHistory 解锁
+	}
+
+	SyncConfig struct {
+		Enabled   bool   `toml:"enabled"`
+		Type      string `toml:"type"` // "webdav" 或 "s3"
+		URL       string `toml:"url"`  // WebDAV 目录地址或 S3 endpoint
+		Username  string `toml:"username"`
+		Password  string `toml:"password"`
+		Bucket    string `toml:"bucket"`
+		Region    string `toml:"region"`
+		AccessKey string `toml:"access_key"`
+		SecretKey string `toml:"secret_key"`
+		Prefix    string `toml:"prefix"`
+		Interval  int    `toml:"interval"` // 自动同步间隔（分钟），0 表示只手动同步
+	}
+
+	OCRConfig struct {
+		Engine    string  `toml:"engine"`     // "rapidocr" 或 "tesseract"，为空时使用 rapidocr
+		Path      string  `toml:"path"`       // 可执行文件路径，为空时在程序所在目录和 PATH 中查找
+		Language  string  `toml:"language"`   // 识别语言，Tesseract 为 -l 参数（如 chi_sim+eng）
+		ModelSet  string  `toml:"model_set"`  // RapidOCR 的模型组名称（见模型目录中的 configs.txt），auto 表示按源语言选择
+		ModelsDir string  `toml:"models_dir"` // RapidOCR 的模型目录，为空时为 RapidOCR-json 所在目录下的 models
+		Timeout   int     `toml:"timeout"`    // 单次识别超时（秒），0 表示使用默认值
+		MinScore  float64 `toml:"min_score"`  // 置信度低于该值的文字丢弃，0~1
+
+		Preprocess PreprocessConfig `toml:"preprocess"`
+	}
+
+	ScreenshotConfig struct {
+		Area    string `toml:"area"`     // 截图范围："cursor" 为鼠标所在的显示器，"all" 为所有显示器，为空时为 cursor
+		SaveDir string `toml:"save_dir"` // 保存截图的目录，为空时为图片目录下的 handy-translate；相对路径相对于配置文件所在目录
+	}
+
+	// PreprocessConfig 识别前对截图的预处理，按配置项的顺序进行
+	PreprocessConfig struct {
+		MinHeight  int     `toml:"min_height"`  // 截图高度小于该值时按整数倍放大（最多 4 倍），0 表示不放大
+		Grayscale  bool    `toml:"grayscale"`   // 转为灰度图，开启反色、对比度或二值化时也会转为灰度图
+		InvertDark bool    `toml:"invert_dark"` // 背景较暗（平均亮度低于一半）时反色为白底黑字
+		Contrast   float64 `toml:"contrast"`    // 对比度倍数，以中间亮度为中心拉伸，0 或 1 表示不调整
+		Binarize   bool    `toml:"binarize"`    // 按大津法自动选择阈值二值化
+		DebugDir   string  `toml:"debug_dir"`   // 保存每一步处理后图片的目录，为空时不保存；相对路径相对于配置文件所在目录
 	}
 )
 
-// Init  config
-func Init(projectName string) {
-	filePath, _ := os.Getwd()
-	b := strings.Index(filePath, projectName)
-	configPath := filePath[:b+len(projectName)]
+// EnvConfigPath 指定配置文件路径的环境变量
+const EnvConfigPath = "HANDY_TRANSLATE_CONFIG"
+
+// defaultConfig 首次运行时生成的默认配置
+//
+//go:embed default.toml
+var defaultConfig []byte
+
+// Path 当前使用的配置文件路径
+var Path string
 
-	configFile, err := os.Open(configPath + "/config.toml")
+// Init 查找并加载配置文件，查找顺序：
+//  1. 命令行参数 flagPath
+//  2. 环境变量 HANDY_TRANSLATE_CONFIG
+//  3. XDG 配置目录下的 <projectName>/config.toml
+//  4. 可执行文件所在目录下的 config.toml
+//
+// 都不存在时在 XDG 配置目录生成默认配置；1、2 指定的文件不存在时在该路径生成默认配置
+func Init(projectName, flagPath string) error {
+	configPath, err := resolvePath(projectName, flagPath)
 	if err != nil {
-		logrus.WithError(err).Error("Open")
-		os.Exit(1)
+		return err
 	}
-	defer configFile.Close()
 
-	fd, err := io.ReadAll(configFile)
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		if err := writeDefault(configPath); err != nil {
+			return fmt.Errorf("生成默认配置 %s 失败: %w", configPath, err)
+		}
+		logrus.WithField("path", configPath).Info("已生成默认配置")
+	}
+
+	fd, err := os.ReadFile(configPath)
 	if err != nil {
-		logrus.WithError(err).Error("ReadAll")
-		os.Exit(1)
+		return fmt.Errorf("读取配置 %s 失败: %w", configPath, err)
 	}
-	err = toml.Unmarshal(fd, &Data)
+
+	data, err := parse(fd)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		return fmt.Errorf("解析配置 %s 失败: %w", configPath, err)
 	}
-	fmt.Println(Data)
+
+	saveMu.Lock()
+	Path = configPath
+	store(data, fd)
+	saveMu.Unlock()
+	logrus.WithField("path", Path).Info("配置已加载")
+
+	// 校验问题不影响启动，由前端通过 Diagnostics 展示
+	for _, d := range Validate(data) {
+		logrus.WithField("field", d.Field).WithField("level", d.Level).Warn(d.Message)
+	}
+	return nil
 }
 
-func Save() {
-	filePath := "./config.toml"
-	data, err := toml.Marshal(&Data)
-	if err != nil {
-		logrus.WithError(err).Error("Marshal")
+// resolvePath 按查找顺序确定配置文件路径
+func resolvePath(projectName, flagPath string) (string, error) {
+	if flagPath != "" {
+		return filepath.Abs(flagPath)
+	}
+
+	if envPath := os.Getenv(EnvConfigPath); envPath != "" {
+		return filepath.Abs(envPath)
+	}
+
+	relPath := filepath.Join(projectName, "config.toml")
+	if p, err := xdg.SearchConfigFile(relPath); err == nil {
+		return p, nil
+	}
+
+	if exe, err := os.Executable(); err == nil {
+		p := filepath.Join(filepath.Dir(exe), "config.toml")
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		}
 	}
 
-	// 使用 os.Remove() 函数删除文件
-	err = os.Remove(filePath)
+	// 都不存在，使用 XDG 配置目录（会自动创建目录）
+	return xdg.ConfigFile(relPath)
+}
+
+// writeDefault 写入默认配置
+func writeDefault(configPath string) error {
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(configPath, defaultConfig, 0644)
+}
+
+// ResolvePath 将配置中的相对路径（如 storage_path）解析为相对于配置文件所在目录的路径
+func ResolvePath(p string) string {
+	if p == "" || filepath.IsAbs(p) || Path == "" {
+		return p
+	}
+	return filepath.Join(filepath.Dir(Path), p)
+}
+
+// ScreenshotDir 保存截图的目录
+func (c *Config) ScreenshotDir() string {
+	if c.Screenshot.SaveDir != "" {
+		return ResolvePath(c.Screenshot.SaveDir)
+	}
+	return filepath.Join(xdg.UserDirs.Pictures, "handy-translate")
+}
+
+// saveMu 串行化配置的保存和重新加载
+var saveMu sync.Mutex
+
+// save 将配置写回配置文件，只修改有变化的键，保留注释和格式。调用方需持有 saveMu
+func save(c *Config) ([]byte, error) {
+	if Path == "" {
+		return nil, errors.New("config: not initialized")
+	}
+
+	data, err := toml.Marshal(c)
 	if err != nil {
-		fmt.Println("删除文件时出错:", err)
-		return
+		return nil, fmt.Errorf("序列化配置失败: %w", err)
 	}
 
-	fmt.Println("文件已成功删除")
+	src, err := os.ReadFile(Path)
+	switch {
+	case err == nil:
+		if patched, ok := patchTOML(src, data, knownTOML(src)); ok {
+			data = patched
+		} else {
+			logrus.WithField("path", Path).Warn("无法保留配置文件格式，将整体重写")
+		}
+	case !os.IsNotExist(err):
+		return nil, fmt.Errorf("读取配置 %s 失败: %w", Path, err)
+	}
 
-	// 打开文件，如果文件不存在则创建
-	file, err := os.Create(filePath)
+	if err := writeFileAtomic(Path, data, 0644); err != nil {
+		return nil, fmt.Errorf("写入配置 %s 失败: %w", Path, err)
+	}
+	return data, nil
+}
+
+// knownTOML 原文件中配置结构能识别的部分：解码为配置后再 Marshal，无法解码时为 nil
+func knownTOML(src []byte) []byte {
+	var c Config
+	if err := toml.Unmarshal(src, &c); err != nil {
+		return nil
+	}
+	data, err := toml.Marshal(&c)
 	if err != nil {
-		fmt.Println("无法创建文件:", err)
-		return
+		return nil
 	}
-	defer file.Close()
+	return data
+}
 
-	_, err = file.Write(data)
+// writeFileAtomic 先写入同目录下的临时文件并落盘，再重命名覆盖原文件，写入过程中崩溃不会损坏原文件。
+// 文件已存在时保留原有权限，否则使用 perm
+func writeFileAtomic(filePath string, data []byte, perm os.FileMode) error {
+	if info, err := os.Stat(filePath); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), "."+filepath.Base(filePath)+".*.tmp")
 	if err != nil {
-		fmt.Println("无法写入文件:", err)
-		return
+		return err
 	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // 重命名成功后文件已不存在
 
-	fmt.Println("数据写入成功!")
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, filePath)
 }
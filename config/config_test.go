@@ -0,0 +1,746 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"handy-translate/explain"
+)
+
+func TestInitGeneratesDefault(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "sub", "config.toml")
+	if err := Init("handy-translate", configPath); err != nil {
+		t.Fatal(err)
+	}
+	if Path != configPath {
+		t.Errorf("期望使用 %s，实际 %s", configPath, Path)
+	}
+	if _, err := os.Stat(configPath); err != nil {
+		t.Fatalf("应生成默认配置: %v", err)
+	}
+	if c := Get(); c.Appname != "handy-translate" || len(c.Translate) == 0 {
+		t.Errorf("默认配置内容错误: %+v", c)
+	}
+}
+
+func TestInitFromEnv(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(configPath, []byte("appname = \"from-env\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(EnvConfigPath, configPath)
+
+	if err := Init("handy-translate", ""); err != nil {
+		t.Fatal(err)
+	}
+	if Get().Appname != "from-env" {
+		t.Errorf("应读取环境变量指定的配置，实际 appname=%q", Get().Appname)
+	}
+	if got := ResolvePath("./data"); got != filepath.Join(filepath.Dir(configPath), "data") {
+		t.Errorf("相对路径应相对于配置文件目录，实际 %s", got)
+	}
+}
+
+func TestInitInvalid(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(configPath, []byte("appname = "), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Init("handy-translate", configPath); err == nil {
+		t.Error("配置格式错误时应返回错误")
+	}
+}
+
+const commentedConfig = `# 应用配置
+appname = 'handy-translate'
+translate_way = 'baidu' # 当前翻译服务
+
+[keyboards]
+toolBar = ['center', '', '']
+
+# 百度翻译
+[translate.baidu]
+name = '百度翻译'
+appID = 'APP ID'
+key = '密钥'
+
+# 有道翻译
+[translate.youdao]
+name = '有道翻译'
+appID = '应用ID'
+key = '应用密钥'
+
+[explain_templates]
+default_template = 'programmer'
+
+[explain_templates.templates.programmer]
+name = '技术视角'
+description = '程序员'
+template = '''多行
+模板：{{.text}}'''
+
+[history]
+enabled = true
+storage_path = "./data"
+custom = 1 # 配置结构中没有的键
+`
+
+func TestSavePreservesComments(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(configPath, []byte(commentedConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := Init("handy-translate", configPath); err != nil {
+		t.Fatal(err)
+	}
+
+	before := Get()
+	err := Update(func(c *Config) error {
+		c.TranslateWay = "youdao"
+		delete(c.Translate, "baidu")
+		c.Translate["deepseek"] = Translate{Name: "DeepSeek", AppID: "deepseek", Key: "sk"}
+		c.History.Encrypted = true
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := before.Translate["baidu"]; !ok || before.TranslateWay != "baidu" {
+		t.Error("Update 不应修改旧的快照")
+	}
+
+	b, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := string(b)
+	for _, want := range []string{
+		"# 应用配置\n",
+		"translate_way = 'youdao' # 当前翻译服务\n",
+		"# 有道翻译\n[translate.youdao]\n",
+		"template = '''多行\n模板：{{.text}}'''\n",
+		"custom = 1 # 配置结构中没有的键\n",
+	} {
+		if !strings.Contains(saved, want) {
+			t.Errorf("保存后应保留 %q，实际:\n%s", want, saved)
+		}
+	}
+	for _, unwanted := range []string{"百度翻译", "# 百度翻译"} {
+		if strings.Contains(saved, unwanted) {
+			t.Errorf("删除的表应被移除: %q，实际:\n%s", unwanted, saved)
+		}
+	}
+
+	want := Get()
+	if err := Init("handy-translate", configPath); err != nil {
+		t.Fatal(err)
+	}
+	if c := Get(); c.TranslateWay != "youdao" || c.Translate["deepseek"].Key != "sk" || !c.History.Encrypted ||
+		len(c.Translate) != len(want.Translate) {
+		t.Errorf("重新加载的配置与保存的不一致: %+v", c)
+	}
+
+	if info, err := os.Stat(configPath); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("应保留原文件权限: %v %v", info, err)
+	}
+	if tmp, _ := filepath.Glob(filepath.Join(filepath.Dir(configPath), "*.tmp")); len(tmp) != 0 {
+		t.Errorf("不应残留临时文件: %v", tmp)
+	}
+}
+
+func TestSaveConcurrent(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.toml")
+	if err := Init("handy-translate", configPath); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := Update(func(c *Config) error { return nil }); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	b, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != string(defaultConfig) {
+		t.Errorf("配置没有变化时保存不应修改文件，实际:\n%s", b)
+	}
+}
+
+func TestPatchTOMLUnsupported(t *testing.T) {
+	src := []byte("point = { x = 1, y = 2 }\n")
+	if _, ok := patchTOML(src, []byte("[point]\nx = 3\ny = 2\n"), nil); ok {
+		t.Error("内联表内部的修改应交由调用方整体重写")
+	}
+}
+
+func TestSaveTemplateExamples(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.toml")
+	if err := Init("handy-translate", configPath); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(configPath, append([]byte("# 应用配置\n"), b...), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Init("handy-translate", configPath); err != nil {
+		t.Fatal(err)
+	}
+
+	update := func(examples ...explain.Example) {
+		t.Helper()
+		if err := Update(func(c *Config) error {
+			for id, tpl := range c.ExplainTemplates.Templates {
+				tpl.Examples = examples
+				c.ExplainTemplates.Templates[id] = tpl
+			}
+			c.Appname = fmt.Sprintf("examples-%d", len(examples))
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		b, err := os.ReadFile(configPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(b), "# 应用配置\n") {
+			t.Errorf("修改示例后应保留注释，实际:\n%s", b)
+		}
+		if got := strings.Count(string(b), ".examples]]"); got != len(examples)*len(Get().ExplainTemplates.Templates) {
+			t.Errorf("期望 %d 个示例表，实际 %d:\n%s", len(examples)*len(Get().ExplainTemplates.Templates), got, b)
+		}
+
+		want := Get()
+		if err := Init("handy-translate", configPath); err != nil {
+			t.Fatal(err)
+		}
+		for id, tpl := range Get().ExplainTemplates.Templates {
+			if !reflect.DeepEqual(tpl.Examples, want.ExplainTemplates.Templates[id].Examples) {
+				t.Errorf("模板 %s 重新加载的示例不一致: %+v", id, tpl.Examples)
+			}
+		}
+	}
+
+	update(explain.Example{Input: "CPU", Output: "中央处理器"}, explain.Example{Input: "GPU", Output: "图形处理器"})
+	update(explain.Example{Input: "CPU", Output: "中央处理器"})
+	update()
+}
+
+func TestSaveClearsFields(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(configPath, []byte(commentedConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := Init("handy-translate", configPath); err != nil {
+		t.Fatal(err)
+	}
+
+	temperature := 0.3
+	if err := AddTemplate("tuned", ExplainTemplate{Name: "调参", Template: "{{.text}}", System: "sys", Temperature: &temperature, MaxTokens: 100}); err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdateTemplate("tuned", ExplainTemplate{Name: "调参", Template: "{{.text}}"}); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := string(b)
+	for _, stale := range []string{"system", "temperature", "max_tokens"} {
+		if strings.Contains(saved, stale) {
+			t.Errorf("清空的 %s 应从配置文件中删除，实际:\n%s", stale, saved)
+		}
+	}
+	for _, want := range []string{"# 应用配置\n", "custom = 1 # 配置结构中没有的键\n"} {
+		if !strings.Contains(saved, want) {
+			t.Errorf("保存后应保留 %q，实际:\n%s", want, saved)
+		}
+	}
+
+	if err := Init("handy-translate", configPath); err != nil {
+		t.Fatal(err)
+	}
+	if tpl := Get().ExplainTemplates.Templates["tuned"]; tpl.System != "" || tpl.Temperature != nil || tpl.MaxTokens != 0 {
+		t.Errorf("重新加载后清空的字段不应恢复: %+v", tpl)
+	}
+}
+
+func TestReload(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.toml")
+	if err := Init("handy-translate", configPath); err != nil {
+		t.Fatal(err)
+	}
+
+	var changes []*Config
+	Subscribe(func(old, new *Config) {
+		if new.Appname == "reloaded" || old.Appname == "reloaded" {
+			changes = append(changes, new)
+		}
+	})
+
+	// 自己保存的修改不触发重新加载
+	if err := Update(func(c *Config) error { c.Appname = "reloaded"; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if err := Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 {
+		t.Fatalf("期望通知 1 次，实际 %d", len(changes))
+	}
+
+	// 手动修改
+	b, _ := os.ReadFile(configPath)
+	edited := strings.Replace(string(b), "translate_way = 'deepseek'", "translate_way = 'youdao'", 1)
+	if err := os.WriteFile(configPath, []byte(edited), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if Get().TranslateWay != "youdao" || len(changes) != 2 {
+		t.Errorf("手动修改后应重新加载并通知: %s %d", Get().TranslateWay, len(changes))
+	}
+
+	// 校验失败时保留修改前的配置
+	invalid := strings.Replace(edited, "translate_way = 'youdao'", "translate_way = 'missing'", 1)
+	if err := os.WriteFile(configPath, []byte(invalid), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Reload(); err == nil {
+		t.Error("translate_way 不存在时应返回错误")
+	}
+	if Get().TranslateWay != "youdao" || len(changes) != 2 {
+		t.Errorf("校验失败时不应替换配置: %s %d", Get().TranslateWay, len(changes))
+	}
+
+	// 启动时已存在的问题不妨碍修改其他配置项后重新加载
+	if err := Init("handy-translate", configPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := firstError(Validate(Get())); err == nil {
+		t.Fatal("启动时的配置应有 error 级别的问题")
+	}
+	fixed := strings.Replace(invalid, "appname = 'reloaded'", "appname = 'still-invalid'", 1)
+	if err := os.WriteFile(configPath, []byte(fixed), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Reload(); err != nil {
+		t.Errorf("已存在的问题不应阻止重新加载: %v", err)
+	}
+	if Get().Appname != "still-invalid" {
+		t.Errorf("应重新加载修改后的配置，实际 appname=%q", Get().Appname)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	c, err := parse(defaultConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.History.StoragePath = t.TempDir()
+
+	diags := Validate(c)
+	if err := firstError(diags); err != nil {
+		t.Errorf("默认配置不应有错误: %v", err)
+	}
+	fields := map[string]bool{}
+	for _, d := range diags {
+		fields[d.Field] = true
+	}
+	for _, field := range []string{"translate.baidu.key", "translate.baidu.appID", "translate.youdao.key", "translate.deepseek.key"} {
+		if !fields[field] {
+			t.Errorf("占位值应产生提示: %s，实际 %v", field, diags)
+		}
+	}
+
+	c.TranslateWay = "missing"
+	c.ExplainTemplates.DefaultTemplate = "missing"
+	c.ExplainTemplates.Templates["broken"] = ExplainTemplate{Template: "{{.text"}
+	c.ExplainTemplates.Templates["notext"] = ExplainTemplate{Template: "解释一下"}
+	temperature := 3.0
+	c.ExplainTemplates.Templates["params"] = ExplainTemplate{
+		System:      "{{.lang}}",
+		Template:    "{{.text}}",
+		Examples:    []explain.Example{{Input: "CPU"}},
+		Temperature: &temperature,
+		MaxTokens:   -1,
+	}
+	c.OCR.Preprocess.MinHeight = -1
+	c.OCR.Preprocess.Contrast = -0.5
+	c.Screenshot.Area = "primary"
+	c.Translate["deepseek"] = Translate{Name: "DeepSeek", Key: "sk-real"}
+
+	want := map[string]string{
+		"translate_way":                                  LevelError,
+		"explain_templates.default_template":             LevelError,
+		"explain_templates.templates.broken.template":    LevelError,
+		"explain_templates.templates.notext.template":    LevelWarning,
+		"explain_templates.templates.params.system":      LevelError,
+		"explain_templates.templates.params.examples[0]": LevelWarning,
+		"explain_templates.templates.params.temperature": LevelError,
+		"explain_templates.templates.params.max_tokens":  LevelError,
+		"ocr.preprocess.min_height":                      LevelError,
+		"ocr.preprocess.contrast":                        LevelError,
+		"screenshot.area":                                LevelError,
+	}
+	got := map[string]string{}
+	for _, d := range Validate(c) {
+		got[d.Field] = d.Level
+	}
+	for field, level := range want {
+		if got[field] != level {
+			t.Errorf("%s 期望 %s，实际 %q", field, level, got[field])
+		}
+	}
+	if _, ok := got["translate.deepseek.key"]; ok {
+		t.Error("已填写的密钥不应产生提示")
+	}
+	if diags := Validate(c); diags[0].Level != LevelError {
+		t.Error("error 级别的问题应排在前面")
+	}
+}
+
+func TestCheckWritable(t *testing.T) {
+	dir := t.TempDir()
+
+	// 目录不存在时检查上级目录，校验不创建目录
+	missing := filepath.Join(dir, "typo", "history")
+	if err := checkWritable(missing); err != nil {
+		t.Errorf("上级目录可写时应通过: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "typo")); !os.IsNotExist(err) {
+		t.Error("校验不应创建目录")
+	}
+
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkWritable(filepath.Join(file, "sub")); err == nil {
+		t.Error("上级是文件时应返回错误")
+	}
+}
+
+func TestSecrets(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.toml")
+	if err := Init("handy-translate", configPath); err != nil {
+		t.Fatal(err)
+	}
+	// 没有可用的 Secret Service，SetSecret 应改用密钥文件
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", "unix:path="+filepath.Join(dir, "no-bus"))
+
+	t.Setenv("TEST_DEEPSEEK_KEY", "sk-from-env")
+	if err := os.WriteFile(filepath.Join(dir, "baidu.key"), []byte("baidu-secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	err := Update(func(c *Config) error {
+		c.Translate["deepseek"] = Translate{Name: "DeepSeek", Key: "env:TEST_DEEPSEEK_KEY"}
+		c.Translate["baidu"] = Translate{Name: "百度翻译", AppID: "123", Key: "file:baidu.key"}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := Get().ResolveTranslate("deepseek"); err != nil || got.Key != "sk-from-env" {
+		t.Errorf("env 引用解析错误: %q %v", got.Key, err)
+	}
+	if got, err := Get().ResolveTranslate("baidu"); err != nil || got.Key != "baidu-secret" || got.AppID != "123" {
+		t.Errorf("file 引用解析错误: %+v %v", got, err)
+	}
+
+	// 解析结果缓存到配置下次变化
+	t.Setenv("TEST_DEEPSEEK_KEY", "sk-changed")
+	if got, _ := Get().ResolveTranslate("deepseek"); got.Key != "sk-from-env" {
+		t.Errorf("配置未变化时应使用缓存的密钥，实际 %q", got.Key)
+	}
+	if err := Update(func(c *Config) error { c.Appname = "secrets"; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := Get().ResolveTranslate("deepseek"); got.Key != "sk-changed" {
+		t.Errorf("配置变化后应重新解析密钥，实际 %q", got.Key)
+	}
+
+	// 校验只检查引用的格式，不读取密钥
+	c := Get().Clone()
+	c.Translate["deepseek"] = Translate{Name: "DeepSeek", Key: "env:TEST_MISSING_KEY"}
+	c.Translate["caiyun"] = Translate{Name: "彩云小译", Key: "keyring:"}
+	got := map[string]bool{}
+	for _, d := range Validate(c) {
+		got[d.Field] = true
+	}
+	if got["translate.deepseek.key"] || !got["translate.caiyun.key"] {
+		t.Errorf("校验引用格式错误: %v", Validate(c))
+	}
+
+	if err := SetSecret("youdao", "key", "youdao-secret-value"); err != nil {
+		t.Fatal(err)
+	}
+	ref := Get().Translate["youdao"].Key
+	if !IsSecretRef(ref) {
+		t.Fatalf("配置中应只保存引用，实际 %q", ref)
+	}
+	if got, err := Get().ResolveTranslate("youdao"); err != nil || got.Key != "youdao-secret-value" {
+		t.Errorf("SetSecret 保存的密钥读取错误: %q %v", got.Key, err)
+	}
+	saved, _ := os.ReadFile(configPath)
+	if strings.Contains(string(saved), "youdao-secret-value") {
+		t.Error("密钥不应写入配置文件")
+	}
+
+	for value, want := range map[string]string{
+		"":                    "",
+		"short":               "****",
+		"sk-1234567890abcdef": "****cdef",
+		"env:KEY":             "env:KEY",
+	} {
+		if got := MaskSecret(value); got != want {
+			t.Errorf("MaskSecret(%q) = %q，期望 %q", value, got, want)
+		}
+	}
+
+	if err := SetSecret("youdao", "name", "x"); err == nil {
+		t.Error("不支持的字段应返回错误")
+	}
+}
+
+func TestProviderCRUD(t *testing.T) {
+	dir := t.TempDir()
+	if err := Init("handy-translate", filepath.Join(dir, "config.toml")); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", "unix:path="+filepath.Join(dir, "no-bus"))
+
+	if err := AddProvider("unknown", Translate{}); !errors.Is(err, ErrUnsupportedProvider) {
+		t.Errorf("不支持的翻译服务应返回 ErrUnsupportedProvider，实际 %v", err)
+	}
+	// 未通过校验的修改不会保存密钥
+	if err := AddProvider("baidu", Translate{AppID: "baidu-app-id", Key: "baidu-secret"}); !errors.Is(err, ErrProviderExists) {
+		t.Errorf("重复添加应返回 ErrProviderExists，实际 %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "secrets")); !os.IsNotExist(err) {
+		t.Errorf("添加失败时不应保存密钥: %v", err)
+	}
+
+	if err := AddProvider("caiyun", Translate{Name: "彩云小译", Key: "caiyun-token-1234"}); err != nil {
+		t.Fatal(err)
+	}
+	caiyun := Get().Translate["caiyun"]
+	if !IsSecretRef(caiyun.Key) {
+		t.Errorf("添加的密钥应保存为引用，实际 %q", caiyun.Key)
+	}
+
+	// 传回遮盖值时保持密钥不变
+	if err := UpdateProvider("caiyun", Translate{Name: "彩云", Key: MaskSecret(caiyun.Key)}); err != nil {
+		t.Fatal(err)
+	}
+	if got := Get().Translate["caiyun"]; got.Name != "彩云" || got.Key != caiyun.Key {
+		t.Errorf("修改后的配置错误: %+v", got)
+	}
+	if err := UpdateProvider("missing", Translate{}); !errors.Is(err, ErrProviderNotFound) {
+		t.Errorf("修改不存在的翻译服务应返回 ErrProviderNotFound，实际 %v", err)
+	}
+
+	// appID 同样只保存引用
+	if err := UpdateProvider("youdao", Translate{Name: "有道翻译", AppID: "youdao-app-id", Key: "youdao-secret"}); err != nil {
+		t.Fatal(err)
+	}
+	youdao := Get().Translate["youdao"]
+	if !IsSecretRef(youdao.AppID) || !IsSecretRef(youdao.Key) {
+		t.Errorf("appID 和 key 都应保存为引用，实际 %+v", youdao)
+	}
+	if got, err := Get().ResolveTranslate("youdao"); err != nil || got.AppID != "youdao-app-id" || got.Key != "youdao-secret" {
+		t.Errorf("保存的密钥读取错误: %+v %v", got, err)
+	}
+	saved, _ := os.ReadFile(filepath.Join(dir, "config.toml"))
+	if strings.Contains(string(saved), "youdao-app-id") || strings.Contains(string(saved), "youdao-secret") {
+		t.Error("appID 和 key 不应写入配置文件")
+	}
+
+	if err := DeleteProvider(Get().TranslateWay); !errors.Is(err, ErrInvalid) {
+		t.Errorf("不能删除当前使用的翻译服务，实际 %v", err)
+	}
+	if err := DeleteProvider("caiyun"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := Get().Translate["caiyun"]; ok {
+		t.Error("删除后不应存在")
+	}
+
+	// 引入新错误的修改不会保存
+	err := Update(func(c *Config) error {
+		c.TranslateWay = "missing"
+		return nil
+	})
+	if !errors.Is(err, ErrInvalid) || Get().TranslateWay == "missing" {
+		t.Errorf("未通过校验的修改应被拒绝: %v", err)
+	}
+}
+
+func TestStoreSecretUndo(t *testing.T) {
+	dir := t.TempDir()
+	if err := Init("handy-translate", filepath.Join(dir, "config.toml")); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", "unix:path="+filepath.Join(dir, "no-bus"))
+
+	// 原来没有的密钥撤销时删除
+	_, undo, err := storeSecretUndo("caiyun.key", "new-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := undo(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(secretFile("caiyun.key")); !os.IsNotExist(err) {
+		t.Errorf("撤销后密钥文件应被删除: %v", err)
+	}
+
+	// 覆盖已有的密钥，撤销时恢复原值
+	if _, err := storeSecret("baidu.key", "old-secret"); err != nil {
+		t.Fatal(err)
+	}
+	ref, undo, err := storeSecretUndo("baidu.key", "new-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := resolveSecret(ref); got != "new-secret" {
+		t.Errorf("保存的密钥错误: %q", got)
+	}
+	if err := undo(); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := resolveSecret(ref); got != "old-secret" {
+		t.Errorf("撤销后应恢复原来的密钥，实际 %q", got)
+	}
+}
+
+func TestTemplateCRUD(t *testing.T) {
+	dir := t.TempDir()
+	if err := Init("handy-translate", filepath.Join(dir, "config.toml")); err != nil {
+		t.Fatal(err)
+	}
+	defaultID := Get().ExplainTemplates.DefaultTemplate
+
+	if err := AddTemplate("bad id", ExplainTemplate{}); !errors.Is(err, ErrInvalidTemplate) {
+		t.Errorf("非法 ID 应返回 ErrInvalidTemplate，实际 %v", err)
+	}
+	if err := AddTemplate(defaultID, ExplainTemplate{Template: "{{.text}}"}); !errors.Is(err, ErrTemplateExists) {
+		t.Errorf("重复添加应返回 ErrTemplateExists，实际 %v", err)
+	}
+	if err := AddTemplate("broken", ExplainTemplate{Template: "{{.text"}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("无法渲染的模板应被拒绝，实际 %v", err)
+	}
+
+	if err := AddTemplate("short", ExplainTemplate{Name: "简短", Template: "简短解释：{{.text}}"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdateTemplate("short", ExplainTemplate{Name: "简短", Template: "一句话解释：{{.text}}"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := Get().ExplainTemplates.Templates["short"].Template; got != "一句话解释：{{.text}}" {
+		t.Errorf("修改后的模板错误: %q", got)
+	}
+
+	id, err := DuplicateTemplate("short", "")
+	if err != nil || id != "short_copy" {
+		t.Fatalf("复制模板: id=%q err=%v", id, err)
+	}
+	if id, _ := DuplicateTemplate("short", ""); id != "short_copy_2" {
+		t.Errorf("再次复制应生成 short_copy_2，实际 %q", id)
+	}
+
+	if err := DeleteTemplate(defaultID); !errors.Is(err, ErrInvalid) {
+		t.Errorf("不能删除默认模板，实际 %v", err)
+	}
+	if err := DeleteTemplate("short_copy_2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteTemplate("short_copy_2"); !errors.Is(err, ErrTemplateNotFound) {
+		t.Errorf("删除不存在的模板应返回 ErrTemplateNotFound，实际 %v", err)
+	}
+
+	// 导出后重新导入，冲突的 ID 自动重命名
+	pack, err := ExportTemplates("pack", []string{"short"})
+	if err != nil || len(pack.Templates) != 1 {
+		t.Fatalf("导出模板: %+v %v", pack, err)
+	}
+	ids, err := ImportTemplates(pack, false)
+	if err != nil || len(ids) != 1 || ids[0] != "short_2" {
+		t.Fatalf("导入模板: %v %v", ids, err)
+	}
+	ids, err = ImportTemplates(pack, true)
+	if err != nil || len(ids) != 1 || ids[0] != "short" {
+		t.Errorf("覆盖导入: %v %v", ids, err)
+	}
+	if _, err := ExportTemplates("pack", []string{"missing"}); !errors.Is(err, ErrTemplateNotFound) {
+		t.Errorf("导出不存在的模板应返回 ErrTemplateNotFound，实际 %v", err)
+	}
+}
+
+func TestSwitchProfile(t *testing.T) {
+	dir := t.TempDir()
+	if err := Init("handy-translate", filepath.Join(dir, "config.toml")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := SwitchProfile("missing"); !errors.Is(err, ErrProfileNotFound) {
+		t.Errorf("切换不存在的方案应返回 ErrProfileNotFound，实际 %v", err)
+	}
+
+	p, err := SwitchProfile("writing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.From != "zh" || p.To != "en" || p.Mode != ModeTranslate {
+		t.Errorf("返回的方案错误: %+v", p)
+	}
+	if c := Get(); c.Profile != "writing" || c.TranslateWay != "baidu" {
+		t.Errorf("切换后的配置错误: profile=%q translate_way=%q", c.Profile, c.TranslateWay)
+	}
+
+	// 切换结果保存到配置文件
+	if err := Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if Get().Profile != "writing" {
+		t.Errorf("重新加载后应保留当前方案，实际 %q", Get().Profile)
+	}
+
+	if _, err := SwitchProfile("reading"); err != nil {
+		t.Fatal(err)
+	}
+	if c := Get(); c.TranslateWay != "deepseek" || c.ExplainTemplates.DefaultTemplate != "programmer" {
+		t.Errorf("切换后的配置错误: translate_way=%q template=%q", c.TranslateWay, c.ExplainTemplates.DefaultTemplate)
+	}
+
+	// 方案中的模式和模板需要有效
+	err = Update(func(c *Config) error {
+		c.Profiles["reading"] = Profile{Name: "阅读", Mode: "read", Template: "missing"}
+		return nil
+	})
+	if !errors.Is(err, ErrInvalid) {
+		t.Errorf("无效的方案应被拒绝，实际 %v", err)
+	}
+}
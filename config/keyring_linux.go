@@ -0,0 +1,210 @@
+//go:build linux
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// 通过 Secret Service D-Bus 接口（GNOME Keyring、KWallet 等）读写系统密钥环
+
+const (
+	secretService    = "org.freedesktop.secrets"
+	secretPath       = dbus.ObjectPath("/org/freedesktop/secrets")
+	secretIface      = "org.freedesktop.Secret.Service"
+	collectionIface  = "org.freedesktop.Secret.Collection"
+	itemIface        = "org.freedesktop.Secret.Item"
+	promptIface      = "org.freedesktop.Secret.Prompt"
+	defaultAlias     = dbus.ObjectPath("/org/freedesktop/secrets/aliases/default")
+	promptTimeout    = 2 * time.Minute
+	keyringAttribute = "handy-translate"
+)
+
+// secretValue Secret Service 中的 Secret 结构 (oayays)
+type secretValue struct {
+	Session     dbus.ObjectPath
+	Parameters  []byte
+	Value       []byte
+	ContentType string
+}
+
+type secretClient struct {
+	conn    *dbus.Conn
+	service dbus.BusObject
+	session dbus.ObjectPath
+}
+
+func newSecretClient() (*secretClient, error) {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrKeyringUnavailable, err)
+	}
+
+	c := &secretClient{conn: conn, service: conn.Object(secretService, secretPath)}
+	var output dbus.Variant
+	if err := c.service.Call(secretIface+".OpenSession", 0, "plain", dbus.MakeVariant("")).Store(&output, &c.session); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrKeyringUnavailable, err)
+	}
+	return c, nil
+}
+
+func (c *secretClient) close() {
+	c.conn.Object(secretService, c.session).Call("org.freedesktop.Secret.Session.Close", 0)
+}
+
+func attributes(name string) map[string]string {
+	return map[string]string{"application": keyringAttribute, "name": name}
+}
+
+// keyringGet 读取密钥环中的密钥
+func keyringGet(name string) (string, error) {
+	c, err := newSecretClient()
+	if err != nil {
+		return "", err
+	}
+	defer c.close()
+
+	var unlocked, locked []dbus.ObjectPath
+	if err := c.service.Call(secretIface+".SearchItems", 0, attributes(name)).Store(&unlocked, &locked); err != nil {
+		return "", err
+	}
+	if len(unlocked) == 0 && len(locked) > 0 {
+		if unlocked, err = c.unlock(locked); err != nil {
+			return "", err
+		}
+	}
+	if len(unlocked) == 0 {
+		return "", fmt.Errorf("密钥环中没有 %q", name)
+	}
+
+	var secret secretValue
+	if err := c.conn.Object(secretService, unlocked[0]).Call(itemIface+".GetSecret", 0, c.session).Store(&secret); err != nil {
+		return "", err
+	}
+	return string(secret.Value), nil
+}
+
+// keyringSet 将密钥保存到默认密钥环，已存在时覆盖
+func keyringSet(name, value string) error {
+	c, err := newSecretClient()
+	if err != nil {
+		return err
+	}
+	defer c.close()
+
+	collection := defaultAlias
+	var alias dbus.ObjectPath
+	if err := c.service.Call(secretIface+".ReadAlias", 0, "default").Store(&alias); err == nil && alias != "/" {
+		collection = alias
+	}
+	if _, err := c.unlock([]dbus.ObjectPath{collection}); err != nil {
+		return err
+	}
+
+	properties := map[string]dbus.Variant{
+		itemIface + ".Label":      dbus.MakeVariant("Handy Translate: " + name),
+		itemIface + ".Attributes": dbus.MakeVariant(attributes(name)),
+	}
+	secret := secretValue{Session: c.session, Value: []byte(value), ContentType: "text/plain"}
+
+	var item, prompt dbus.ObjectPath
+	err = c.conn.Object(secretService, collection).
+		Call(collectionIface+".CreateItem", 0, properties, secret, true).
+		Store(&item, &prompt)
+	if err != nil {
+		return err
+	}
+	_, err = c.prompt(prompt)
+	return err
+}
+
+// keyringDelete 删除密钥环中的密钥，不存在时什么也不做
+func keyringDelete(name string) error {
+	c, err := newSecretClient()
+	if err != nil {
+		return err
+	}
+	defer c.close()
+
+	var unlocked, locked []dbus.ObjectPath
+	if err := c.service.Call(secretIface+".SearchItems", 0, attributes(name)).Store(&unlocked, &locked); err != nil {
+		return err
+	}
+	for _, item := range append(unlocked, locked...) {
+		var prompt dbus.ObjectPath
+		if err := c.conn.Object(secretService, item).Call(itemIface+".Delete", 0).Store(&prompt); err != nil {
+			return err
+		}
+		if _, err := c.prompt(prompt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unlock 解锁对象，需要用户确认时弹出系统的解锁对话框
+func (c *secretClient) unlock(objects []dbus.ObjectPath) ([]dbus.ObjectPath, error) {
+	var unlocked []dbus.ObjectPath
+	var prompt dbus.ObjectPath
+	if err := c.service.Call(secretIface+".Unlock", 0, objects).Store(&unlocked, &prompt); err != nil {
+		return nil, err
+	}
+	if prompt == "/" {
+		return unlocked, nil
+	}
+
+	result, err := c.prompt(prompt)
+	if err != nil {
+		return nil, err
+	}
+	if paths, ok := result.Value().([]dbus.ObjectPath); ok {
+		return paths, nil
+	}
+	return nil, errors.New("解锁密钥环失败")
+}
+
+// prompt 显示 Secret Service 的确认对话框并等待 Completed 信号，prompt 为 "/" 时无需确认
+func (c *secretClient) prompt(prompt dbus.ObjectPath) (dbus.Variant, error) {
+	if prompt == "/" || prompt == "" {
+		return dbus.Variant{}, nil
+	}
+
+	match := []dbus.MatchOption{
+		dbus.WithMatchObjectPath(prompt),
+		dbus.WithMatchInterface(promptIface),
+		dbus.WithMatchMember("Completed"),
+	}
+	if err := c.conn.AddMatchSignal(match...); err != nil {
+		return dbus.Variant{}, err
+	}
+	defer c.conn.RemoveMatchSignal(match...)
+
+	signals := make(chan *dbus.Signal, 1)
+	c.conn.Signal(signals)
+	defer c.conn.RemoveSignal(signals)
+
+	if err := c.conn.Object(secretService, prompt).Call(promptIface+".Prompt", 0, "").Err; err != nil {
+		return dbus.Variant{}, err
+	}
+
+	timeout := time.After(promptTimeout)
+	for {
+		select {
+		case signal := <-signals:
+			if signal.Path != prompt || len(signal.Body) < 2 {
+				continue
+			}
+			if dismissed, _ := signal.Body[0].(bool); dismissed {
+				return dbus.Variant{}, errors.New("用户取消了密钥环操作")
+			}
+			result, _ := signal.Body[1].(dbus.Variant)
+			return result, nil
+		case <-timeout:
+			return dbus.Variant{}, errors.New("等待密钥环确认超时")
+		}
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+
+package config
+
+// 其他平台没有 Secret Service，SetSecret 会改用配置目录下的密钥文件
+
+func keyringGet(name string) (string, error) {
+	return "", ErrKeyringUnavailable
+}
+
+func keyringSet(name, value string) error {
+	return ErrKeyringUnavailable
+}
+
+func keyringDelete(name string) error {
+	return ErrKeyringUnavailable
+}
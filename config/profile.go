@@ -0,0 +1,47 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// 工具栏模式
+const (
+	ModeTranslate = "translate"
+	ModeExplain   = "explain"
+)
+
+var ErrProfileNotFound = errors.New("config: profile not found")
+
+// ProfileIDs 按 ID 排序的配置方案
+func (c *Config) ProfileIDs() []string {
+	ids := make([]string, 0, len(c.Profiles))
+	for id := range c.Profiles {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// SwitchProfile 切换配置方案：记录当前方案，并修改翻译服务和默认解释模板。
+// 语言和工具栏模式不保存在配置中，由调用方根据返回的方案设置
+func SwitchProfile(id string) (Profile, error) {
+	var profile Profile
+	err := Update(func(c *Config) error {
+		p, ok := c.Profiles[id]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrProfileNotFound, id)
+		}
+		profile = p
+		c.Profile = id
+		if p.TranslateWay != "" {
+			c.TranslateWay = p.TranslateWay
+		}
+		if p.Template != "" {
+			c.ExplainTemplates.DefaultTemplate = p.Template
+		}
+		return nil
+	})
+	return profile, err
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var (
+	ErrProviderExists      = errors.New("config: provider already exists")
+	ErrProviderNotFound    = errors.New("config: provider not found")
+	ErrUnsupportedProvider = errors.New("config: unsupported provider")
+)
+
+// Providers 支持的翻译服务
+func Providers() []string {
+	ways := make([]string, 0, len(requiredFields))
+	for way := range requiredFields {
+		ways = append(ways, way)
+	}
+	sort.Strings(ways)
+	return ways
+}
+
+// AddProvider 在 [translate] 中添加翻译服务，appID、key 保存到密钥环或密钥文件，配置中只记录引用
+func AddProvider(way string, t Translate) error {
+	if _, ok := requiredFields[way]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnsupportedProvider, way)
+	}
+
+	return saveProvider(way, t, func(c *Config, t Translate) error {
+		if _, ok := c.Translate[way]; ok {
+			return fmt.Errorf("%w: %s", ErrProviderExists, way)
+		}
+		if c.Translate == nil {
+			c.Translate = map[string]Translate{}
+		}
+		c.Translate[way] = t
+		return nil
+	})
+}
+
+// UpdateProvider 修改翻译服务。appID、key 与 GetTranslateMap 返回的遮盖值相同时视为未修改
+func UpdateProvider(way string, t Translate) error {
+	existing, ok := Get().Translate[way]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrProviderNotFound, way)
+	}
+
+	if t.AppID == MaskSecret(existing.AppID) {
+		t.AppID = existing.AppID
+	}
+	if t.Key == MaskSecret(existing.Key) {
+		t.Key = existing.Key
+	}
+
+	return saveProvider(way, t, func(c *Config, t Translate) error {
+		if _, ok := c.Translate[way]; !ok {
+			return fmt.Errorf("%w: %s", ErrProviderNotFound, way)
+		}
+		c.Translate[way] = t
+		return nil
+	})
+}
+
+// DeleteProvider 删除翻译服务，不能删除当前使用的翻译服务
+func DeleteProvider(way string) error {
+	return Update(func(c *Config) error {
+		if _, ok := c.Translate[way]; !ok {
+			return fmt.Errorf("%w: %s", ErrProviderNotFound, way)
+		}
+		if c.TranslateWay == way {
+			return fmt.Errorf("%w: %s 是当前使用的翻译服务，请先切换到其他翻译服务", ErrInvalid, way)
+		}
+		delete(c.Translate, way)
+		return nil
+	})
+}
+
+// saveProvider 先在当前配置的副本上调用 apply 并校验，通过后才把 t 中的明文 appID、key 保存为密钥，
+// 再用引用替换明文写回配置；写回失败时撤销保存的密钥
+func saveProvider(way string, t Translate, apply func(c *Config, t Translate) error) error {
+	old := Get()
+	c := old.Clone()
+	if err := apply(c, t); err != nil {
+		return err
+	}
+	if err := newError(Validate(old), Validate(c)); err != nil {
+		return err
+	}
+
+	var undos []func() error
+	rollback := func(err error) error {
+		for i := len(undos) - 1; i >= 0; i-- {
+			err = errors.Join(err, undos[i]())
+		}
+		return err
+	}
+	for field, value := range map[string]*string{"appID": &t.AppID, "key": &t.Key} {
+		if *value == "" || IsSecretRef(*value) {
+			continue
+		}
+		ref, undo, err := storeSecretUndo(way+"."+field, *value)
+		if err != nil {
+			return rollback(err)
+		}
+		undos = append(undos, undo)
+		*value = ref
+	}
+
+	if err := Update(func(c *Config) error { return apply(c, t) }); err != nil {
+		return rollback(err)
+	}
+	return nil
+}
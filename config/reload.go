@@ -0,0 +1,198 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"handy-translate/explain"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// current 当前配置快照，修改时整体替换，读取方无需加锁
+	current atomic.Pointer[Config]
+
+	// loaded 最近一次加载或写入的文件内容，用于忽略自己保存引起的文件变化。由 saveMu 保护
+	loaded []byte
+
+	subscribersMu sync.Mutex
+	subscribers   []func(old, new *Config)
+)
+
+// Get 返回当前配置快照，调用方不能修改返回的配置
+func Get() *Config {
+	if c := current.Load(); c != nil {
+		return c
+	}
+	return &Config{}
+}
+
+// Update 在当前配置的副本上调用 fn，校验通过后写回配置文件、替换快照并通知订阅者。
+// fn 返回错误或修改引入了新的 error 级别问题时放弃修改
+func Update(fn func(c *Config) error) error {
+	saveMu.Lock()
+	old := Get()
+	c := old.Clone()
+	if err := fn(c); err != nil {
+		saveMu.Unlock()
+		return err
+	}
+	if err := newError(Validate(old), Validate(c)); err != nil {
+		saveMu.Unlock()
+		return err
+	}
+
+	data, err := save(c)
+	if err != nil {
+		saveMu.Unlock()
+		return err
+	}
+	store(c, data)
+	saveMu.Unlock()
+
+	notify(old, c)
+	return nil
+}
+
+// Subscribe 订阅配置变化，配置文件被修改或调用 Update 后在变化发生的 goroutine 中调用 fn
+func Subscribe(fn func(old, new *Config)) {
+	subscribersMu.Lock()
+	defer subscribersMu.Unlock()
+	subscribers = append(subscribers, fn)
+}
+
+func notify(old, new *Config) {
+	subscribersMu.Lock()
+	fns := append([]func(old, new *Config){}, subscribers...)
+	subscribersMu.Unlock()
+
+	for _, fn := range fns {
+		fn(old, new)
+	}
+}
+
+// store 替换快照并清空已解析的密钥，调用方需持有 saveMu
+func store(c *Config, data []byte) {
+	current.Store(c)
+	loaded = data
+	clearSecrets()
+}
+
+// parse 解析配置文件内容
+func parse(data []byte) (*Config, error) {
+	var c Config
+	if err := toml.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Reload 重新读取配置文件，内容有变化且校验通过时替换快照并通知订阅者；
+// 解析失败或修改引入了新的 error 级别问题时保留当前配置并返回错误，与 Update 相同，修改前已存在的问题不影响重新加载
+func Reload() error {
+	saveMu.Lock()
+	if Path == "" {
+		saveMu.Unlock()
+		return errors.New("config: not initialized")
+	}
+
+	data, err := os.ReadFile(Path)
+	if err != nil {
+		saveMu.Unlock()
+		return fmt.Errorf("读取配置 %s 失败: %w", Path, err)
+	}
+	if bytes.Equal(data, loaded) {
+		saveMu.Unlock()
+		return nil
+	}
+
+	c, err := parse(data)
+	if err == nil {
+		err = newError(Validate(Get()), Validate(c))
+	}
+	if err != nil {
+		// 记录下来，文件再次修改前不重复报错
+		loaded = data
+		saveMu.Unlock()
+		return fmt.Errorf("配置 %s 无效，继续使用修改前的配置: %w", Path, err)
+	}
+
+	old := Get()
+	store(c, data)
+	saveMu.Unlock()
+
+	notify(old, c)
+	return nil
+}
+
+// Watch 定期检查配置文件是否被修改，修改后自动重新加载，ctx 取消时退出
+func Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastMod time.Time
+	var lastSize int64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(Path)
+			if err != nil {
+				continue
+			}
+			if info.ModTime().Equal(lastMod) && info.Size() == lastSize {
+				continue
+			}
+			lastMod, lastSize = info.ModTime(), info.Size()
+
+			if err := Reload(); err != nil {
+				logrus.WithError(err).Error("重新加载配置失败")
+			}
+		}
+	}
+}
+
+// Clone 深拷贝配置
+func (c *Config) Clone() *Config {
+	clone := *c
+
+	if c.Keyboards != nil {
+		clone.Keyboards = make(map[string][]string, len(c.Keyboards))
+		for k, v := range c.Keyboards {
+			clone.Keyboards[k] = append([]string(nil), v...)
+		}
+	}
+	if c.Translate != nil {
+		clone.Translate = make(map[string]Translate, len(c.Translate))
+		for k, v := range c.Translate {
+			clone.Translate[k] = v
+		}
+	}
+	if c.Profiles != nil {
+		clone.Profiles = make(map[string]Profile, len(c.Profiles))
+		for k, v := range c.Profiles {
+			clone.Profiles[k] = v
+		}
+	}
+	if c.ExplainTemplates.Templates != nil {
+		clone.ExplainTemplates.Templates = make(map[string]ExplainTemplate, len(c.ExplainTemplates.Templates))
+		for k, v := range c.ExplainTemplates.Templates {
+			v.Examples = append([]explain.Example(nil), v.Examples...)
+			if v.Temperature != nil {
+				temperature := *v.Temperature
+				v.Temperature = &temperature
+			}
+			clone.ExplainTemplates.Templates[k] = v
+		}
+	}
+	return &clone
+}
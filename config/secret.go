@@ -0,0 +1,222 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// 翻译服务的 appID 和 key 可以写成引用，使用时再解析，避免密钥以明文保存在配置文件中：
+//
+//	key = 'env:DEEPSEEK_KEY'          # 环境变量
+//	key = 'file:/path/to/deepseek.key' # 文件内容，相对路径相对于配置文件所在目录
+//	key = 'keyring:deepseek.key'       # 系统密钥环（Secret Service）
+
+const (
+	refEnv     = "env:"
+	refFile    = "file:"
+	refKeyring = "keyring:"
+)
+
+// ErrKeyringUnavailable 系统密钥环不可用
+var ErrKeyringUnavailable = errors.New("config: keyring unavailable")
+
+// secretFields 可以保存为密钥的字段
+var secretFields = []string{"appID", "key"}
+
+// IsSecretRef 判断值是否为密钥引用
+func IsSecretRef(value string) bool {
+	return strings.HasPrefix(value, refEnv) || strings.HasPrefix(value, refFile) || strings.HasPrefix(value, refKeyring)
+}
+
+var (
+	secretMu sync.Mutex
+	// secrets 已解析的密钥引用，配置变化时清空，避免每次翻译都读取文件或密钥环
+	secrets = map[string]string{}
+)
+
+// ResolveSecret 解析密钥引用，不是引用时原样返回。解析结果缓存到配置下次变化，解析失败不缓存
+func ResolveSecret(value string) (string, error) {
+	if !IsSecretRef(value) {
+		return value, nil
+	}
+
+	secretMu.Lock()
+	secret, ok := secrets[value]
+	secretMu.Unlock()
+	if ok {
+		return secret, nil
+	}
+
+	secret, err := resolveSecret(value)
+	if err != nil {
+		return "", err
+	}
+	secretMu.Lock()
+	secrets[value] = secret
+	secretMu.Unlock()
+	return secret, nil
+}
+
+// clearSecrets 清空已解析的密钥，配置变化时调用
+func clearSecrets() {
+	secretMu.Lock()
+	defer secretMu.Unlock()
+	secrets = map[string]string{}
+}
+
+func resolveSecret(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, refEnv):
+		name := strings.TrimPrefix(value, refEnv)
+		secret, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("环境变量 %s 未设置", name)
+		}
+		return secret, nil
+	case strings.HasPrefix(value, refFile):
+		data, err := os.ReadFile(ResolvePath(strings.TrimPrefix(value, refFile)))
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	default:
+		return keyringGet(strings.TrimPrefix(value, refKeyring))
+	}
+}
+
+// secretRefName 返回引用中的环境变量名、文件路径或密钥环中的名称
+func secretRefName(value string) string {
+	for _, prefix := range []string{refEnv, refFile, refKeyring} {
+		if strings.HasPrefix(value, prefix) {
+			return strings.TrimSpace(strings.TrimPrefix(value, prefix))
+		}
+	}
+	return ""
+}
+
+// ResolveTranslate 返回解析了密钥引用的翻译服务配置
+func (c *Config) ResolveTranslate(way string) (Translate, error) {
+	t := c.Translate[way]
+
+	var err error
+	if t.AppID, err = ResolveSecret(t.AppID); err != nil {
+		return t, fmt.Errorf("translate.%s.appID: %w", way, err)
+	}
+	if t.Key, err = ResolveSecret(t.Key); err != nil {
+		return t, fmt.Errorf("translate.%s.key: %w", way, err)
+	}
+	return t, nil
+}
+
+// MaskSecret 遮盖密钥，只保留最后 4 位；引用本身不含密钥，原样返回
+func MaskSecret(value string) string {
+	if value == "" || IsSecretRef(value) {
+		return value
+	}
+	runes := []rune(value)
+	if len(runes) < 8 {
+		return "****"
+	}
+	return "****" + string(runes[len(runes)-4:])
+}
+
+// SetSecret 保存翻译服务的密钥并在配置中记录引用，密钥本身不会写入配置文件。
+// 优先保存到系统密钥环，密钥环不可用时保存到配置目录下 secrets 目录中仅当前用户可读的文件。
+// value 为空时清除该字段
+func SetSecret(way, field, value string) error {
+	valid := false
+	for _, f := range secretFields {
+		valid = valid || f == field
+	}
+	if !valid {
+		return fmt.Errorf("config: unknown secret field %q", field)
+	}
+	if _, ok := Get().Translate[way]; !ok {
+		return fmt.Errorf("%w: %s", ErrProviderNotFound, way)
+	}
+
+	ref, undo := "", func() error { return nil }
+	if value != "" {
+		var err error
+		if ref, undo, err = storeSecretUndo(way+"."+field, value); err != nil {
+			return err
+		}
+	}
+
+	err := Update(func(c *Config) error {
+		t, ok := c.Translate[way]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrProviderNotFound, way)
+		}
+		switch field {
+		case "appID":
+			t.AppID = ref
+		case "key":
+			t.Key = ref
+		}
+		c.Translate[way] = t
+		return nil
+	})
+	if err != nil {
+		return errors.Join(err, undo())
+	}
+	return nil
+}
+
+// storeSecret 保存密钥，返回引用
+func storeSecret(name, value string) (string, error) {
+	err := keyringSet(name, value)
+	if err == nil {
+		return refKeyring + name, nil
+	}
+	if !errors.Is(err, ErrKeyringUnavailable) {
+		return "", fmt.Errorf("保存到密钥环失败: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(secretFile(name)), 0700); err != nil {
+		return "", err
+	}
+	if err := writeFileAtomic(secretFile(name), []byte(value), 0600); err != nil {
+		return "", fmt.Errorf("保存密钥文件失败: %w", err)
+	}
+	return refFile + filepath.ToSlash(filepath.Join("secrets", name)), nil
+}
+
+// storeSecretUndo 与 storeSecret 相同，另外返回撤销这次保存的函数：
+// 原来保存了同名密钥时恢复原值，否则删除，配置没有保存成功时调用，避免留下无用的密钥或覆盖仍在使用的密钥
+func storeSecretUndo(name, value string) (string, func() error, error) {
+	oldKeyring, keyringErr := keyringGet(name)
+	oldFile, fileErr := os.ReadFile(secretFile(name))
+
+	ref, err := storeSecret(name, value)
+	if err != nil {
+		return "", nil, err
+	}
+	undo := func() error {
+		var err error
+		switch {
+		case strings.HasPrefix(ref, refKeyring) && keyringErr == nil:
+			err = keyringSet(name, oldKeyring)
+		case strings.HasPrefix(ref, refKeyring):
+			err = keyringDelete(name)
+		case fileErr == nil:
+			err = writeFileAtomic(secretFile(name), oldFile, 0600)
+		default:
+			err = os.Remove(secretFile(name))
+		}
+		if err != nil {
+			return fmt.Errorf("撤销保存的密钥 %s 失败: %w", name, err)
+		}
+		return nil
+	}
+	return ref, undo, nil
+}
+
+// secretFile 密钥环不可用时保存密钥的文件
+func secretFile(name string) string {
+	return filepath.Join(filepath.Dir(Path), "secrets", name)
+}
@@ -0,0 +1,165 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"handy-translate/explain"
+)
+
+var (
+	ErrTemplateExists   = errors.New("config: template already exists")
+	ErrTemplateNotFound = errors.New("config: template not found")
+	ErrInvalidTemplate  = errors.New("config: invalid template id")
+)
+
+// templateIDPattern 模板 ID 作为 TOML 的表名，只允许字母、数字、下划线和连字符
+var templateIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func checkTemplateID(id string) error {
+	if !templateIDPattern.MatchString(id) {
+		return fmt.Errorf("%w: %q，只能包含字母、数字、下划线和连字符", ErrInvalidTemplate, id)
+	}
+	return nil
+}
+
+// AddTemplate 添加解释模板
+func AddTemplate(id string, t ExplainTemplate) error {
+	if err := checkTemplateID(id); err != nil {
+		return err
+	}
+	return Update(func(c *Config) error {
+		if _, ok := c.ExplainTemplates.Templates[id]; ok {
+			return fmt.Errorf("%w: %s", ErrTemplateExists, id)
+		}
+		if c.ExplainTemplates.Templates == nil {
+			c.ExplainTemplates.Templates = map[string]ExplainTemplate{}
+		}
+		c.ExplainTemplates.Templates[id] = t
+		return nil
+	})
+}
+
+// UpdateTemplate 修改解释模板
+func UpdateTemplate(id string, t ExplainTemplate) error {
+	return Update(func(c *Config) error {
+		if _, ok := c.ExplainTemplates.Templates[id]; !ok {
+			return fmt.Errorf("%w: %s", ErrTemplateNotFound, id)
+		}
+		c.ExplainTemplates.Templates[id] = t
+		return nil
+	})
+}
+
+// DeleteTemplate 删除解释模板，不能删除默认模板
+func DeleteTemplate(id string) error {
+	return Update(func(c *Config) error {
+		if _, ok := c.ExplainTemplates.Templates[id]; !ok {
+			return fmt.Errorf("%w: %s", ErrTemplateNotFound, id)
+		}
+		if c.ExplainTemplates.DefaultTemplate == id {
+			return fmt.Errorf("%w: %s 是默认模板，请先设置其他默认模板", ErrInvalid, id)
+		}
+		delete(c.ExplainTemplates.Templates, id)
+		return nil
+	})
+}
+
+// DuplicateTemplate 复制解释模板，newID 为空时自动生成，返回新模板的 ID
+func DuplicateTemplate(id, newID string) (string, error) {
+	if newID != "" {
+		if err := checkTemplateID(newID); err != nil {
+			return "", err
+		}
+	}
+
+	err := Update(func(c *Config) error {
+		t, ok := c.ExplainTemplates.Templates[id]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrTemplateNotFound, id)
+		}
+		if newID == "" {
+			newID = uniqueTemplateID(c.ExplainTemplates.Templates, id+"_copy")
+		} else if _, ok := c.ExplainTemplates.Templates[newID]; ok {
+			return fmt.Errorf("%w: %s", ErrTemplateExists, newID)
+		}
+		t.Name += "（副本）"
+		c.ExplainTemplates.Templates[newID] = t
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return newID, nil
+}
+
+// ImportTemplates 导入模板包。ID 已存在时，overwrite 为 true 则覆盖，否则以新的 ID 导入。
+// 返回导入后的模板 ID
+func ImportTemplates(pack *explain.Pack, overwrite bool) ([]string, error) {
+	ids := make([]string, 0, len(pack.Templates))
+	for id := range pack.Templates {
+		if err := checkTemplateID(id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var imported []string
+	err := Update(func(c *Config) error {
+		imported = imported[:0]
+		if c.ExplainTemplates.Templates == nil {
+			c.ExplainTemplates.Templates = map[string]ExplainTemplate{}
+		}
+		for _, id := range ids {
+			target := id
+			if _, ok := c.ExplainTemplates.Templates[id]; ok && !overwrite {
+				target = uniqueTemplateID(c.ExplainTemplates.Templates, id)
+			}
+			c.ExplainTemplates.Templates[target] = pack.Templates[id]
+			imported = append(imported, target)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return imported, nil
+}
+
+// ExportTemplates 将指定的模板导出为模板包，ids 为空时导出全部模板
+func ExportTemplates(name string, ids []string) (*explain.Pack, error) {
+	templates := Get().ExplainTemplates.Templates
+	pack := &explain.Pack{Name: name, Templates: map[string]explain.Template{}}
+
+	if len(ids) == 0 {
+		for id, t := range templates {
+			pack.Templates[id] = t
+		}
+		return pack, nil
+	}
+
+	for _, id := range ids {
+		t, ok := templates[id]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrTemplateNotFound, id)
+		}
+		pack.Templates[id] = t
+	}
+	return pack, nil
+}
+
+// uniqueTemplateID 返回不与已有模板冲突的 ID：base、base_2、base_3……
+func uniqueTemplateID(templates map[string]ExplainTemplate, base string) string {
+	if _, ok := templates[base]; !ok {
+		return base
+	}
+	for i := 2; ; i++ {
+		id := fmt.Sprintf("%s_%d", base, i)
+		if _, ok := templates[id]; !ok {
+			return id
+		}
+	}
+}
@@ -0,0 +1,737 @@
+package config
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// 保存配置时只修改有变化的键，保留用户的注释、空行和键的顺序。
+//
+// 做法：分别解析磁盘上的原文件和 toml.Marshal 的输出，按键路径比较两者的值：
+//   - 值有变化的键原地替换值的文本，行尾注释保留
+//   - 新增的键插入到所在表的末尾，所在表不存在时追加到文件末尾
+//   - 整张表被删除（如删除翻译服务、模板）时删除该表的所有行
+//   - 配置结构能识别但新的配置中没有的键（如清空后省略的 omitempty 字段）删除该行
+//   - 原文件中有而配置结构中没有的键保持不变
+//   - 表数组（如模板的 [[...examples]]）作为一个整体比较，有变化时只重写该表数组的文本
+//
+// 遇到内联表内部的修改等无法原地处理的情况时返回 false，由调用方整体重写。
+
+// tomlEntry 文件中的一个表头或键值对
+type tomlEntry struct {
+	header    bool
+	path      []string // 表头为表路径，键值对为完整键路径
+	table     []string // 键值对所在的表
+	rawKey    string   // 键值对中 = 左侧的原文或表头 [] 中的原文
+	arrayRoot []string // 所在的最外层表数组，不在表数组中时为 nil
+	lineStart int
+	lineEnd   int // 下一行的起始位置（包含换行符）
+	valStart  int
+	valEnd    int
+}
+
+// tomlDoc 按出现顺序解析出的表头和键值对，不包括注释和空行
+type tomlDoc struct {
+	src     string
+	entries []*tomlEntry
+}
+
+// tomlEdit 对原文 [start, end) 的替换
+type tomlEdit struct {
+	start, end int
+	text       string
+}
+
+// patchTOML 将 src 修改为与 marshaled 中的配置值一致，返回修改后的文本。
+// known 为 src 中配置结构能识别的部分（src 解码为配置后再 Marshal 的结果），其中有而 marshaled 中没有的键被删除，为 nil 时不删除键
+func patchTOML(src, marshaled, known []byte) ([]byte, bool) {
+	var oldMap, newMap, knownMap map[string]any
+	if err := toml.Unmarshal(src, &oldMap); err != nil {
+		return nil, false
+	}
+	if err := toml.Unmarshal(marshaled, &newMap); err != nil {
+		return nil, false
+	}
+	if err := toml.Unmarshal(known, &knownMap); err != nil {
+		return nil, false
+	}
+
+	oldLeaves, oldTables := map[string]any{}, map[string]bool{}
+	newLeaves, newTables := map[string]any{}, map[string]bool{}
+	knownLeaves := map[string]any{}
+	if !flattenTOML(oldMap, nil, oldLeaves, oldTables) || !flattenTOML(newMap, nil, newLeaves, newTables) ||
+		!flattenTOML(knownMap, nil, knownLeaves, map[string]bool{}) {
+		return nil, false
+	}
+
+	oldDoc, ok := parseTOMLDoc(string(src))
+	if !ok {
+		return nil, false
+	}
+	newDoc, ok := parseTOMLDoc(string(marshaled))
+	if !ok {
+		return nil, false
+	}
+
+	var edits []tomlEdit
+
+	// 删除整张表
+	removed := removedTables(oldTables, newTables)
+	for _, table := range removed {
+		edits = append(edits, oldDoc.deleteTable(splitPathKey(table))...)
+	}
+
+	// 修改和新增的键，按 Marshal 输出的顺序处理，新增的键按所在表分组
+	var inserts []string // 表路径，保持首次出现的顺序
+	insertLines := map[string][]string{}
+	for _, entry := range newDoc.entries {
+		if entry.header || entry.arrayRoot != nil {
+			continue
+		}
+		key := pathKey(entry.path)
+		newValue, isLeaf := newLeaves[key]
+		if !isLeaf {
+			return nil, false
+		}
+		valueText := newDoc.src[entry.valStart:entry.valEnd]
+
+		oldValue, existed := oldLeaves[key]
+		if !existed {
+			if oldTables[key] {
+				// 类型由表变为值
+				return nil, false
+			}
+			tableKey := pathKey(entry.table)
+			if _, ok := insertLines[tableKey]; !ok {
+				inserts = append(inserts, tableKey)
+			}
+			insertLines[tableKey] = append(insertLines[tableKey], entry.rawKey+" = "+valueText)
+			continue
+		}
+		if reflect.DeepEqual(oldValue, newValue) {
+			continue
+		}
+
+		old := oldDoc.findKey(entry.path)
+		if old == nil {
+			// 值定义在内联表或点分键中
+			return nil, false
+		}
+		edits = append(edits, tomlEdit{start: old.valStart, end: old.valEnd, text: valueText})
+	}
+
+	// 删除配置中已清空的键，所在的表被整体删除或为表数组时由对应的处理删除
+	stale := staleKeys(oldLeaves, newLeaves, knownLeaves)
+	for _, key := range stale {
+		path := splitPathKey(key)
+		if underRemoved(path, removed) || len(oldDoc.arrayRegions(path)) > 0 {
+			continue
+		}
+		old := oldDoc.findKey(path)
+		if old == nil {
+			return nil, false
+		}
+		edits = append(edits, tomlEdit{start: old.lineStart, end: old.lineEnd})
+	}
+
+	// 追加到文件末尾的新表放在最后，不能插到已有表的新增键之前
+	var appended []tomlEdit
+	for _, tableKey := range inserts {
+		edit, isNew, ok := oldDoc.insertKeys(splitPathKey(tableKey), insertLines[tableKey], newDoc)
+		if !ok {
+			return nil, false
+		}
+		if isNew {
+			appended = append(appended, edit)
+		} else {
+			edits = append(edits, edit)
+		}
+	}
+	edits = append(edits, appended...)
+
+	arrayEdits, ok := oldDoc.patchArrays(newDoc, oldLeaves, newLeaves, removed)
+	if !ok {
+		return nil, false
+	}
+	edits = append(edits, arrayEdits...)
+
+	if len(edits) == 0 {
+		return src, true
+	}
+
+	patched := applyTOMLEdits(string(src), edits)
+
+	// 校验修改结果，防止写入与配置不一致或无法解析的文件
+	var patchedMap map[string]any
+	if err := toml.Unmarshal([]byte(patched), &patchedMap); err != nil {
+		return nil, false
+	}
+	patchedLeaves, patchedTables := map[string]any{}, map[string]bool{}
+	if !flattenTOML(patchedMap, nil, patchedLeaves, patchedTables) {
+		return nil, false
+	}
+	for key, value := range newLeaves {
+		if !reflect.DeepEqual(patchedLeaves[key], value) {
+			return nil, false
+		}
+	}
+	for _, table := range removed {
+		if patchedTables[table] {
+			return nil, false
+		}
+	}
+	for _, key := range stale {
+		if _, ok := patchedLeaves[key]; ok {
+			return nil, false
+		}
+	}
+	return []byte(patched), true
+}
+
+// staleKeys 原文件中配置结构能识别、新的配置中已没有的键
+func staleKeys(oldLeaves, newLeaves, knownLeaves map[string]any) []string {
+	var stale []string
+	for key := range oldLeaves {
+		_, isKnown := knownLeaves[key]
+		_, isNew := newLeaves[key]
+		if isKnown && !isNew {
+			stale = append(stale, key)
+		}
+	}
+	sort.Strings(stale)
+	return stale
+}
+
+// pathKey 将键路径拼接为 map 的键
+func pathKey(path []string) string {
+	return strings.Join(path, "\x00")
+}
+
+func splitPathKey(key string) []string {
+	if key == "" {
+		return nil
+	}
+	return strings.Split(key, "\x00")
+}
+
+// flattenTOML 展开为 键路径 -> 值，数组（包括表数组）视为值；数组中同时有表和其他值时返回 false
+func flattenTOML(m map[string]any, prefix []string, leaves map[string]any, tables map[string]bool) bool {
+	tables[pathKey(prefix)] = true
+	for k, v := range m {
+		path := append(append([]string(nil), prefix...), k)
+		switch v := v.(type) {
+		case map[string]any:
+			if !flattenTOML(v, path, leaves, tables) {
+				return false
+			}
+		case []any:
+			tableCount := 0
+			for _, item := range v {
+				if _, ok := item.(map[string]any); ok {
+					tableCount++
+				}
+			}
+			if tableCount > 0 && tableCount < len(v) {
+				return false
+			}
+			leaves[pathKey(path)] = v
+		default:
+			leaves[pathKey(path)] = v
+		}
+	}
+	return true
+}
+
+// removedTables 被删除的表，只返回最外层（父表仍存在）的表
+func removedTables(oldTables, newTables map[string]bool) []string {
+	var removed []string
+	for table := range oldTables {
+		if newTables[table] {
+			continue
+		}
+		path := splitPathKey(table)
+		if newTables[pathKey(path[:len(path)-1])] {
+			removed = append(removed, table)
+		}
+	}
+	sort.Strings(removed)
+	return removed
+}
+
+func hasPrefix(path, prefix []string) bool {
+	if len(path) < len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if path[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func equalPath(a, b []string) bool {
+	return len(a) == len(b) && hasPrefix(a, b)
+}
+
+// findKey 查找键值对，不包括表数组中的键
+func (d *tomlDoc) findKey(path []string) *tomlEntry {
+	for _, entry := range d.entries {
+		if !entry.header && entry.arrayRoot == nil && equalPath(entry.path, path) {
+			return entry
+		}
+	}
+	return nil
+}
+
+// section 表头 i 所在段落的结束位置（段落中最后一个键值对的行尾）
+func (d *tomlDoc) section(i int) int {
+	end := d.entries[i].lineEnd
+	for _, entry := range d.entries[i+1:] {
+		if entry.header {
+			break
+		}
+		end = entry.lineEnd
+	}
+	return end
+}
+
+// deleteTable 删除表及其子表的表头、键值对以及表头上方紧邻的注释
+func (d *tomlDoc) deleteTable(table []string) []tomlEdit {
+	var edits []tomlEdit
+	inDeleted := false
+	for i, entry := range d.entries {
+		if entry.header {
+			inDeleted = hasPrefix(entry.path, table)
+			if inDeleted {
+				edits = append(edits, tomlEdit{start: d.commentStart(entry.lineStart), end: d.skipBlankLines(d.section(i))})
+			}
+			continue
+		}
+		if !inDeleted && hasPrefix(entry.path, table) {
+			edits = append(edits, tomlEdit{start: entry.lineStart, end: entry.lineEnd})
+		}
+	}
+	return edits
+}
+
+// commentStart 向上包含紧邻的注释行
+func (d *tomlDoc) commentStart(lineStart int) int {
+	start := lineStart
+	for start > 0 {
+		prev := strings.LastIndexByte(d.src[:start-1], '\n') + 1
+		if !strings.HasPrefix(strings.TrimSpace(d.src[prev:start]), "#") {
+			break
+		}
+		start = prev
+	}
+	return start
+}
+
+// skipBlankLines 跳过后面的空行，避免删除表后留下多余的空行
+func (d *tomlDoc) skipBlankLines(pos int) int {
+	for pos < len(d.src) {
+		next := lineEnd(d.src, pos)
+		if strings.TrimSpace(d.src[pos:next]) != "" {
+			break
+		}
+		pos = next
+	}
+	return pos
+}
+
+// arrayRoots 文件中的最外层表数组，按首次出现的顺序
+func (d *tomlDoc) arrayRoots() [][]string {
+	var roots [][]string
+	seen := map[string]bool{}
+	for _, entry := range d.entries {
+		if entry.arrayRoot != nil && !seen[pathKey(entry.arrayRoot)] {
+			seen[pathKey(entry.arrayRoot)] = true
+			roots = append(roots, entry.arrayRoot)
+		}
+	}
+	return roots
+}
+
+// arrayRegions 表数组的所有元素（包括元素中的子表）所在的文本区间，连续的元素合并为一个区间
+func (d *tomlDoc) arrayRegions(root []string) []tomlEdit {
+	var regions []tomlEdit
+	in := false
+	for _, entry := range d.entries {
+		if entry.arrayRoot == nil || !equalPath(entry.arrayRoot, root) {
+			in = false
+			continue
+		}
+		if !in {
+			regions = append(regions, tomlEdit{start: entry.lineStart})
+			in = true
+		}
+		regions[len(regions)-1].end = entry.lineEnd
+	}
+	return regions
+}
+
+// patchArrays 比较表数组：有变化时删除原文件中该表数组的全部元素（或内联写法的键值对），
+// 在原来的位置写入 Marshal 输出中的文本；原文件中没有时插入到所在表的末尾，所在表没有表头时追加到文件末尾
+func (d *tomlDoc) patchArrays(newDoc *tomlDoc, oldLeaves, newLeaves map[string]any, removed []string) ([]tomlEdit, bool) {
+	roots := newDoc.arrayRoots()
+	seen := map[string]bool{}
+	for _, root := range roots {
+		seen[pathKey(root)] = true
+	}
+	for _, root := range d.arrayRoots() {
+		if !seen[pathKey(root)] {
+			roots = append(roots, root)
+		}
+	}
+
+	var edits, appended []tomlEdit
+	for _, root := range roots {
+		key := pathKey(root)
+		if reflect.DeepEqual(oldLeaves[key], newLeaves[key]) || underRemoved(root, removed) {
+			continue
+		}
+
+		var text string
+		if regions := newDoc.arrayRegions(root); len(regions) > 0 {
+			text = newDoc.src[regions[0].start:regions[len(regions)-1].end]
+		} else if _, ok := newLeaves[key]; ok {
+			// 新的配置中为内联写法，由逐键比较处理
+			continue
+		}
+
+		if old := d.findKey(root); old != nil {
+			edits = append(edits, tomlEdit{start: old.lineStart, end: old.lineEnd})
+		}
+		regions := d.arrayRegions(root)
+		for _, region := range regions {
+			edits = append(edits, region)
+		}
+		if text == "" {
+			continue
+		}
+
+		if len(regions) > 0 {
+			edits = append(edits, tomlEdit{start: regions[0].start, end: regions[0].start, text: text})
+			continue
+		}
+		parent := root[:len(root)-1]
+		found := false
+		for i, entry := range d.entries {
+			if entry.header && entry.arrayRoot == nil && len(parent) > 0 && equalPath(entry.path, parent) {
+				pos := d.section(i)
+				edits = append(edits, tomlEdit{start: pos, end: pos, text: d.newlineBefore(pos) + "\n" + text})
+				found = true
+				break
+			}
+		}
+		if !found {
+			pos := len(d.src)
+			appended = append(appended, tomlEdit{start: pos, end: pos, text: d.newlineBefore(pos) + "\n" + text})
+		}
+	}
+	return append(edits, appended...), true
+}
+
+// underRemoved 路径是否位于被删除的表中
+func underRemoved(path []string, removed []string) bool {
+	for _, table := range removed {
+		if hasPrefix(path, splitPathKey(table)) {
+			return true
+		}
+	}
+	return false
+}
+
+// insertKeys 在表的末尾插入键值对，表不存在时在文件末尾新建表（isNew 为 true）
+func (d *tomlDoc) insertKeys(table []string, lines []string, newDoc *tomlDoc) (edit tomlEdit, isNew bool, ok bool) {
+	text := strings.Join(lines, "\n") + "\n"
+
+	if len(table) == 0 {
+		pos := 0
+		for _, entry := range d.entries {
+			if entry.header {
+				break
+			}
+			pos = entry.lineEnd
+		}
+		return tomlEdit{start: pos, end: pos, text: d.newlineBefore(pos) + text}, false, true
+	}
+
+	for i, entry := range d.entries {
+		if entry.header && equalPath(entry.path, table) {
+			pos := d.section(i)
+			return tomlEdit{start: pos, end: pos, text: d.newlineBefore(pos) + text}, false, true
+		}
+	}
+
+	for _, entry := range newDoc.entries {
+		if entry.header && equalPath(entry.path, table) {
+			pos := len(d.src)
+			prefix := d.newlineBefore(pos)
+			if pos > 0 {
+				prefix += "\n"
+			}
+			return tomlEdit{start: pos, end: pos, text: prefix + "[" + entry.rawKey + "]\n" + text}, true, true
+		}
+	}
+	return tomlEdit{}, false, false
+}
+
+// newlineBefore 插入位置不在行首时需要先换行
+func (d *tomlDoc) newlineBefore(pos int) string {
+	if pos > 0 && d.src[pos-1] != '\n' {
+		return "\n"
+	}
+	return ""
+}
+
+// applyTOMLEdits 按原文位置依次应用修改；位置相同时先插入后替换，插入按加入的顺序
+func applyTOMLEdits(src string, edits []tomlEdit) string {
+	sort.SliceStable(edits, func(i, j int) bool {
+		if edits[i].start != edits[j].start {
+			return edits[i].start < edits[j].start
+		}
+		return edits[i].start == edits[i].end && edits[j].start != edits[j].end
+	})
+
+	var b strings.Builder
+	cursor := 0
+	for _, edit := range edits {
+		if edit.start > cursor {
+			b.WriteString(src[cursor:edit.start])
+		}
+		b.WriteString(edit.text)
+		cursor = max(cursor, edit.end)
+	}
+	b.WriteString(src[cursor:])
+	return b.String()
+}
+
+// parseTOMLDoc 解析表头和键值对的位置，只识别本文件需要的语法，不做完整校验（由 toml.Unmarshal 负责）
+func parseTOMLDoc(src string) (*tomlDoc, bool) {
+	doc := &tomlDoc{src: src}
+	var table, arrayRoot []string
+
+	pos := 0
+	for pos < len(src) {
+		lineStart := pos
+		pos = skipSpace(src, pos)
+		if pos >= len(src) {
+			break
+		}
+
+		switch c := src[pos]; {
+		case c == '\n' || c == '\r' || c == '#':
+			pos = lineEnd(src, pos)
+		case c == '[':
+			open, closing := "[", "]"
+			if strings.HasPrefix(src[pos:], "[[") {
+				open, closing = "[[", "]]"
+			}
+			keyStart := skipSpace(src, pos+len(open))
+			path, next, ok := parseTOMLKey(src, keyStart)
+			if !ok {
+				return nil, false
+			}
+			rawKey := strings.TrimSpace(src[keyStart:next])
+			next = skipSpace(src, next)
+			if !strings.HasPrefix(src[next:], closing) {
+				return nil, false
+			}
+			pos = lineEnd(src, next+len(closing))
+			table = path
+
+			// 表数组元素中的子表和嵌套的表数组属于最外层的表数组
+			nested := arrayRoot != nil && len(path) > len(arrayRoot) && hasPrefix(path, arrayRoot)
+			switch {
+			case nested:
+			case closing == "]]":
+				arrayRoot = path
+			default:
+				arrayRoot = nil
+			}
+			doc.entries = append(doc.entries, &tomlEntry{
+				header:    true,
+				path:      path,
+				rawKey:    rawKey,
+				arrayRoot: arrayRoot,
+				lineStart: lineStart,
+				lineEnd:   pos,
+			})
+		default:
+			keyStart := pos
+			key, next, ok := parseTOMLKey(src, pos)
+			if !ok {
+				return nil, false
+			}
+			rawKey := strings.TrimSpace(src[keyStart:next])
+			next = skipSpace(src, next)
+			if next >= len(src) || src[next] != '=' {
+				return nil, false
+			}
+			valStart := skipSpace(src, next+1)
+			valEnd, ok := scanTOMLValue(src, valStart)
+			if !ok {
+				return nil, false
+			}
+			pos = lineEnd(src, valEnd)
+			doc.entries = append(doc.entries, &tomlEntry{
+				path:      append(append([]string(nil), table...), key...),
+				table:     table,
+				rawKey:    rawKey,
+				arrayRoot: arrayRoot,
+				lineStart: lineStart,
+				lineEnd:   pos,
+				valStart:  valStart,
+				valEnd:    valEnd,
+			})
+		}
+	}
+	return doc, true
+}
+
+func skipSpace(src string, pos int) int {
+	for pos < len(src) && (src[pos] == ' ' || src[pos] == '\t') {
+		pos++
+	}
+	return pos
+}
+
+// lineEnd 返回下一行的起始位置
+func lineEnd(src string, pos int) int {
+	if i := strings.IndexByte(src[pos:], '\n'); i >= 0 {
+		return pos + i + 1
+	}
+	return len(src)
+}
+
+// parseTOMLKey 解析点分键，支持裸键和引号键
+func parseTOMLKey(src string, pos int) ([]string, int, bool) {
+	var path []string
+	for {
+		pos = skipSpace(src, pos)
+		if pos >= len(src) {
+			return nil, 0, false
+		}
+
+		switch src[pos] {
+		case '"', '\'':
+			end, ok := scanTOMLString(src, pos)
+			if !ok {
+				return nil, 0, false
+			}
+			var m map[string]string
+			if err := toml.Unmarshal([]byte("k = "+src[pos:end]), &m); err != nil {
+				return nil, 0, false
+			}
+			path = append(path, m["k"])
+			pos = end
+		default:
+			start := pos
+			for pos < len(src) && isBareKeyChar(src[pos]) {
+				pos++
+			}
+			if pos == start {
+				return nil, 0, false
+			}
+			path = append(path, src[start:pos])
+		}
+
+		next := skipSpace(src, pos)
+		if next < len(src) && src[next] == '.' {
+			pos = next + 1
+			continue
+		}
+		return path, pos, true
+	}
+}
+
+func isBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// scanTOMLValue 返回值的结束位置，值可以跨多行（多行字符串、数组、内联表）
+func scanTOMLValue(src string, pos int) (int, bool) {
+	if pos >= len(src) {
+		return 0, false
+	}
+
+	switch src[pos] {
+	case '"', '\'':
+		return scanTOMLString(src, pos)
+	case '[', '{':
+		depth := 0
+		for pos < len(src) {
+			switch c := src[pos]; c {
+			case '[', '{':
+				depth++
+				pos++
+			case ']', '}':
+				depth--
+				pos++
+				if depth == 0 {
+					return pos, true
+				}
+			case '"', '\'':
+				end, ok := scanTOMLString(src, pos)
+				if !ok {
+					return 0, false
+				}
+				pos = end
+			case '#':
+				pos = lineEnd(src, pos)
+			default:
+				pos++
+			}
+		}
+		return 0, false
+	default:
+		end := pos
+		for end < len(src) && !strings.ContainsRune(" \t\r\n#,]}", rune(src[end])) {
+			end++
+		}
+		return end, end > pos
+	}
+}
+
+// scanTOMLString 返回字符串（含引号）的结束位置
+func scanTOMLString(src string, pos int) (int, bool) {
+	quote := src[pos]
+	if strings.HasPrefix(src[pos:], strings.Repeat(string(quote), 3)) {
+		delim := strings.Repeat(string(quote), 3)
+		i := pos + 3
+		for i < len(src) {
+			if quote == '"' && src[i] == '\\' {
+				i += 2
+				continue
+			}
+			if strings.HasPrefix(src[i:], delim) {
+				end := i + 3
+				// 结束符前最多可以再有两个引号
+				for n := 0; n < 2 && end < len(src) && src[end] == quote; n++ {
+					end++
+				}
+				return end, true
+			}
+			i++
+		}
+		return 0, false
+	}
+
+	i := pos + 1
+	for i < len(src) && src[i] != '\n' {
+		if quote == '"' && src[i] == '\\' {
+			i += 2
+			continue
+		}
+		if src[i] == quote {
+			return i + 1, true
+		}
+		i++
+	}
+	return 0, false
+}
@@ -0,0 +1,248 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"handy-translate/display"
+	"handy-translate/explain"
+)
+
+// 诊断级别：error 表示配置无法使用，重新加载时会保留修改前的配置；warning 表示部分功能无法正常工作
+const (
+	LevelError   = "error"
+	LevelWarning = "warning"
+)
+
+// ErrInvalid 修改后的配置未通过校验
+var ErrInvalid = errors.New("config: invalid")
+
+// Diagnostic 配置校验发现的一个问题
+type Diagnostic struct {
+	Level   string `json:"level"`
+	Field   string `json:"field"` // 配置项路径，例如 "translate.baidu.key"
+	Message string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("[%s] %s: %s", d.Level, d.Field, d.Message)
+}
+
+// requiredFields 各翻译服务必须填写的字段
+var requiredFields = map[string][]string{
+	"baidu":    {"appID", "key"},
+	"youdao":   {"appID", "key"},
+	"caiyun":   {"key"},
+	"deepseek": {"key"},
+}
+
+// placeholders 默认配置中的占位值
+var placeholders = []string{"密钥", "APP ID", "应用ID", "应用密钥"}
+
+// Validate 校验配置，返回按级别和配置项排序的问题列表，没有问题时返回空
+func Validate(c *Config) []Diagnostic {
+	var diags []Diagnostic
+	add := func(level, field, format string, args ...any) {
+		diags = append(diags, Diagnostic{Level: level, Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if c.TranslateWay == "" {
+		add(LevelError, "translate_way", "未设置当前翻译服务")
+	} else if _, ok := c.Translate[c.TranslateWay]; !ok {
+		add(LevelError, "translate_way", "翻译服务 %q 未在 [translate] 中定义", c.TranslateWay)
+	}
+
+	for way, provider := range c.Translate {
+		values := map[string]string{"appID": provider.AppID, "key": provider.Key}
+		for _, field := range requiredFields[way] {
+			value := strings.TrimSpace(values[field])
+			path := "translate." + way + "." + field
+			if IsSecretRef(value) {
+				// 只检查引用的格式：校验在保存配置时进行，读取系统密钥环可能要等待用户解锁，读取失败在使用时报告
+				if secretRefName(value) == "" {
+					add(LevelWarning, path, "引用 %q 缺少名称", value)
+				}
+				continue
+			}
+			switch {
+			case value == "":
+				add(LevelWarning, path, "未填写，%s无法使用", providerName(way, provider))
+			case isPlaceholder(value):
+				add(LevelWarning, path, "仍是占位值 %q，请填写%s的真实信息", value, providerName(way, provider))
+			}
+		}
+	}
+
+	templates := c.ExplainTemplates
+	if templates.DefaultTemplate != "" {
+		if _, ok := templates.Templates[templates.DefaultTemplate]; !ok {
+			add(LevelError, "explain_templates.default_template", "模板 %q 不存在", templates.DefaultTemplate)
+		}
+	}
+	for id, tpl := range templates.Templates {
+		prefix := "explain_templates.templates." + id + "."
+		usesText, err := explain.Check(tpl.Template)
+		switch {
+		case err != nil:
+			add(LevelError, prefix+"template", "模板无法渲染: %v", err)
+		case !usesText:
+			add(LevelWarning, prefix+"template", "模板没有使用 {{.text}}，选中的文本不会发送给模型")
+		}
+		if _, err := explain.Check(tpl.System); err != nil {
+			add(LevelError, prefix+"system", "系统提示词无法渲染: %v", err)
+		}
+		for i, example := range tpl.Examples {
+			if example.Input == "" || example.Output == "" {
+				add(LevelWarning, fmt.Sprintf("%sexamples[%d]", prefix, i), "示例的 input 和 output 不能为空")
+			}
+		}
+		if tpl.Temperature != nil && (*tpl.Temperature < 0 || *tpl.Temperature > 2) {
+			add(LevelError, prefix+"temperature", "应在 0 到 2 之间，实际为 %v", *tpl.Temperature)
+		}
+		if tpl.MaxTokens < 0 {
+			add(LevelError, prefix+"max_tokens", "不能为负数，实际为 %d", tpl.MaxTokens)
+		}
+	}
+
+	if c.Profile != "" {
+		if _, ok := c.Profiles[c.Profile]; !ok {
+			add(LevelError, "profile", "配置方案 %q 不存在", c.Profile)
+		}
+	}
+	for id, p := range c.Profiles {
+		prefix := "profiles." + id + "."
+		if p.TranslateWay != "" {
+			if _, ok := c.Translate[p.TranslateWay]; !ok {
+				add(LevelError, prefix+"translate_way", "翻译服务 %q 未在 [translate] 中定义", p.TranslateWay)
+			}
+		}
+		if p.Mode != "" && p.Mode != ModeTranslate && p.Mode != ModeExplain {
+			add(LevelError, prefix+"mode", "应为 %s 或 %s，实际为 %q", ModeTranslate, ModeExplain, p.Mode)
+		}
+		if p.Template != "" {
+			if _, ok := templates.Templates[p.Template]; !ok {
+				add(LevelError, prefix+"template", "模板 %q 不存在", p.Template)
+			}
+		}
+	}
+
+	if c.History.Enabled {
+		if c.History.StoragePath == "" {
+			add(LevelError, "history.storage_path", "开启历史记录时不能为空")
+		} else if err := checkWritable(ResolvePath(c.History.StoragePath)); err != nil {
+			add(LevelWarning, "history.storage_path", "目录不可写，历史记录无法保存: %v", err)
+		}
+	}
+
+	if c.Sync.Enabled && c.Sync.Type != "webdav" && c.Sync.Type != "s3" {
+		add(LevelError, "sync.type", "应为 webdav 或 s3，实际为 %q", c.Sync.Type)
+	}
+
+	if c.OCR.Timeout < 0 {
+		add(LevelError, "ocr.timeout", "不能为负数，实际为 %d", c.OCR.Timeout)
+	}
+	if c.OCR.MinScore < 0 || c.OCR.MinScore > 1 {
+		add(LevelError, "ocr.min_score", "应在 0 到 1 之间，实际为 %v", c.OCR.MinScore)
+	}
+	if c.OCR.Preprocess.MinHeight < 0 {
+		add(LevelError, "ocr.preprocess.min_height", "不能为负数，实际为 %d", c.OCR.Preprocess.MinHeight)
+	}
+	if c.OCR.Preprocess.Contrast < 0 {
+		add(LevelError, "ocr.preprocess.contrast", "不能为负数，实际为 %v", c.OCR.Preprocess.Contrast)
+	}
+	if dir := c.OCR.Preprocess.DebugDir; dir != "" {
+		if err := checkWritable(ResolvePath(dir)); err != nil {
+			add(LevelWarning, "ocr.preprocess.debug_dir", "目录不可写，调试图片无法保存: %v", err)
+		}
+	}
+
+	if area := c.Screenshot.Area; area != "" && area != display.AreaCursor && area != display.AreaAll {
+		add(LevelError, "screenshot.area", "应为 %s 或 %s，实际为 %q", display.AreaCursor, display.AreaAll, area)
+	}
+	if dir := c.Screenshot.SaveDir; dir != "" {
+		if err := checkWritable(ResolvePath(dir)); err != nil {
+			add(LevelWarning, "screenshot.save_dir", "目录不可写，截图无法保存: %v", err)
+		}
+	}
+
+	sort.Slice(diags, func(i, j int) bool {
+		if diags[i].Level != diags[j].Level {
+			return diags[i].Level == LevelError
+		}
+		if diags[i].Field != diags[j].Field {
+			return diags[i].Field < diags[j].Field
+		}
+		return diags[i].Message < diags[j].Message
+	})
+	return diags
+}
+
+// Diagnostics 校验当前配置
+func Diagnostics() []Diagnostic {
+	return Validate(Get())
+}
+
+// firstError 返回第一个 error 级别的问题
+func firstError(diags []Diagnostic) error {
+	for _, d := range diags {
+		if d.Level == LevelError {
+			return fmt.Errorf("%s: %s", d.Field, d.Message)
+		}
+	}
+	return nil
+}
+
+// newError 返回 after 中有而 before 中没有的第一个 error 级别问题，修改前已存在的问题不影响修改
+func newError(before, after []Diagnostic) error {
+	existing := map[Diagnostic]bool{}
+	for _, d := range before {
+		existing[d] = true
+	}
+	for _, d := range after {
+		if d.Level == LevelError && !existing[d] {
+			return fmt.Errorf("%w: %s: %s", ErrInvalid, d.Field, d.Message)
+		}
+	}
+	return nil
+}
+
+func isPlaceholder(value string) bool {
+	for _, p := range placeholders {
+		if value == p {
+			return true
+		}
+	}
+	return false
+}
+
+func providerName(way string, provider Translate) string {
+	if provider.Name != "" {
+		return provider.Name
+	}
+	return way
+}
+
+// checkWritable 检查目录可写，不修改文件系统：目录不存在时检查最近的已存在的上级目录，目录在使用时才创建
+func checkWritable(dir string) error {
+	for p := filepath.Clean(dir); ; {
+		info, err := os.Stat(p)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("%s 不是目录", p)
+			}
+			return canWrite(p, info)
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+		parent := filepath.Dir(p)
+		if parent == p {
+			return err
+		}
+		p = parent
+	}
+}
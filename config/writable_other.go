@@ -0,0 +1,19 @@
+//go:build !windows
+
+package config
+
+import (
+	"os"
+	"syscall"
+)
+
+// wOK access(2) 的 W_OK
+const wOK = 0x2
+
+// canWrite 按当前用户的权限检查目录是否可写
+func canWrite(dir string, _ os.FileInfo) error {
+	if err := syscall.Access(dir, wOK); err != nil {
+		return &os.PathError{Op: "access", Path: dir, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+package config
+
+import (
+	"fmt"
+	"os"
+)
+
+// canWrite Windows 上只检查只读属性，ACL 导致的无法写入在使用时报错
+func canWrite(dir string, info os.FileInfo) error {
+	if info.Mode().Perm()&0200 == 0 {
+		return fmt.Errorf("%s 是只读目录", dir)
+	}
+	return nil
+}
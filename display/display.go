@@ -0,0 +1,125 @@
+// Package display 多显示器布局和坐标换算。截图使用虚拟屏幕中的物理像素，
+// 截图窗口中的选区为 CSS 像素，两者之间按显示器的缩放比例换算
+package display
+
+import (
+	"image"
+	"math"
+)
+
+// 截图范围
+const (
+	AreaCursor = "cursor" // 鼠标所在的显示器
+	AreaAll    = "all"    // 所有显示器拼接为一张图
+)
+
+// Display 一个显示器
+type Display struct {
+	Bounds      image.Rectangle // 虚拟屏幕中的物理像素范围，主显示器左上角为原点，其他显示器的坐标可能为负
+	ScaleFactor float64         // 缩放比例（物理像素 / CSS 像素），即 DPI/96
+}
+
+// Layout 所有显示器
+type Layout []Display
+
+// At 返回包含 p 的显示器，p 不在任何显示器中时返回距离最近的显示器
+func (l Layout) At(p image.Point) Display {
+	best, bestDist := Display{}, math.MaxInt
+	for _, d := range l {
+		if p.In(d.Bounds) {
+			return d
+		}
+		if dist := distance(p, d.Bounds); dist < bestDist {
+			best, bestDist = d, dist
+		}
+	}
+	return best
+}
+
+// Union 所有显示器的外接矩形
+func (l Layout) Union() image.Rectangle {
+	var r image.Rectangle
+	for _, d := range l {
+		r = r.Union(d.Bounds)
+	}
+	return r
+}
+
+// UniformScale 所有显示器的缩放比例是否相同
+func (l Layout) UniformScale() bool {
+	for _, d := range l {
+		if scaleOf(d) != scaleOf(l[0]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Region 截图范围：AreaAll 为所有显示器的外接矩形；其他情况为鼠标所在的显示器。
+// 截图窗口中只有一个缩放比例，各显示器的缩放比例不同时 AreaAll 也只截取鼠标所在的显示器
+func (l Layout) Region(area string, cursor image.Point) Transform {
+	d := l.At(cursor)
+	bounds := d.Bounds
+	if area == AreaAll && l.UniformScale() {
+		bounds = l.Union()
+	}
+	return Transform{Origin: bounds.Min, Size: bounds.Size(), Scale: scaleOf(d)}
+}
+
+// scaleOf 显示器的缩放比例，无效时为 1
+func scaleOf(d Display) float64 {
+	if d.ScaleFactor <= 0 {
+		return 1
+	}
+	return d.ScaleFactor
+}
+
+// distance p 到矩形的距离的平方
+func distance(p image.Point, r image.Rectangle) int {
+	dx := max(r.Min.X-p.X, 0, p.X-(r.Max.X-1))
+	dy := max(r.Min.Y-p.Y, 0, p.Y-(r.Max.Y-1))
+	return dx*dx + dy*dy
+}
+
+// Transform 截图图片与截图窗口、虚拟屏幕之间的坐标换算。截图图片的原点为 (0, 0)，
+// 对应虚拟屏幕中的 Origin；截图窗口覆盖截图范围，其中 1 个 CSS 像素对应 Scale 个物理像素
+type Transform struct {
+	Origin image.Point `json:"origin"` // 截图左上角在虚拟屏幕中的物理坐标
+	Size   image.Point `json:"size"`   // 截图的物理像素大小
+	Scale  float64     `json:"scale"`  // 物理像素 / CSS 像素
+}
+
+// Bounds 截图在虚拟屏幕中的物理像素范围
+func (t Transform) Bounds() image.Rectangle {
+	return image.Rectangle{Min: t.Origin, Max: t.Origin.Add(t.Size)}
+}
+
+// FromCSS 将截图窗口中的选区（CSS 像素，两个角的顺序任意）换算为截图图片中的像素范围，
+// 向外取整并限制在截图范围内
+func (t Transform) FromCSS(x0, y0, x1, y1 float64) image.Rectangle {
+	scale := t.Scale
+	if scale <= 0 {
+		scale = 1
+	}
+	r := image.Rect(
+		int(math.Floor(math.Min(x0, x1)*scale)),
+		int(math.Floor(math.Min(y0, y1)*scale)),
+		int(math.Ceil(math.Max(x0, x1)*scale)),
+		int(math.Ceil(math.Max(y0, y1)*scale)),
+	)
+	return r.Intersect(image.Rectangle{Max: t.Size})
+}
+
+// ToCSS 将截图图片中的坐标换算为截图窗口中的 CSS 像素
+func (t Transform) ToCSS(p image.Point) (x, y float64) {
+	scale := t.Scale
+	if scale <= 0 {
+		scale = 1
+	}
+	return float64(p.X) / scale, float64(p.Y) / scale
+}
+
+// ToScreen 将截图图片中的坐标换算为虚拟屏幕中的物理坐标
+func (t Transform) ToScreen(p image.Point) image.Point {
+	return p.Add(t.Origin)
+}
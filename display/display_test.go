@@ -0,0 +1,66 @@
+package display
+
+import (
+	"image"
+	"testing"
+)
+
+// 主显示器 1920x1080（100%），左侧为 2560x1440（150%）的显示器，底边对齐，原点为负
+var layout = Layout{
+	{Bounds: image.Rect(0, 0, 1920, 1080), ScaleFactor: 1},
+	{Bounds: image.Rect(-2560, -360, 0, 1080), ScaleFactor: 1.5},
+}
+
+func TestLayout(t *testing.T) {
+	if d := layout.At(image.Pt(100, 100)); d.ScaleFactor != 1 {
+		t.Errorf("主显示器中的点应返回主显示器，实际 %+v", d)
+	}
+	if d := layout.At(image.Pt(-10, -300)); d.ScaleFactor != 1.5 {
+		t.Errorf("负坐标的点应返回左侧显示器，实际 %+v", d)
+	}
+	if d := layout.At(image.Pt(2500, 500)); d.ScaleFactor != 1 {
+		t.Errorf("不在任何显示器中的点应返回最近的显示器，实际 %+v", d)
+	}
+	if got, want := layout.Union(), image.Rect(-2560, -360, 1920, 1080); got != want {
+		t.Errorf("Union() = %v，期望 %v", got, want)
+	}
+
+	tr := layout.Region(AreaCursor, image.Pt(-100, 0))
+	if tr.Bounds() != image.Rect(-2560, -360, 0, 1080) || tr.Scale != 1.5 {
+		t.Errorf("鼠标所在显示器的截图范围错误: %+v", tr)
+	}
+	if layout.UniformScale() {
+		t.Error("缩放比例不同的显示器不应视为相同")
+	}
+	tr = layout.Region(AreaAll, image.Pt(100, 100))
+	if tr.Bounds() != image.Rect(0, 0, 1920, 1080) || tr.Scale != 1 {
+		t.Errorf("缩放比例不同时应只截取鼠标所在的显示器，实际 %+v", tr)
+	}
+
+	// 缩放比例相同时截取所有显示器的外接矩形
+	uniform := Layout{
+		{Bounds: image.Rect(0, 0, 2880, 1620), ScaleFactor: 1.5},
+		{Bounds: image.Rect(-2560, -360, 0, 1080), ScaleFactor: 1.5},
+	}
+	tr = uniform.Region(AreaAll, image.Pt(100, 100))
+	if tr.Bounds() != image.Rect(-2560, -360, 2880, 1620) || tr.Scale != 1.5 {
+		t.Errorf("所有显示器的截图范围错误: %+v", tr)
+	}
+}
+
+func TestTransform(t *testing.T) {
+	tr := Transform{Origin: image.Pt(-2560, -360), Size: image.Pt(2560, 1440), Scale: 1.5}
+
+	if got, want := tr.FromCSS(110.5, 20, 10, 60.2), image.Rect(15, 30, 166, 91); got != want {
+		t.Errorf("FromCSS() = %v，期望 %v", got, want)
+	}
+	if got := tr.FromCSS(-10, -10, 2000, 2000); got != image.Rect(0, 0, 2560, 1440) {
+		t.Errorf("超出截图的选区应限制在截图范围内，实际 %v", got)
+	}
+	if x, y := tr.ToCSS(image.Pt(150, 30)); x != 100 || y != 20 {
+		t.Errorf("ToCSS() = (%v, %v)", x, y)
+	}
+	if got := tr.ToScreen(image.Pt(10, 10)); got != image.Pt(-2550, -350) {
+		t.Errorf("ToScreen() = %v", got)
+	}
+}
@@ -0,0 +1,153 @@
+// Package explain 解释模板的渲染、校验以及模板包的导入导出
+package explain
+
+import (
+	"strings"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/prompts"
+)
+
+// Template 解释模板。System 和 Template 为 Go 模板，可以使用 Vars 中的变量；
+// Examples 为少样本示例，按顺序作为对话历史发送给模型，不经过模板渲染
+type Template struct {
+	Name        string    `toml:"name" json:"name"`
+	Description string    `toml:"description" json:"description"`
+	System      string    `toml:"system,omitempty" json:"system,omitempty"`
+	Template    string    `toml:"template" json:"template"`
+	Examples    []Example `toml:"examples,omitempty" json:"examples,omitempty"`
+	Temperature *float64  `toml:"temperature,omitempty" json:"temperature,omitempty"` // 未设置时使用模型默认值
+	MaxTokens   int       `toml:"max_tokens,omitempty" json:"max_tokens,omitempty"`   // 0 表示使用模型默认值
+}
+
+// Example 少样本示例
+type Example struct {
+	Input  string `toml:"input" json:"input"`
+	Output string `toml:"output" json:"output"`
+}
+
+// Vars 模板变量
+type Vars struct {
+	Text    string // {{.text}} 选中的文本
+	Context string // {{.context}} 选中文本前后的内容，没有时为空
+	From    string // {{.from}} 源语言
+	To      string // {{.to}} 目标语言
+	App     string // {{.app}} 选中文本所在的应用（前台窗口标题）
+	Date    string // {{.date}} 当前日期，为空时使用当天日期
+}
+
+// SampleText 预览模板时默认使用的示例文本
+const SampleText = "goroutine"
+
+// SampleVars 预览模板时使用的示例变量
+func SampleVars(text string) Vars {
+	if text == "" {
+		text = SampleText
+	}
+	return Vars{
+		Text:    text,
+		Context: "Each " + text + " has its own stack, which grows and shrinks as needed.",
+		From:    "auto",
+		To:      "zh",
+		App:     "Visual Studio Code",
+	}
+}
+
+// textSentinel 用于检查模板是否引用了 {{.text}}
+const textSentinel = "\x00text\x00"
+
+func (v Vars) values() map[string]any {
+	date := v.Date
+	if date == "" {
+		date = time.Now().Format(time.DateOnly)
+	}
+	return map[string]any{
+		"text":    v.Text,
+		"context": v.Context,
+		"from":    v.From,
+		"to":      v.To,
+		"app":     v.App,
+		"date":    date,
+	}
+}
+
+// Render 渲染模板，与请求模型时的渲染方式（langchaingo Go 模板）一致
+func Render(tpl string, vars Vars) (string, error) {
+	return prompts.RenderTemplate(tpl, prompts.TemplateFormatGoTemplate, vars.values())
+}
+
+// Check 检查模板能否渲染，以及是否引用了 {{.text}}
+func Check(tpl string) (usesText bool, err error) {
+	vars := SampleVars(textSentinel)
+	out, err := Render(tpl, vars)
+	if err != nil {
+		return false, err
+	}
+	return strings.Contains(out, textSentinel), nil
+}
+
+// Messages 构建发送给模型的对话：系统提示词、少样本示例，最后是渲染后的模板
+func (t Template) Messages(vars Vars) ([]llms.MessageContent, error) {
+	var messages []llms.MessageContent
+
+	if t.System != "" {
+		system, err := Render(t.System, vars)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, llms.TextParts(llms.ChatMessageTypeSystem, system))
+	}
+
+	for _, example := range t.Examples {
+		messages = append(messages,
+			llms.TextParts(llms.ChatMessageTypeHuman, example.Input),
+			llms.TextParts(llms.ChatMessageTypeAI, example.Output),
+		)
+	}
+
+	prompt, err := Render(t.Template, vars)
+	if err != nil {
+		return nil, err
+	}
+	messages = append(messages, llms.TextParts(llms.ChatMessageTypeHuman, prompt))
+	return messages, nil
+}
+
+// CallOptions 模板中设置的模型参数
+func (t Template) CallOptions() []llms.CallOption {
+	var opts []llms.CallOption
+	if t.Temperature != nil {
+		opts = append(opts, llms.WithTemperature(*t.Temperature))
+	}
+	if t.MaxTokens > 0 {
+		opts = append(opts, llms.WithMaxTokens(t.MaxTokens))
+	}
+	return opts
+}
+
+// Message 预览时返回的对话消息
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Preview 返回将要发送给模型的对话消息，用于预览模板，不会请求模型
+func (t Template) Preview(vars Vars) ([]Message, error) {
+	messages, err := t.Messages(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := make([]Message, 0, len(messages))
+	for _, m := range messages {
+		var content strings.Builder
+		for _, part := range m.Parts {
+			if text, ok := part.(llms.TextContent); ok {
+				content.WriteString(text.Text)
+			}
+		}
+		preview = append(preview, Message{Role: string(m.Role), Content: content.String()})
+	}
+	return preview, nil
+}
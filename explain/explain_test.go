@@ -0,0 +1,102 @@
+package explain
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	vars := Vars{Text: "goroutine", Context: "Each goroutine", From: "en", To: "zh", App: "VS Code", Date: "2024-01-02"}
+	out, err := Render("{{.text}}|{{.context}}|{{.from}}|{{.to}}|{{.app}}|{{.date}}", vars)
+	if err != nil || out != "goroutine|Each goroutine|en|zh|VS Code|2024-01-02" {
+		t.Errorf("渲染结果错误: %q %v", out, err)
+	}
+	if out, _ := Render("{{.date}}", Vars{}); out == "" {
+		t.Error("未设置日期时应使用当天日期")
+	}
+	if _, err := Render("{{.missing}}", vars); err == nil {
+		t.Error("引用未定义的变量应返回错误")
+	}
+}
+
+func TestMessages(t *testing.T) {
+	temperature := 0.0
+	tpl := Template{
+		System:      "你是{{.to}}术语专家",
+		Template:    "解释：{{.text}}",
+		Examples:    []Example{{Input: "解释：CPU", Output: "中央处理器"}},
+		Temperature: &temperature,
+		MaxTokens:   256,
+	}
+
+	messages, err := tpl.Preview(Vars{Text: "GPU", To: "zh"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Message{
+		{Role: "system", Content: "你是zh术语专家"},
+		{Role: "human", Content: "解释：CPU"},
+		{Role: "ai", Content: "中央处理器"},
+		{Role: "human", Content: "解释：GPU"},
+	}
+	if !reflect.DeepEqual(messages, want) {
+		t.Errorf("对话消息错误:\n got %+v\nwant %+v", messages, want)
+	}
+
+	if got := len(tpl.CallOptions()); got != 2 {
+		t.Errorf("应设置 2 个模型参数，实际 %d", got)
+	}
+	if got := len((Template{}).CallOptions()); got != 0 {
+		t.Errorf("未设置模型参数时不应有选项，实际 %d", got)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	cases := []struct {
+		tpl      string
+		usesText bool
+		wantErr  bool
+	}{
+		{"解释 {{.text}}", true, false},
+		{"{{ .text | trim }}", true, false},
+		{"固定内容", false, false},
+		{"{{.app}} {{.date}} {{.text}}", true, false},
+		{"{{.text", false, true},
+	}
+	for _, c := range cases {
+		usesText, err := Check(c.tpl)
+		if (err != nil) != c.wantErr || usesText != c.usesText {
+			t.Errorf("Check(%q) = %v, %v", c.tpl, usesText, err)
+		}
+	}
+}
+
+func TestPackRoundTrip(t *testing.T) {
+	pack := &Pack{
+		Name: "示例",
+		Templates: map[string]Template{
+			"short": {Name: "简短", Description: "一句话", Template: "简短解释：{{.text}}", Examples: []Example{{Input: "CPU", Output: "中央处理器"}}},
+		},
+	}
+
+	dir := t.TempDir()
+	for _, name := range []string{"pack.toml", "pack.json"} {
+		path := filepath.Join(dir, name)
+		if err := WritePack(path, pack); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ReadPack(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Name != pack.Name || !reflect.DeepEqual(got.Templates, pack.Templates) {
+			t.Errorf("%s 读回的模板包不一致: %+v", name, got)
+		}
+	}
+
+	if err := WritePack(filepath.Join(dir, "pack.yaml"), pack); err == nil || !strings.Contains(err.Error(), "unsupported") {
+		t.Errorf("不支持的格式应返回错误，实际 %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package explain
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// Pack 模板包，可以导出为独立的 TOML 或 JSON 文件分享给他人
+type Pack struct {
+	Name        string              `toml:"name" json:"name"`
+	Description string              `toml:"description" json:"description"`
+	Templates   map[string]Template `toml:"templates" json:"templates"`
+}
+
+// ReadPack 读取模板包，按扩展名（.toml 或 .json）解析
+func ReadPack(path string) (*Pack, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var pack Pack
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		err = toml.Unmarshal(data, &pack)
+	case ".json":
+		err = json.Unmarshal(data, &pack)
+	default:
+		return nil, fmt.Errorf("explain: unsupported pack format %q", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析模板包 %s 失败: %w", path, err)
+	}
+	if len(pack.Templates) == 0 {
+		return nil, fmt.Errorf("模板包 %s 中没有模板", path)
+	}
+	return &pack, nil
+}
+
+// WritePack 写入模板包，按扩展名（.toml 或 .json）编码
+func WritePack(path string, pack *Pack) error {
+	var data []byte
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		data, err = toml.Marshal(pack)
+	case ".json":
+		data, err = json.MarshalIndent(pack, "", "  ")
+	default:
+		return fmt.Errorf("explain: unsupported pack format %q", filepath.Ext(path))
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -12,15 +12,79 @@
 import { Call as $Call, CancellablePromise as $CancellablePromise, Create as $Create } from "@wailsio/runtime";
 
 /**
- * CaptureSelectedScreen 截取选中的区域
+ * CaptureSelectedScreen 裁剪截图会话中选中的区域并识别文字，选区为截图窗口中两个角的 CSS 像素坐标，
+ * 会话不存在、已过期或选区为空时返回错误。截图模式为 overlay 时返回各文字块的截图坐标和原文（JSON），
+ * 之后按批翻译，通过 overlay_result 事件发送译文；为 text 时返回空，识别出的文字合并后在翻译窗口中翻译
+ * @param {string} sessionID
  * @param {number} startX
  * @param {number} startY
- * @param {number} width
- * @param {number} height
+ * @param {number} endX
+ * @param {number} endY
+ * @returns {$CancellablePromise<string>}
+ */
+export function CaptureSelectedScreen(sessionID, startX, startY, endX, endY) {
+    return $Call.ByID(454152140, sessionID, startX, startY, endX, endY);
+}
+
+/**
+ * CloseScreenshotSession 结束截图会话并释放截图，截图窗口关闭时调用
+ * @param {string} sessionID
+ * @returns {$CancellablePromise<void>}
+ */
+export function CloseScreenshotSession(sessionID) {
+    return $Call.ByID(2199503377, sessionID);
+}
+
+/**
+ * CopyScreenshotImage 将截图会话中选中的区域复制到剪贴板
+ * @param {string} sessionID
+ * @param {number} startX
+ * @param {number} startY
+ * @param {number} endX
+ * @param {number} endY
+ * @returns {$CancellablePromise<void>}
+ */
+export function CopyScreenshotImage(sessionID, startX, startY, endX, endY) {
+    return $Call.ByID(3994904693, sessionID, startX, startY, endX, endY);
+}
+
+/**
+ * CopyScreenshotText 识别截图会话中选中区域的文字并复制到剪贴板，不翻译，返回识别出的文字
+ * @param {string} sessionID
+ * @param {number} startX
+ * @param {number} startY
+ * @param {number} endX
+ * @param {number} endY
+ * @returns {$CancellablePromise<string>}
+ */
+export function CopyScreenshotText(sessionID, startX, startY, endX, endY) {
+    return $Call.ByID(2558542703, sessionID, startX, startY, endX, endY);
+}
+
+/**
+ * PinScreenshot 将截图会话中选中的区域钉在屏幕上原来的位置，显示为置顶的小窗口
+ * @param {string} sessionID
+ * @param {number} startX
+ * @param {number} startY
+ * @param {number} endX
+ * @param {number} endY
  * @returns {$CancellablePromise<void>}
  */
-export function CaptureSelectedScreen(startX, startY, width, height) {
-    return $Call.ByID(454152140, startX, startY, width, height);
+export function PinScreenshot(sessionID, startX, startY, endX, endY) {
+    return $Call.ByID(4055507400, sessionID, startX, startY, endX, endY);
+}
+
+/**
+ * SaveScreenshot 将截图会话中选中的区域保存到 screenshot.save_dir，文件名带时间，返回文件路径
+ * @param {string} sessionID
+ * @param {number} startX
+ * @param {number} startY
+ * @param {number} endX
+ * @param {number} endY
+ * @returns {$CancellablePromise<string>}
+ */
+export function SaveScreenshot(sessionID, startX, startY, endX, endY) {
+    return $Call.ByID(3143230836, sessionID, startX, startY, endX, endY);
 }
 
 /**
@@ -33,6 +97,15 @@ export function ExplainStream(queryText, templateID) {
     return $Call.ByID(376363948, queryText, templateID);
 }
 
+/**
+ * GetAppState 获取后端当前的语言（from_lang、to_lang）、工具栏模式（toolbar_mode）、截图模式（capture_mode）、翻译服务和配置方案，
+ * 前端启动时据此恢复界面状态
+ * @returns {$CancellablePromise<string>}
+ */
+export function GetAppState() {
+    return $Call.ByID(3272654863);
+}
+
 /**
  * GetExplainTemplates 获取所有解释模板
  * @returns {$CancellablePromise<string>}
@@ -84,6 +157,15 @@ export function MyFetch(URL, content) {
     return $Call.ByID(2071126117, URL, content);
 }
 
+/**
+ * SetCaptureMode 设置截图模式：text 在翻译窗口中翻译识别出的文字，overlay 将译文覆盖在截图上
+ * @param {string} mode
+ * @returns {$CancellablePromise<void>}
+ */
+export function SetCaptureMode(mode) {
+    return $Call.ByID(3986478476, mode);
+}
+
 /**
  * SetDefaultExplainTemplate 设置默认解释模板
  * @param {string} templateID
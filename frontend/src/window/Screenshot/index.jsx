@@ -2,7 +2,16 @@ import React, { useEffect, useState, useRef } from 'react';
 import toast, { Toaster } from 'react-hot-toast';
 import { useConfig, useToastStyle, useVoice } from '../../hooks';
 import { atom, useAtom } from 'jotai';
-import { CaptureSelectedScreen } from '../../../bindings/handy-translate/app';
+import {
+    CaptureSelectedScreen,
+    CloseScreenshotSession,
+    CopyScreenshotImage,
+    CopyScreenshotText,
+    GetAppState,
+    PinScreenshot,
+    SaveScreenshot,
+    SetCaptureMode,
+} from '../../../bindings/handy-translate/app';
 import { Events, Window } from "@wailsio/runtime";
 
 
@@ -18,14 +27,54 @@ export default function Screenshot() {
     const toastStyle = useToastStyle();
     const imgRef = useRef();
     const canvasRef = useRef(null);
+    const sessionRef = useRef(''); // 当前截图会话 ID，选区操作都引用这次截图
+    const [selection, setSelection] = useState(null); // 选中的区域（CSS 像素），选中后显示操作栏
+    const scaleRef = useRef(1); // 截图的物理像素 / CSS 像素
+    const [captureMode, setCaptureMode] = useState('text'); // text 在翻译窗口中翻译，overlay 将译文覆盖在截图上
+    const [overlay, setOverlay] = useState({}); // 覆盖翻译的文字块，按 index 索引
+
+    // 合并覆盖翻译的文字块，已有译文的文字块不被没有译文的覆盖
+    const mergeOverlay = (blocks) => {
+        setOverlay((prev) => {
+            const next = { ...prev }
+            for (const block of blocks) {
+                next[block.index] = { ...prev[block.index], ...block, translation: block.translation || prev[block.index]?.translation }
+            }
+            return next
+        })
+    }
 
     useEffect(() => {
         Events.On("screenshotBase64", function (result) {
-            let base64 = result.data
-            setImgurl("data:image/png;base64," + base64)
+            // 截图失败时后端在翻译窗口中显示错误，不发送此事件
+            const { session_id, image, scale } = result.data
+            if (sessionRef.current && sessionRef.current !== session_id) {
+                CloseScreenshotSession(sessionRef.current)
+            }
+            sessionRef.current = session_id
+            scaleRef.current = scale || 1
+            setSelection(null)
+            setOverlay({})
+            setImgurl("data:image/png;base64," + image)
+            GetAppState().then((res) => {
+                setCaptureMode(JSON.parse(res).capture_mode || 'text')
+            })
+        })
+
+        // 覆盖翻译的译文按批到达，只处理当前截图会话的结果
+        Events.On("overlay_result", function (result) {
+            const { session_id, blocks, error } = result.data
+            if (session_id !== sessionRef.current) {
+                return
+            }
+            if (error) {
+                toast.error(error, { style: toastStyle });
+            }
+            mergeOverlay(blocks || [])
         })
     }, [])
 
+    // 选区为截图窗口中的 CSS 像素，后端按显示器缩放比例换算为截图的物理像素
     const captureScreenshot = (x, y, width, height) => {
         // const canvas = canvasRef.current;
         // const context = canvas.getContext('2d');
@@ -41,23 +90,95 @@ export default function Screenshot() {
         // context.drawImage(image, x, y, width, height, 0, 0, width, height);
         // const base64Data = canvas.toDataURL('image/png');
         // EventsEmit("screenshotCapture", base64Data)
-        CaptureSelectedScreen(x, y, x + width, y + height).then((res) => {
+        // 截图窗口随后隐藏，错误由后端显示在翻译窗口中；识别完成后结束截图会话
+        const sessionID = sessionRef.current
+        sessionRef.current = ''
+        CaptureSelectedScreen(sessionID, x, y, x + width, y + height).then((res) => {
             console.log("success", res)
+        }).catch((err) => {
+            console.error("截图翻译失败", err)
+        }).finally(() => {
+            CloseScreenshotSession(sessionID)
         })
         setImgurl("")
     }
 
+    // 覆盖翻译：截图窗口保持显示，先显示识别出的文字块，译文通过 overlay_result 事件按批更新
+    const captureOverlay = ({ left, top, right, bottom }) => {
+        setOverlay({})
+        CaptureSelectedScreen(sessionRef.current, left, top, right, bottom).then((res) => {
+            mergeOverlay(JSON.parse(res || '[]') || [])
+        }).catch((err) => {
+            toast.error(err.toString(), { style: toastStyle });
+        })
+    }
+
+    const toggleCaptureMode = () => {
+        const mode = captureMode === 'overlay' ? 'text' : 'overlay'
+        SetCaptureMode(mode).then(() => {
+            setCaptureMode(mode)
+        }).catch((err) => {
+            toast.error(err.toString(), { style: toastStyle });
+        })
+    }
+
+    // 文字块的截图坐标（物理像素）换算为截图窗口中的 CSS 像素范围
+    const blockRect = (box) => {
+        const xs = box.map((p) => p[0] / scaleRef.current)
+        const ys = box.map((p) => p[1] / scaleRef.current)
+        return {
+            left: Math.min(...xs),
+            top: Math.min(...ys),
+            width: Math.max(...xs) - Math.min(...xs),
+            height: Math.max(...ys) - Math.min(...ys),
+        }
+    }
+
+    // 对选区执行操作（复制、保存、复制文字、钉图），各操作共用同一次截图；成功后结束截图会话并关闭截图窗口，失败时保留选区并提示
+    const runAction = (action) => {
+        const { left, top, right, bottom } = selection
+        action(sessionRef.current, left, top, right, bottom).then(() => {
+            CloseScreenshotSession(sessionRef.current)
+            sessionRef.current = ''
+            setSelection(null)
+            setOverlay({})
+            setImgurl("")
+            Window.Hide()
+        }).catch((err) => {
+            toast.error(err.toString(), { style: toastStyle });
+        })
+    }
+
+    const actions = [
+        { label: '翻译', onClick: () => {
+            const { left, top, right, bottom } = selection
+            setSelection(null)
+            if (captureMode === 'overlay') {
+                captureOverlay(selection)
+                return
+            }
+            captureScreenshot(left, top, right - left, bottom - top)
+            Window.Hide()
+        } },
+        { label: '复制', onClick: () => runAction(CopyScreenshotImage) },
+        { label: '保存', onClick: () => runAction(SaveScreenshot) },
+        { label: '复制文字', onClick: () => runAction(CopyScreenshotText) },
+        { label: '钉图', onClick: () => runAction(PinScreenshot) },
+        { label: captureMode === 'overlay' ? '覆盖翻译' : '文字翻译', onClick: toggleCaptureMode },
+    ]
+
     return (
         <>
+            <Toaster />
             <img
                 ref={imgRef}
-                className='fixed top-0 left-0 w-full select-none'
+                className='fixed top-0 left-0 w-full h-full select-none'
                 src={imgurl}
                 draggable={false}
                 onLoad={() => {
+                    // 后端已将窗口移到截图的显示器上并覆盖截图范围
                     if (imgurl !== '' && imgRef.current.complete) {
                         Window.Show()
-                        Window.Fullscreen()
                         Window.SetAlwaysOnTop(true)
                     }
                 }}
@@ -67,18 +188,35 @@ export default function Screenshot() {
                 style={{
                     top: Math.min(mouseDownY, mouseMoveY),
                     left: Math.min(mouseDownX, mouseMoveX),
-                    bottom: screen.height - Math.max(mouseDownY, mouseMoveY),
-                    right: screen.width - Math.max(mouseDownX, mouseMoveX),
+                    bottom: window.innerHeight - Math.max(mouseDownY, mouseMoveY),
+                    right: window.innerWidth - Math.max(mouseDownX, mouseMoveX),
                 }}
             />
+            {selection && (
+                <div
+                    className='fixed border border-solid border-sky-500'
+                    style={{
+                        top: selection.top,
+                        left: selection.left,
+                        width: selection.right - selection.left,
+                        height: selection.bottom - selection.top,
+                    }}
+                />
+            )}
             <div
                 className='fixed top-0 left-0 bottom-0 right-0 cursor-crosshair select-none'
                 onMouseDown={(e) => {
                     if (e.button === 0) {
+                        setSelection(null);
+                        setOverlay({});
                         setIsDown(true);
                         setMouseDownX(e.clientX);
                         setMouseDownY(e.clientY);
                     } else {
+                        CloseScreenshotSession(sessionRef.current)
+                        sessionRef.current = ''
+                        setOverlay({})
+                        setImgurl("")
                         Window.Hide()
                     }
                 }}
@@ -93,23 +231,53 @@ export default function Screenshot() {
                     setIsDown(false);
                     setIsMoved(false);
                     if (e.button === 0) {
-                        const imgWidth = imgRef.current.naturalWidth;
-                        const dpi = imgWidth / screen.width;
-                        const left = Math.floor(Math.min(mouseDownX, e.clientX) * dpi);
-                        const top = Math.floor(Math.min(mouseDownY, e.clientY) * dpi);
-                        const right = Math.floor(Math.max(mouseDownX, e.clientX) * dpi);
-                        const bottom = Math.floor(Math.max(mouseDownY, e.clientY) * dpi);
+                        const left = Math.min(mouseDownX, e.clientX);
+                        const top = Math.min(mouseDownY, e.clientY);
+                        const right = Math.max(mouseDownX, e.clientX);
+                        const bottom = Math.max(mouseDownY, e.clientY);
                         const width = right - left;
                         const height = bottom - top;
                         if (width <= 0 || height <= 0) {
                             toast.error('Screenshot area is too small', { style: toastStyle });
                         } else {
-                            captureScreenshot(left, top, width, height)
+                            // 选中后显示操作栏，由用户选择翻译、复制、保存、复制文字或钉图
+                            setSelection({ left, top, right, bottom })
                         }
                     }
-                    Window.Hide()
                 }}
             />
+            {Object.values(overlay).map((block) => {
+                // 译文未到达时先显示原文
+                const rect = blockRect(block.box)
+                return (
+                    <div
+                        key={block.index}
+                        className='fixed overflow-hidden bg-white/90 text-black leading-tight select-none pointer-events-none'
+                        style={{ ...rect, fontSize: Math.max(rect.height * 0.7, 10) }}
+                    >
+                        {block.translation || block.text}
+                    </div>
+                )
+            })}
+            {selection && (
+                <div
+                    className='fixed flex gap-1 p-1 rounded bg-white shadow select-none'
+                    style={{
+                        top: Math.min(selection.bottom + 6, window.innerHeight - 40),
+                        left: Math.max(selection.right - 340, 0),
+                    }}
+                >
+                    {actions.map(({ label, onClick }) => (
+                        <button
+                            key={label}
+                            className='px-2 py-1 text-sm text-black rounded hover:bg-sky-100'
+                            onClick={onClick}
+                        >
+                            {label}
+                        </button>
+                    ))}
+                </div>
+            )}
         </>
     );
 }
\ No newline at end of file
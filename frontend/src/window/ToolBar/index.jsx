@@ -4,7 +4,7 @@ import { HeartIcon } from './HeartIcon';
 import { CameraIcon } from './CameraIcon';
 import { BsTranslate } from "react-icons/bs";
 import { MdContentCopy, MdVolumeUp, MdPushPin, MdOutlinePushPin, MdLightbulb } from "react-icons/md";
-import { ToolBarShow, Show, Hide, SetToolBarPinned, GetToolBarPinned, TranslateStream, Translate, TranslateMeanings, ExplainStream, GetExplainTemplates, SetDefaultExplainTemplate } from "../../../bindings/handy-translate/app";
+import { ToolBarShow, Show, Hide, SetToolBarPinned, GetToolBarPinned, TranslateStream, Translate, TranslateMeanings, ExplainStream, GetExplainTemplates, SetDefaultExplainTemplate, GetAppState } from "../../../bindings/handy-translate/app";
 import { lingva_tts } from "../../services/tts";
 import { useVoice } from "../../hooks/useVoice";
 import { Events, Window } from "@wailsio/runtime";
@@ -74,8 +74,27 @@ export default function ToolBar() {
     const contentRef = useRef(); // 实际内容容器的引用
     const { t } = useTranslation(); // 国际化
 
-    // 初始化时从后端获取固定状态和模板列表
+    // 初始化时从后端获取固定状态、工具栏模式和模板列表
     useEffect(() => {
+        // 后端从 state.json 恢复了上次的模式，以后端为准
+        GetAppState().then(result => {
+            const restored = JSON.parse(result).toolbar_mode
+            if (restored) {
+                setMode(restored)
+                modeRef.current = restored
+            }
+        }).catch(err => {
+            console.error('获取应用状态失败:', err)
+        })
+
+        // 切换配置方案时后端已保存新的模式，只更新界面
+        const unsubscribeProfile = Events.On("profile_changed", function (data) {
+            if (data.data?.mode) {
+                setMode(data.data.mode)
+                modeRef.current = data.data.mode
+            }
+        })
+
         GetToolBarPinned().then(pinned => {
             console.log('从后端获取工具栏固定状态:', pinned)
             setIsPinned(pinned)
@@ -113,6 +132,10 @@ export default function ToolBar() {
         }).catch(err => {
             console.error('获取解释模板失败:', err)
         })
+
+        return () => {
+            if (unsubscribeProfile) unsubscribeProfile()
+        }
     }, [])
 
     // 检测是否为单个单词
@@ -848,6 +871,9 @@ export default function ToolBar() {
                     <Tabs
                         selectedKey={mode}
                         onSelectionChange={async (key) => {
+                            if (key === modeRef.current) {
+                                return
+                            }
                             setMode(key)
                             modeRef.current = key // 同步更新 ref
                             // 通知后端更新模式
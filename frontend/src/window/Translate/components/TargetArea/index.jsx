@@ -15,7 +15,7 @@ import {
 } from '@nextui-org/react';
 import toast, { Toaster } from 'react-hot-toast';
 import React, { useEffect, useRef, useState } from "react";
-import { useAtomValue } from 'jotai';
+import { useAtomValue, useSetAtom } from 'jotai';
 import { useTranslation } from 'react-i18next';
 import { BiCollapseVertical, BiExpandVertical } from 'react-icons/bi';
 import { TbTransformFilled } from 'react-icons/tb';
@@ -28,7 +28,7 @@ import * as builtinServices from '../../../../services/translate';
 import { useConfig, useToastStyle, useVoice } from '../../../../hooks';
 import { sourceTextAtom, detectLanguageAtom } from '../SourceArea';
 import { sourceLanguageAtom, targetLanguageAtom } from '../LanguageArea';
-import { Translate, TranslateStream } from '../../../../../bindings/handy-translate/app';
+import { GetAppState, Translate, TranslateStream } from '../../../../../bindings/handy-translate/app';
 import { Events, Clipboard } from "@wailsio/runtime";
 
 export default function TargetArea(props) {
@@ -49,6 +49,11 @@ export default function TargetArea(props) {
     const sourceLanguage = useAtomValue(sourceLanguageAtom);
     const targetLanguage = useAtomValue(targetLanguageAtom);
     const detectLanguage = useAtomValue(detectLanguageAtom);
+    const setSourceLanguage = useSetAtom(sourceLanguageAtom);
+    const setTargetLanguage = useSetAtom(targetLanguageAtom);
+    const serviceNameRef = useRef(name);
+    serviceNameRef.current = translateServiceName;
+    const backendLangRef = useRef(null); // 后端当前的语言（翻译服务的语言代码），从后端恢复前为 null
 
     const speak = useVoice();
     const toastStyle = useToastStyle();
@@ -59,6 +64,33 @@ export default function TargetArea(props) {
     const [result, setResult] = useState('');
     const [error, setError] = useState('');
 
+    // 将后端的语言代码换算为界面中的语言，换算不了的保持不变
+    const applyBackendLanguages = (from, to) => {
+        const LanguageEnum = builtinServices[serviceNameRef.current]?.Language ?? {};
+        const keyOf = (code) => Object.keys(LanguageEnum).find((key) => LanguageEnum[key] === code);
+        const source = keyOf(from);
+        const target = keyOf(to);
+        if (source) setSourceLanguage(source);
+        if (target) setTargetLanguage(target);
+        backendLangRef.current = [from, to];
+    }
+
+    // 后端从 state.json 恢复了上次的语言，界面以后端为准；切换配置方案时后端已保存新的语言
+    useEffect(() => {
+        GetAppState().then((res) => {
+            const state = JSON.parse(res);
+            applyBackendLanguages(state.from_lang, state.to_lang);
+        }).catch((err) => {
+            console.error('获取应用状态失败:', err);
+        })
+        const unsubscribeProfile = Events.On("profile_changed", function (data) {
+            applyBackendLanguages(data.data?.from, data.data?.to);
+        })
+        return () => {
+            if (unsubscribeProfile) unsubscribeProfile()
+        }
+    }, [])
+
     useEffect(() => {
         // 监听普通翻译结果
         const unsubscribeResult = Events.On("result", function (data) {
@@ -88,9 +120,14 @@ export default function TargetArea(props) {
             setIsLoading(false)
         })
 
+        // 只在语言与后端的不同时（用户修改了语言）通知后端，从后端恢复前不发送，避免覆盖恢复的语言
         const LanguageEnum = builtinServices[translateServiceName].Language;
-        if (sourceLanguage in LanguageEnum && targetLanguage in LanguageEnum) {
-            Events.Emit({ name: "translateLang", data: [LanguageEnum[sourceLanguage], LanguageEnum[targetLanguage]] })
+        if (backendLangRef.current && sourceLanguage in LanguageEnum && targetLanguage in LanguageEnum) {
+            const langs = [LanguageEnum[sourceLanguage], LanguageEnum[targetLanguage]];
+            if (langs[0] !== backendLangRef.current[0] || langs[1] !== backendLangRef.current[1]) {
+                backendLangRef.current = langs;
+                Events.Emit({ name: "translateLang", data: langs })
+            }
         }
 
         // 清理事件监听
@@ -0,0 +1,496 @@
+package history
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+var (
+	// ErrLocked 历史记录已加密但本次会话尚未解锁
+	ErrLocked = errors.New("history: storage is locked")
+	// ErrWrongPassphrase 口令错误
+	ErrWrongPassphrase = errors.New("history: wrong passphrase")
+	// ErrWrongKey 数据不是用当前密钥加密的，例如其他机器更换口令前上传的分段
+	ErrWrongKey = errors.New("history: data was encrypted with a different key")
+)
+
+// encryptedMagic 加密文件头，其后依次为 nonce 和密文
+var encryptedMagic = []byte("HTENC1\n")
+
+// keyCheckPlaintext 用于校验口令是否正确
+var keyCheckPlaintext = []byte("handy-translate-history")
+
+const (
+	scryptN     = 1 << 15
+	scryptR     = 8
+	scryptP     = 1
+	keyLength   = 32 // AES-256
+	saltLength  = 16
+	keyFileName = "key.json"
+)
+
+// keyFile 保存在 storage_path/history/key.json，不包含密钥本身，只有派生参数和校验数据
+type keyFile struct {
+	KDF   string `json:"kdf"`
+	Salt  []byte `json:"salt"`
+	N     int    `json:"n"`
+	R     int    `json:"r"`
+	P     int    `json:"p"`
+	Check []byte `json:"check"`
+}
+
+// Unlock 使用口令解锁加密的历史记录，首次调用时会以该口令创建密钥，并加密开启加密前保存的明文文件；
+// 解锁后写入锁定期间暂存的记录
+func (h *HistoryService) Unlock(passphrase string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	kf, err := h.readKeyFile()
+	if err != nil {
+		return err
+	}
+
+	var key []byte
+	if kf == nil {
+		// 未开启加密且没有密钥文件，无需解锁
+		if !h.encrypted {
+			return nil
+		}
+		key, kf, err = newKey(passphrase)
+		if err != nil {
+			return err
+		}
+		// 与更换口令相同的方式替换文件，中途失败时保留原来的明文文件，不写入密钥文件
+		if err := h.reencryptLocked(nil, key, kf); err != nil {
+			return err
+		}
+	} else {
+		key, err = kf.derive(passphrase)
+		if err != nil {
+			return err
+		}
+	}
+
+	h.key = key
+	h.flushPendingLocked()
+	return nil
+}
+
+// flushPendingLocked 写入锁定期间暂存的记录，调用方需持有 h.mu
+func (h *HistoryService) flushPendingLocked() {
+	pending := h.pending
+	h.pending = nil
+	for _, record := range pending {
+		h.mergeRecordLocked(h.recordFilePath(record), record)
+	}
+}
+
+// Pending 锁定期间暂存在内存中的记录数，这些记录在解锁后才写入文件，解锁前退出程序会丢失
+func (h *HistoryService) Pending() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.pending)
+}
+
+// Locked 历史记录是否处于加密且未解锁的状态
+func (h *HistoryService) Locked() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.encrypted && h.key == nil
+}
+
+// Encrypted 历史记录是否加密存储
+func (h *HistoryService) Encrypted() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.encrypted
+}
+
+// Rekey 使用新口令重新加密全部历史记录，未加密的旧文件也会一并加密
+func (h *HistoryService) Rekey(oldPassphrase, newPassphrase string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	kf, err := h.readKeyFile()
+	if err != nil {
+		return err
+	}
+	oldKey := h.key
+	if kf != nil {
+		if oldKey, err = kf.derive(oldPassphrase); err != nil {
+			return err
+		}
+	}
+
+	newKeyBytes, newKF, err := newKey(newPassphrase)
+	if err != nil {
+		return err
+	}
+	if err := h.reencryptLocked(oldKey, newKeyBytes, newKF); err != nil {
+		return err
+	}
+	h.key, h.encrypted = newKeyBytes, true
+	return nil
+}
+
+// reencryptLocked 使用 oldKey 读出全部记录文件，以 newKey 重新加密并替换密钥文件，调用方需持有 h.mu。
+//
+// 替换分为几步：先写入全部 .tmp 文件，再写入 key.json.new；之后把原文件改名为 .old、.tmp 改名为原文件，
+// 最后把 key.json.new 改名为 key.json，这一步是提交点。提交前任何一步失败都会回滚到原来的文件和密钥，
+// 进程在提交前退出时由 recoverLocked 在下次启动时回滚，因此磁盘上不会出现新旧密钥混用
+func (h *HistoryService) reencryptLocked(oldKey, newKey []byte, newKF *keyFile) error {
+	if err := h.recoverLocked(); err != nil {
+		return err
+	}
+
+	files, err := h.allRecordFiles()
+	if err != nil {
+		return err
+	}
+
+	for _, filePath := range files {
+		records, err := readRecordFile(filePath, oldKey)
+		if err == nil {
+			err = writeRecordFile(filePath+".tmp", records, newKey)
+		}
+		if err != nil {
+			h.rollbackLocked(files)
+			return err
+		}
+	}
+	if err := writeJSONFile(h.keyFilePath()+".new", newKF); err != nil {
+		h.rollbackLocked(files)
+		return err
+	}
+
+	for _, filePath := range files {
+		err := os.Rename(filePath, filePath+".old")
+		if err == nil {
+			err = os.Rename(filePath+".tmp", filePath)
+		}
+		if err != nil {
+			h.rollbackLocked(files)
+			return err
+		}
+	}
+	if err := os.Rename(h.keyFilePath()+".new", h.keyFilePath()); err != nil {
+		h.rollbackLocked(files)
+		return err
+	}
+
+	for _, filePath := range files {
+		os.Remove(filePath + ".old")
+	}
+	return nil
+}
+
+// KeyFile 返回密钥文件的原始内容，用于同步到其他机器，未加密时返回 nil
+func (h *HistoryService) KeyFile() ([]byte, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	data, err := os.ReadFile(h.keyFilePath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// KeyFileSalt 返回密钥文件中的盐（十六进制），盐相同的两份密钥文件以同一口令派生出同一个密钥
+func KeyFileSalt(data []byte) (string, error) {
+	var kf keyFile
+	if err := json.Unmarshal(data, &kf); err != nil {
+		return "", fmt.Errorf("解析密钥文件失败: %w", err)
+	}
+	return hex.EncodeToString(kf.Salt), nil
+}
+
+// AdoptKey 改用另一台机器的密钥文件：以口令校验后，用其密钥重新加密本地全部记录并替换本地的密钥文件。
+// 本地未解锁时先尝试用同一口令解锁本地的密钥，两台机器口令不同时需先调用 Unlock
+func (h *HistoryService) AdoptKey(data []byte, passphrase string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var kf keyFile
+	if err := json.Unmarshal(data, &kf); err != nil {
+		return fmt.Errorf("解析密钥文件失败: %w", err)
+	}
+	key, err := kf.derive(passphrase)
+	if err != nil {
+		return err
+	}
+
+	oldKey := h.key
+	if oldKey == nil {
+		local, err := h.readKeyFile()
+		if err != nil {
+			return err
+		}
+		if local != nil {
+			if oldKey, err = local.derive(passphrase); err != nil {
+				return ErrLocked
+			}
+		}
+	}
+
+	if err := h.reencryptLocked(oldKey, key, &kf); err != nil {
+		return err
+	}
+	h.key, h.encrypted = key, true
+	h.flushPendingLocked()
+	return nil
+}
+
+// rollbackLocked 撤销未提交的重新加密：恢复 .old 文件，删除 .tmp 文件和 key.json.new
+func (h *HistoryService) rollbackLocked(files []string) {
+	for _, filePath := range files {
+		if _, err := os.Stat(filePath + ".old"); err == nil {
+			if err := os.Rename(filePath+".old", filePath); err != nil {
+				fmt.Printf("恢复历史记录文件失败: %v\n", err)
+			}
+		}
+		os.Remove(filePath + ".tmp")
+	}
+	os.Remove(h.keyFilePath() + ".new")
+}
+
+// recoverLocked 处理上次中断的重新加密：存在 key.json.new 说明未提交，回滚；
+// 否则已提交或尚未开始替换，删除残留的 .old 和 .tmp 文件
+func (h *HistoryService) recoverLocked() error {
+	var leftovers []string
+	for _, recordType := range []string{"translate", "explain"} {
+		matches, err := filepath.Glob(filepath.Join(h.storagePath, "history", recordType, "*.json.*"))
+		if err != nil {
+			return err
+		}
+		leftovers = append(leftovers, matches...)
+	}
+
+	if _, err := os.Stat(h.keyFilePath() + ".new"); err == nil {
+		fmt.Println("检测到未完成的重新加密，恢复原来的历史记录文件")
+		files := map[string]bool{}
+		for _, leftover := range leftovers {
+			files[strings.TrimSuffix(strings.TrimSuffix(leftover, ".old"), ".tmp")] = true
+		}
+		list := make([]string, 0, len(files))
+		for filePath := range files {
+			list = append(list, filePath)
+		}
+		h.rollbackLocked(list)
+		return nil
+	}
+
+	for _, leftover := range leftovers {
+		if strings.HasSuffix(leftover, ".old") || strings.HasSuffix(leftover, ".tmp") {
+			if err := os.Remove(leftover); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ExportPlain 将全部历史记录解密后导出到 destDir，目录结构与存储目录一致
+func (h *HistoryService) ExportPlain(passphrase, destDir string) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	kf, err := h.readKeyFile()
+	if err != nil {
+		return 0, err
+	}
+	key := h.key
+	if kf != nil {
+		if key, err = kf.derive(passphrase); err != nil {
+			return 0, err
+		}
+	}
+
+	files, err := h.allRecordFiles()
+	if err != nil {
+		return 0, err
+	}
+
+	exported := 0
+	for _, filePath := range files {
+		records, err := readRecordFile(filePath, key)
+		if err != nil {
+			return exported, err
+		}
+
+		rel, err := filepath.Rel(h.storagePath, filePath)
+		if err != nil {
+			return exported, err
+		}
+		dest := filepath.Join(destDir, rel)
+		if err := os.MkdirAll(filepath.Dir(dest), 0700); err != nil {
+			return exported, err
+		}
+
+		data, err := json.MarshalIndent(records, "", "  ")
+		if err != nil {
+			return exported, err
+		}
+		if err := os.WriteFile(dest, data, 0600); err != nil {
+			return exported, err
+		}
+		exported++
+	}
+	return exported, nil
+}
+
+// encode 按当前加密设置编码文件内容
+func (h *HistoryService) encode(data []byte) ([]byte, error) {
+	if !h.encrypted {
+		return data, nil
+	}
+	if h.key == nil {
+		return nil, ErrLocked
+	}
+	return seal(h.key, data)
+}
+
+// decode 使用本次会话的密钥解码文件内容
+func (h *HistoryService) decode(data []byte) ([]byte, error) {
+	return decodeWith(h.key, data)
+}
+
+// decodeWith 使用指定密钥解码文件内容，未加密的旧文件原样返回
+func decodeWith(key, data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, encryptedMagic) {
+		return data, nil
+	}
+	if key == nil {
+		return nil, ErrLocked
+	}
+	plaintext, err := open(key, data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrWrongKey, err)
+	}
+	return plaintext, nil
+}
+
+func (h *HistoryService) keyFilePath() string {
+	return path.Join(h.storagePath, "history", keyFileName)
+}
+
+func (h *HistoryService) readKeyFile() (*keyFile, error) {
+	data, err := os.ReadFile(h.keyFilePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var kf keyFile
+	if err := json.Unmarshal(data, &kf); err != nil {
+		return nil, fmt.Errorf("解析密钥文件失败: %w", err)
+	}
+	return &kf, nil
+}
+
+// writeJSONFile 写入只有当前用户可读的 JSON 文件
+func writeJSONFile(filePath string, v interface{}) error {
+	if err := os.MkdirAll(path.Dir(filePath), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, data, 0600)
+}
+
+// newKey 生成新的盐并派生密钥
+func newKey(passphrase string) ([]byte, *keyFile, error) {
+	if passphrase == "" {
+		return nil, nil, errors.New("history: empty passphrase")
+	}
+
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, nil, err
+	}
+
+	kf := &keyFile{KDF: "scrypt", Salt: salt, N: scryptN, R: scryptR, P: scryptP}
+	key, err := scrypt.Key([]byte(passphrase), kf.Salt, kf.N, kf.R, kf.P, keyLength)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if kf.Check, err = seal(key, keyCheckPlaintext); err != nil {
+		return nil, nil, err
+	}
+	return key, kf, nil
+}
+
+// derive 使用口令派生密钥并校验
+func (kf *keyFile) derive(passphrase string) ([]byte, error) {
+	if kf.KDF != "scrypt" {
+		return nil, fmt.Errorf("history: unsupported kdf %q", kf.KDF)
+	}
+
+	key, err := scrypt.Key([]byte(passphrase), kf.Salt, kf.N, kf.R, kf.P, keyLength)
+	if err != nil {
+		return nil, err
+	}
+
+	check, err := open(key, kf.Check)
+	if err != nil || !bytes.Equal(check, keyCheckPlaintext) {
+		return nil, ErrWrongPassphrase
+	}
+	return key, nil
+}
+
+// seal AES-GCM 加密，输出 magic + nonce + 密文
+func seal(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	out := append([]byte{}, encryptedMagic...)
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, plaintext, encryptedMagic), nil
+}
+
+// open AES-GCM 解密 seal 的输出
+func open(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	data = bytes.TrimPrefix(data, encryptedMagic)
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("history: encrypted data too short")
+	}
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, encryptedMagic)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
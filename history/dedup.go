@@ -0,0 +1,161 @@
+package history
+
+import (
+	"strings"
+)
+
+// normalizeRecord 补齐旧版本记录缺少的合并字段
+func normalizeRecord(record *HistoryRecord) {
+	if record.Count < 1 {
+		record.Count = 1
+	}
+	if record.FirstSeen.IsZero() {
+		record.FirstSeen = record.Timestamp
+	}
+	if record.LastSeen.IsZero() {
+		record.LastSeen = record.Timestamp
+	}
+	if len(record.Results) == 0 && record.Result != "" {
+		record.Results = []string{record.Result}
+	}
+}
+
+// dedupKey 合并依据：类型 + 源文本 + 语言对（解释类型为模板）
+func dedupKey(record *HistoryRecord) string {
+	text := strings.TrimSpace(record.SourceText)
+	if record.Type == "explain" {
+		return strings.Join([]string{record.Type, record.TemplateID, text}, "\x00")
+	}
+	return strings.Join([]string{record.Type, record.FromLang, record.ToLang, text}, "\x00")
+}
+
+func findRecord(records []*HistoryRecord, key string) *HistoryRecord {
+	for _, record := range records {
+		if dedupKey(record) == key {
+			return record
+		}
+	}
+	return nil
+}
+
+// mergeRecord 将另一条记录 src 合并进 dst，保留 dst 的 ID，两条记录的次数按设备相加
+func mergeRecord(dst, src *HistoryRecord) {
+	if dst.Counts == nil && src.Counts == nil {
+		dst.Count += src.Count
+	} else {
+		counts := deviceCounts(dst)
+		for device, n := range deviceCounts(src) {
+			counts[device] += n
+		}
+		setCounts(dst, counts)
+	}
+	if src.FirstSeen.Before(dst.FirstSeen) {
+		dst.FirstSeen = src.FirstSeen
+		dst.Timestamp = src.Timestamp
+	}
+	if !src.LastSeen.Before(dst.LastSeen) {
+		dst.LastSeen = src.LastSeen
+		if src.Result != "" {
+			dst.Result = src.Result
+		}
+		if src.Provider != "" {
+			dst.Provider = src.Provider
+		}
+	}
+
+	for _, result := range src.Results {
+		if !containsString(dst.Results, result) {
+			dst.Results = append(dst.Results, result)
+		}
+	}
+}
+
+// MergeCounts 合并同一条记录（ID 相同）在两台设备上的次数：每台设备的次数取较大值后求和，
+// 两端各自增加的次数都会保留，且合并满足交换律、结合律和幂等性
+func MergeCounts(dst, src *HistoryRecord) {
+	if dst.Counts == nil && src.Counts == nil {
+		dst.Count = max(dst.Count, src.Count)
+		return
+	}
+	counts := deviceCounts(dst)
+	for device, n := range deviceCounts(src) {
+		counts[device] = max(counts[device], n)
+	}
+	setCounts(dst, counts)
+}
+
+// deviceCounts 复制记录的分设备次数，旧记录的次数记在空设备 ID 下
+func deviceCounts(record *HistoryRecord) map[string]int {
+	counts := make(map[string]int, len(record.Counts)+1)
+	if record.Counts == nil {
+		counts[""] = record.Count
+	}
+	for device, n := range record.Counts {
+		counts[device] = n
+	}
+	return counts
+}
+
+func setCounts(record *HistoryRecord, counts map[string]int) {
+	record.Counts, record.Count = counts, 0
+	for _, n := range counts {
+		record.Count += n
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// dedupRecords 合并一组记录中的重复项，保持首次出现的顺序
+func dedupRecords(records []*HistoryRecord) []*HistoryRecord {
+	var merged []*HistoryRecord
+	index := map[string]*HistoryRecord{}
+	for _, record := range records {
+		key := dedupKey(record)
+		if existing, ok := index[key]; ok {
+			mergeRecord(existing, record)
+			continue
+		}
+		index[key] = record
+		merged = append(merged, record)
+	}
+	return merged
+}
+
+// Dedup 一次性整理已有的历史记录文件，合并每个文件中的重复记录，返回被合并掉的记录数
+func (h *HistoryService) Dedup() (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	removed := 0
+	for _, recordType := range []string{"translate", "explain"} {
+		files, err := h.recordFiles(recordType)
+		if err != nil {
+			return removed, err
+		}
+
+		for _, filePath := range files {
+			records, err := h.readRecords(filePath)
+			if err != nil {
+				return removed, err
+			}
+
+			merged := dedupRecords(records)
+			if len(merged) == len(records) {
+				continue
+			}
+
+			if err := h.writeRecords(filePath, merged); err != nil {
+				return removed, err
+			}
+			removed += len(records) - len(merged)
+		}
+	}
+	return removed, nil
+}
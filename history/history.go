@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"strings"
+	"sync"
 	"time"
 
 	"handy-translate/config"
@@ -12,35 +14,126 @@ import (
 	"github.com/google/uuid"
 )
 
-// HistoryRecord 历史记录结构
+// HistoryRecord 历史记录结构，同一天内重复查询的相同文本会合并为一条记录
 type HistoryRecord struct {
-	ID         string    `json:"id"`
-	Type       string    `json:"type"` // "translate" 或 "explain"
-	SourceText string    `json:"source_text"`
-	Result     string    `json:"result"`      // 仅翻译类型有值
-	FromLang   string    `json:"from_lang"`   // 仅翻译类型
-	ToLang     string    `json:"to_lang"`     // 仅翻译类型
-	TemplateID string    `json:"template_id"` // 仅解释类型
-	Timestamp  time.Time `json:"timestamp"`
+	ID         string         `json:"id"`
+	Type       string         `json:"type"` // "translate" 或 "explain"
+	SourceText string         `json:"source_text"`
+	Result     string         `json:"result"`      // 最近一次的结果
+	Results    []string       `json:"results"`     // 所有不同的结果（不同翻译服务结果不一致时保留全部）
+	FromLang   string         `json:"from_lang"`   // 仅翻译类型
+	ToLang     string         `json:"to_lang"`     // 仅翻译类型
+	TemplateID string         `json:"template_id"` // 仅解释类型
+	Provider   string         `json:"provider,omitempty"`
+	Count      int            `json:"count"`
+	Counts     map[string]int `json:"counts,omitempty"` // 各设备的查询次数，count 为其总和；旧记录的次数记在空设备 ID 下
+	FirstSeen  time.Time      `json:"first_seen"`
+	LastSeen   time.Time      `json:"last_seen"`
+	Timestamp  time.Time      `json:"timestamp"`
 }
 
 // HistoryService 历史记录服务
 type HistoryService struct {
+	mu          sync.Mutex // 保护文件的读-改-写，保存记录是异步进行的
 	enabled     bool
 	storagePath string
+	encrypted   bool             // 是否加密存储
+	key         []byte           // 本次会话解锁后的密钥
+	pending     []*HistoryRecord // 加密存储未解锁时暂存的记录
+	device      string           // 本机的设备 ID，用于分设备记录查询次数
 }
 
 // NewHistoryService 创建历史记录服务实例
 func NewHistoryService() *HistoryService {
-	return &HistoryService{
-		enabled:     config.Data.History.Enabled,
-		storagePath: config.Data.History.StoragePath,
+	cfg := config.Get().History
+	h := &HistoryService{
+		enabled:     cfg.Enabled,
+		storagePath: config.ResolvePath(cfg.StoragePath),
+		encrypted:   cfg.Encrypted,
+	}
+	h.recoverRekey()
+	return h
+}
+
+// SetEnabled 开启或关闭历史记录，配置文件修改后调用
+func (h *HistoryService) SetEnabled(enabled bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.enabled = enabled
+}
+
+// SetStorage 切换存储目录和加密设置，配置文件修改后调用。切换目录后需要重新解锁，
+// 锁定期间暂存的记录在解锁后写入新目录
+func (h *HistoryService) SetStorage(storagePath string, encrypted bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.encrypted = encrypted
+	if storagePath == h.storagePath {
+		return
+	}
+	h.storagePath = storagePath
+	h.key = nil
+	h.device = ""
+	if err := h.recoverLocked(); err != nil {
+		fmt.Printf("恢复未完成的重新加密失败: %v\n", err)
+	}
+}
+
+func (h *HistoryService) isEnabled() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.enabled
+}
+
+// NewHistoryServiceWithPath 创建指定存储目录的历史记录服务实例，供命令行工具使用，存在密钥文件时视为加密存储
+func NewHistoryServiceWithPath(storagePath string) *HistoryService {
+	h := &HistoryService{
+		enabled:     true,
+		storagePath: storagePath,
+	}
+	h.recoverRekey()
+	if _, err := os.Stat(h.keyFilePath()); err == nil {
+		h.encrypted = true
+	}
+	return h
+}
+
+// deviceLocked 返回本机的设备 ID，保存在 storage_path/history/device_id，不存在时生成。调用方需持有 h.mu
+func (h *HistoryService) deviceLocked() string {
+	if h.device != "" {
+		return h.device
+	}
+
+	filePath := path.Join(h.storagePath, "history", "device_id")
+	if data, err := os.ReadFile(filePath); err == nil && len(strings.TrimSpace(string(data))) > 0 {
+		h.device = strings.TrimSpace(string(data))
+		return h.device
+	}
+
+	h.device = uuid.New().String()
+	err := os.MkdirAll(path.Dir(filePath), 0755)
+	if err == nil {
+		err = os.WriteFile(filePath, []byte(h.device+"\n"), 0644)
+	}
+	if err != nil {
+		fmt.Printf("保存设备 ID 失败: %v\n", err)
+	}
+	return h.device
+}
+
+// recoverRekey 启动时处理上次中断的重新加密
+func (h *HistoryService) recoverRekey() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := h.recoverLocked(); err != nil {
+		fmt.Printf("恢复未完成的重新加密失败: %v\n", err)
 	}
 }
 
 // SaveTranslateRecord 保存翻译记录
-func (h *HistoryService) SaveTranslateRecord(sourceText, result, fromLang, toLang string) {
-	if !h.enabled {
+func (h *HistoryService) SaveTranslateRecord(sourceText, result, fromLang, toLang, provider string) {
+	if !h.isEnabled() {
 		return
 	}
 
@@ -51,19 +144,17 @@ func (h *HistoryService) SaveTranslateRecord(sourceText, result, fromLang, toLan
 		Result:     result,
 		FromLang:   fromLang,
 		ToLang:     toLang,
+		Provider:   provider,
 		Timestamp:  time.Now(),
 	}
 
-	date := record.Timestamp.Format("2006-01-02")
-	filePath := path.Join(h.storagePath, "history", "translate", date+".json")
-
-	h.appendToFile(filePath, record)
-	fmt.Printf("翻译历史记录已保存，ID: %s\n", record.ID)
+	saved := h.mergeIntoFile(record)
+	fmt.Printf("翻译历史记录已保存，ID: %s，次数: %d\n", saved.ID, saved.Count)
 }
 
-// SaveExplainRecord 保存解释记录（只保存源词语）
-func (h *HistoryService) SaveExplainRecord(sourceText, result, templateID string) {
-	if !h.enabled {
+// SaveExplainRecord 保存解释记录
+func (h *HistoryService) SaveExplainRecord(sourceText, result, templateID, provider string) {
+	if !h.isEnabled() {
 		return
 	}
 
@@ -71,53 +162,182 @@ func (h *HistoryService) SaveExplainRecord(sourceText, result, templateID string
 		ID:         uuid.New().String(),
 		Type:       "explain",
 		SourceText: sourceText,
-		Result:     result, // 解释类型不保存结果
+		Result:     result,
 		TemplateID: templateID,
+		Provider:   provider,
 		Timestamp:  time.Now(),
 	}
 
-	date := record.Timestamp.Format("2006-01-02")
-	filePath := path.Join(h.storagePath, "history", "explain", date+".json")
+	saved := h.mergeIntoFile(record)
+	fmt.Printf("解释历史记录已保存，ID: %s，词语: %s，次数: %d\n", saved.ID, sourceText, saved.Count)
+}
+
+// mergeIntoFile 将记录合并进当天的文件：已有相同记录时累加次数，否则追加，返回文件中对应的记录。
+// 只在同一天内合并，统计中每天的查询次数按记录所在的日期计算
+func (h *HistoryService) mergeIntoFile(record *HistoryRecord) *HistoryRecord {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	normalizeRecord(record)
+	record.Counts = map[string]int{h.deviceLocked(): record.Count}
 
-	h.appendToFile(filePath, record)
-	fmt.Printf("解释历史记录已保存，ID: %s，词语: %s\n", record.ID, sourceText)
+	// 加密存储尚未解锁，先暂存在内存中，解锁后写入
+	if h.encrypted && h.key == nil {
+		h.pending = append(h.pending, record)
+		fmt.Printf("历史记录已加密且未解锁，暂存记录，ID: %s，共 %d 条暂存记录，退出前未解锁将丢失\n", record.ID, len(h.pending))
+		return record
+	}
+
+	return h.mergeRecordLocked(h.recordFilePath(record), record)
 }
 
-// appendToFile 将记录追加到文件
-func (h *HistoryService) appendToFile(filePath string, record *HistoryRecord) {
+// mergeRecordLocked 合并记录到指定文件，调用方需持有 h.mu
+func (h *HistoryService) mergeRecordLocked(filePath string, record *HistoryRecord) *HistoryRecord {
 	// 确保目录存在
 	err := os.MkdirAll(path.Dir(filePath), 0755)
 	if err != nil {
 		fmt.Printf("创建历史记录目录失败: %v\n", err)
-		return
+		return record
 	}
 
 	// 读取现有记录
+	records, err := h.readRecords(filePath)
+	if err != nil {
+		fmt.Printf("读取历史记录文件失败: %v\n", err)
+		return record
+	}
+
+	saved := record
+	if existing := findRecord(records, dedupKey(record)); existing != nil {
+		mergeRecord(existing, record)
+		saved = existing
+	} else {
+		records = append(records, record)
+	}
+
+	if err := h.writeRecords(filePath, records); err != nil {
+		fmt.Printf("写入历史记录文件失败: %v\n", err)
+	}
+	return saved
+}
+
+// recordFilePath 记录所在的文件：storage_path/history/<类型>/<日期>.json
+func (h *HistoryService) recordFilePath(record *HistoryRecord) string {
+	date := record.Timestamp.Format("2006-01-02")
+	return path.Join(h.storagePath, "history", record.Type, date+".json")
+}
+
+// readRecords 读取单个历史记录文件，文件不存在时返回空
+func (h *HistoryService) readRecords(filePath string) ([]*HistoryRecord, error) {
+	return readRecordFile(filePath, h.key)
+}
+
+// readRecordFile 使用指定密钥读取单个历史记录文件，未加密的文件原样读取
+func readRecordFile(filePath string, key []byte) ([]*HistoryRecord, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	data, err = decodeWith(key, data)
+	if err != nil {
+		return nil, err
+	}
+
 	var records []*HistoryRecord
-	if _, err := os.Stat(filePath); err == nil {
-		data, err := os.ReadFile(filePath)
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("解析历史记录文件 %s 失败: %w", path.Base(filePath), err)
+	}
+	for _, record := range records {
+		normalizeRecord(record)
+	}
+	return records, nil
+}
+
+// writeRecords 写回单个历史记录文件，开启加密时写入密文
+func (h *HistoryService) writeRecords(filePath string, records []*HistoryRecord) error {
+	if !h.encrypted {
+		return writeRecordFile(filePath, records, nil)
+	}
+	if h.key == nil {
+		return ErrLocked
+	}
+	return writeRecordFile(filePath, records, h.key)
+}
+
+// writeRecordFile 写入单个历史记录文件，key 不为空时写入密文
+func writeRecordFile(filePath string, records []*HistoryRecord, key []byte) error {
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if key == nil {
+		return os.WriteFile(filePath, data, 0644)
+	}
+	if data, err = seal(key, data); err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, data, 0600)
+}
+
+// recordFiles 返回指定类型的全部历史记录文件路径，文件名即日期，按日期排序
+func (h *HistoryService) recordFiles(recordType string) ([]string, error) {
+	dir := path.Join(h.storagePath, "history", recordType)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		files = append(files, path.Join(dir, entry.Name()))
+	}
+	return files, nil
+}
+
+// allRecordFiles 返回全部类型的历史记录文件路径
+func (h *HistoryService) allRecordFiles() ([]string, error) {
+	var files []string
+	for _, recordType := range []string{"translate", "explain"} {
+		typeFiles, err := h.recordFiles(recordType)
 		if err != nil {
-			fmt.Printf("读取历史记录文件失败: %v\n", err)
-		} else {
-			if err := json.Unmarshal(data, &records); err != nil {
-				fmt.Printf("解析历史记录文件失败: %v\n", err)
-			}
+			return nil, err
 		}
+		files = append(files, typeFiles...)
 	}
+	return files, nil
+}
 
-	// 添加新记录
-	records = append(records, record)
+// LoadRecords 读取指定类型（"translate" 或 "explain"）的全部历史记录，按文件日期排序
+func (h *HistoryService) LoadRecords(recordType string) ([]*HistoryRecord, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 
-	// 写回文件
-	data, err := json.MarshalIndent(records, "", "  ")
+	files, err := h.recordFiles(recordType)
 	if err != nil {
-		fmt.Printf("序列化历史记录失败: %v\n", err)
-		return
+		return nil, err
 	}
 
-	if err := os.WriteFile(filePath, data, 0644); err != nil {
-		fmt.Printf("写入历史记录文件失败: %v\n", err)
+	var records []*HistoryRecord
+	for _, filePath := range files {
+		fileRecords, err := h.readRecords(filePath)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, fileRecords...)
 	}
+
+	return records, nil
 }
 
 // 全局历史记录服务实例
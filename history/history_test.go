@@ -1,7 +1,12 @@
 package history
 
 import (
+	"bytes"
+	"fmt"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -14,7 +19,7 @@ func TestSaveTranslateRecord(t *testing.T) {
 	}
 
 	// 测试保存翻译记录
-	service.SaveTranslateRecord("Hello world", "你好世界", "en", "zh")
+	service.SaveTranslateRecord("Hello world", "你好世界", "en", "zh", "baidu")
 
 	// 检查文件是否创建
 	date := time.Now().Format("2006-01-02")
@@ -37,7 +42,7 @@ func TestSaveExplainRecord(t *testing.T) {
 	}
 
 	// 测试保存解释记录
-	service.SaveExplainRecord("machine learning", "机器学习是人工智能的一个分支...", "template1")
+	service.SaveExplainRecord("machine learning", "机器学习是人工智能的一个分支...", "template1", "deepseek")
 
 	// 检查文件是否创建
 	date := time.Now().Format("2006-01-02")
@@ -60,7 +65,7 @@ func TestDisabledHistoryService(t *testing.T) {
 	}
 
 	// 测试禁用状态下不保存记录
-	service.SaveTranslateRecord("Hello", "你好", "en", "zh")
+	service.SaveTranslateRecord("Hello", "你好", "en", "zh", "baidu")
 
 	// 检查文件是否未创建
 	date := time.Now().Format("2006-01-02")
@@ -69,3 +74,375 @@ func TestDisabledHistoryService(t *testing.T) {
 		t.Errorf("历史记录功能禁用时不应该创建文件: %s", filePath)
 	}
 }
+
+func TestComputeStats(t *testing.T) {
+	day1 := time.Date(2024, 1, 15, 10, 0, 0, 0, time.Local)
+	day2 := day1.AddDate(0, 0, 1)
+	records := []*HistoryRecord{
+		{Type: "translate", SourceText: "Hello", Result: "你好", FromLang: "en", ToLang: "zh", Provider: "baidu", Timestamp: day1},
+		{Type: "translate", SourceText: "hello ", Result: "你好", FromLang: "en", ToLang: "zh", Provider: "baidu", Timestamp: day1},
+		{Type: "translate", SourceText: "hello", Result: "你好", FromLang: "en", ToLang: "zh", Provider: "deepseek", Timestamp: day2},
+		{Type: "translate", SourceText: "世界", Result: "world", FromLang: "zh", ToLang: "en", Provider: "baidu", Timestamp: day2},
+		{Type: "explain", SourceText: "CPU", Result: "中央处理器", TemplateID: "programmer", Provider: "deepseek", Timestamp: day2},
+	}
+
+	stats := ComputeStats(records, 1, 2)
+
+	if stats.TotalLookups != 5 {
+		t.Errorf("总查询次数期望 5，实际 %d", stats.TotalLookups)
+	}
+	if len(stats.LookupsPerDay) != 2 || stats.LookupsPerDay[0].Count != 2 || stats.LookupsPerDay[1].Count != 3 {
+		t.Errorf("每日查询统计错误: %+v", stats.LookupsPerDay)
+	}
+	if len(stats.TopWords) != 1 || stats.TopWords[0].Text != "Hello" || stats.TopWords[0].Count != 3 {
+		t.Errorf("高频词统计错误: %+v", stats.TopWords)
+	}
+	if stats.LanguagePairs["en->zh"] != 3 || stats.LanguagePairs["zh->en"] != 1 {
+		t.Errorf("语言对统计错误: %+v", stats.LanguagePairs)
+	}
+	if stats.Providers["baidu"] != 3 || stats.Providers["deepseek"] != 2 {
+		t.Errorf("翻译服务统计错误: %+v", stats.Providers)
+	}
+	if len(stats.VocabularyCandidates) != 1 || stats.VocabularyCandidates[0].Count != 3 {
+		t.Errorf("单词本候选统计错误: %+v", stats.VocabularyCandidates)
+	}
+	// (2+2+2+5+5)/5
+	if stats.AverageResultLength != 3.2 {
+		t.Errorf("平均结果长度期望 3.2，实际 %v", stats.AverageResultLength)
+	}
+}
+
+func TestMergeRepeatedRecords(t *testing.T) {
+	service := &HistoryService{
+		enabled:     true,
+		storagePath: "./test_data",
+	}
+	defer os.RemoveAll("./test_data")
+
+	// 同一文本重复翻译十次，其中一次翻译服务给出不同结果
+	for i := 0; i < 9; i++ {
+		service.SaveTranslateRecord("Hello", "你好", "en", "zh", "baidu")
+	}
+	service.SaveTranslateRecord("Hello", "您好", "en", "zh", "deepseek")
+	// 不同语言对不合并
+	service.SaveTranslateRecord("Hello", "Bonjour", "en", "fr", "baidu")
+
+	records, err := service.LoadRecords("translate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("期望合并为 2 条记录，实际 %d", len(records))
+	}
+
+	merged := records[0]
+	if merged.Count != 10 {
+		t.Errorf("期望次数 10，实际 %d", merged.Count)
+	}
+	if merged.Result != "您好" || merged.Provider != "deepseek" {
+		t.Errorf("结果应为最近一次: %+v", merged)
+	}
+	if len(merged.Results) != 2 {
+		t.Errorf("应保留所有不同的结果: %v", merged.Results)
+	}
+	if merged.FirstSeen.After(merged.LastSeen) {
+		t.Errorf("first_seen 不应晚于 last_seen")
+	}
+}
+
+func TestDedupExistingFile(t *testing.T) {
+	service := &HistoryService{
+		enabled:     true,
+		storagePath: "./test_data",
+	}
+	defer os.RemoveAll("./test_data")
+
+	// 模拟旧版本逐条追加的文件
+	day := time.Date(2024, 1, 15, 10, 0, 0, 0, time.Local)
+	filePath := "./test_data/history/translate/2024-01-15.json"
+	if err := os.MkdirAll("./test_data/history/translate", 0755); err != nil {
+		t.Fatal(err)
+	}
+	old := []*HistoryRecord{
+		{ID: "1", Type: "translate", SourceText: "apple", Result: "苹果", FromLang: "en", ToLang: "zh", Timestamp: day},
+		{ID: "2", Type: "translate", SourceText: "apple", Result: "苹果", FromLang: "en", ToLang: "zh", Timestamp: day.Add(time.Minute)},
+		{ID: "3", Type: "translate", SourceText: "pear", Result: "梨", FromLang: "en", ToLang: "zh", Timestamp: day.Add(2 * time.Minute)},
+	}
+	if err := service.writeRecords(filePath, old); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := service.Dedup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 1 {
+		t.Errorf("期望合并 1 条记录，实际 %d", removed)
+	}
+
+	records, err := service.readRecords(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].ID != "1" || records[0].Count != 2 {
+		t.Errorf("整理结果错误: %+v", records[0])
+	}
+	if !records[0].LastSeen.Equal(day.Add(time.Minute)) {
+		t.Errorf("last_seen 应为最后一次查询时间: %v", records[0].LastSeen)
+	}
+}
+
+func TestLookupsPerDayAcrossDays(t *testing.T) {
+	service := &HistoryService{
+		enabled:     true,
+		storagePath: "./test_data",
+	}
+	defer os.RemoveAll("./test_data")
+
+	// 之前某一天查询过一次的文本，今天又查询了两次
+	day := time.Date(2024, 1, 15, 10, 0, 0, 0, time.Local)
+	if err := os.MkdirAll("./test_data/history/translate", 0755); err != nil {
+		t.Fatal(err)
+	}
+	old := []*HistoryRecord{
+		{ID: "1", Type: "translate", SourceText: "apple", Result: "苹果", FromLang: "en", ToLang: "zh", Timestamp: day},
+	}
+	if err := service.writeRecords("./test_data/history/translate/2024-01-15.json", old); err != nil {
+		t.Fatal(err)
+	}
+	service.SaveTranslateRecord("apple", "苹果", "en", "zh", "baidu")
+	service.SaveTranslateRecord("apple", "苹果", "en", "zh", "baidu")
+
+	stats, err := service.Stats(10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []DayCount{{Date: "2024-01-15", Count: 1}, {Date: time.Now().Format("2006-01-02"), Count: 2}}
+	if !reflect.DeepEqual(stats.LookupsPerDay, want) {
+		t.Errorf("每天的查询次数应按查询当天计算: %+v", stats.LookupsPerDay)
+	}
+	if len(stats.TopWords) != 1 || stats.TopWords[0].Count != 3 || stats.TotalLookups != 3 {
+		t.Errorf("高频词应累加各天的查询次数: %+v", stats.TopWords)
+	}
+}
+
+func TestEncryptedHistory(t *testing.T) {
+	service := &HistoryService{
+		enabled:     true,
+		storagePath: "./test_data",
+		encrypted:   true,
+	}
+	defer os.RemoveAll("./test_data")
+
+	// 未解锁时记录暂存在内存中
+	service.SaveTranslateRecord("secret code", "机密代码", "en", "zh", "baidu")
+	if !service.Locked() {
+		t.Fatal("未解锁时应处于锁定状态")
+	}
+	if n := service.Pending(); n != 1 {
+		t.Errorf("期望暂存 1 条记录，实际 %d", n)
+	}
+	if _, err := service.LoadRecords("translate"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := service.Unlock("passphrase"); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := service.Pending(); n != 0 {
+		t.Errorf("解锁后不应有暂存记录，实际 %d", n)
+	}
+
+	date := time.Now().Format("2006-01-02")
+	filePath := "./test_data/history/translate/" + date + ".json"
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("解锁后暂存记录应写入文件: %v", err)
+	}
+	if strings.Contains(string(data), "secret code") {
+		t.Error("文件中不应出现明文")
+	}
+
+	// 新会话：口令错误 / 未解锁 / 正确口令
+	reopened := &HistoryService{enabled: true, storagePath: "./test_data", encrypted: true}
+	if _, err := reopened.LoadRecords("translate"); err != ErrLocked {
+		t.Errorf("未解锁读取应返回 ErrLocked，实际 %v", err)
+	}
+	if err := reopened.Unlock("wrong"); err != ErrWrongPassphrase {
+		t.Errorf("错误口令应返回 ErrWrongPassphrase，实际 %v", err)
+	}
+	if err := reopened.Unlock("passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	records, err := reopened.LoadRecords("translate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].SourceText != "secret code" {
+		t.Errorf("解密结果错误: %+v", records)
+	}
+
+	// 更换口令后旧口令失效
+	if err := reopened.Rekey("passphrase", "new passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	tool := NewHistoryServiceWithPath("./test_data")
+	if _, err := tool.ExportPlain("passphrase", "./test_data/export"); err != ErrWrongPassphrase {
+		t.Errorf("更换口令后旧口令应失效，实际 %v", err)
+	}
+	exported, err := tool.ExportPlain("new passphrase", "./test_data/export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exported != 1 {
+		t.Errorf("期望导出 1 个文件，实际 %d", exported)
+	}
+	plain, err := os.ReadFile("./test_data/export/history/translate/" + date + ".json")
+	if err != nil || !strings.Contains(string(plain), "secret code") {
+		t.Errorf("导出文件应为明文: %v", err)
+	}
+}
+
+func TestUnlockEncryptsPlainFiles(t *testing.T) {
+	dir := t.TempDir()
+	plain := &HistoryService{enabled: true, storagePath: dir}
+	plain.SaveTranslateRecord("plain text", "明文", "en", "zh", "baidu")
+	plain.SaveExplainRecord("API", "接口", "default", "deepseek")
+
+	// 开启加密后首次解锁，已有的明文文件一并加密
+	service := &HistoryService{enabled: true, storagePath: dir, encrypted: true}
+	if err := service.Unlock("passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	files, err := service.allRecordFiles()
+	if err != nil || len(files) != 2 {
+		t.Fatalf("期望 2 个记录文件，实际 %v %v", files, err)
+	}
+	for _, filePath := range files {
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(data, encryptedMagic) {
+			t.Errorf("首次解锁后 %s 应已加密", filePath)
+		}
+	}
+
+	reopened := &HistoryService{enabled: true, storagePath: dir, encrypted: true}
+	if err := reopened.Unlock("passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	records, err := reopened.LoadRecords("translate")
+	if err != nil || len(records) != 1 || records[0].SourceText != "plain text" {
+		t.Errorf("加密后读取结果错误: %+v %v", records, err)
+	}
+}
+
+func TestSetStorage(t *testing.T) {
+	dirA, dirB := t.TempDir(), t.TempDir()
+	service := &HistoryService{enabled: true, storagePath: dirA, encrypted: true}
+	if err := service.Unlock("passphrase"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 切换目录后需要重新解锁，之后的记录写入新目录
+	service.SetStorage(dirB, true)
+	if service.StoragePath() != dirB || !service.Locked() {
+		t.Errorf("切换目录后应使用新目录并重新锁定: %s %v", service.StoragePath(), service.Locked())
+	}
+	service.SaveTranslateRecord("hello", "你好", "en", "zh", "baidu")
+	if err := service.Unlock("passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	files, err := service.allRecordFiles()
+	if err != nil || len(files) != 1 || !strings.HasPrefix(files[0], dirB) {
+		t.Errorf("记录应写入新目录: %v %v", files, err)
+	}
+
+	// 关闭加密后不再需要解锁
+	service.SetStorage(dirA, false)
+	if service.Locked() {
+		t.Error("关闭加密后不应处于锁定状态")
+	}
+}
+
+func TestRekeyFailureKeepsSession(t *testing.T) {
+	dir := t.TempDir()
+	service := NewHistoryServiceWithPath(dir)
+	if err := service.Rekey("", "passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	service.SaveTranslateRecord("secret code", "机密代码", "en", "zh", "baidu")
+
+	// 口令错误时不应影响已解锁的会话
+	if err := service.Rekey("wrong", "new passphrase"); err != ErrWrongPassphrase {
+		t.Errorf("错误口令应返回 ErrWrongPassphrase，实际 %v", err)
+	}
+	if _, err := service.ExportPlain("wrong", filepath.Join(dir, "export")); err != ErrWrongPassphrase {
+		t.Errorf("错误口令应返回 ErrWrongPassphrase，实际 %v", err)
+	}
+	if service.Locked() {
+		t.Fatal("口令错误后会话不应被锁定")
+	}
+	if records, err := service.LoadRecords("translate"); err != nil || len(records) != 1 {
+		t.Errorf("口令错误后应仍能读取记录: %v %v", records, err)
+	}
+}
+
+func TestRecoverInterruptedRekey(t *testing.T) {
+	dir := t.TempDir()
+	service := NewHistoryServiceWithPath(dir)
+	if err := service.Rekey("", "old passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2024, 1, 15, 10, 0, 0, 0, time.Local)
+	files := []string{
+		filepath.Join(dir, "history", "translate", "2024-01-15.json"),
+		filepath.Join(dir, "history", "translate", "2024-01-16.json"),
+	}
+	for i, filePath := range files {
+		service.mergeRecordLocked(filePath, &HistoryRecord{
+			ID: fmt.Sprint(i), Type: "translate", SourceText: fmt.Sprint("word", i), Result: "词",
+			FromLang: "en", ToLang: "zh", Timestamp: day.AddDate(0, 0, i),
+		})
+	}
+
+	// 模拟重新加密在提交前中断：新文件和 key.json.new 已写入，第一个文件已替换
+	newKeyBytes, newKF, err := newKey("new passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, filePath := range files {
+		records, err := service.readRecords(filePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := writeRecordFile(filePath+".tmp", records, newKeyBytes); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writeJSONFile(service.keyFilePath()+".new", newKF); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(files[0], files[0]+".old"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(files[0]+".tmp", files[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重新启动后回滚，旧口令仍可读取全部记录
+	reopened := NewHistoryServiceWithPath(dir)
+	if err := reopened.Unlock("old passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	records, err := reopened.LoadRecords("translate")
+	if err != nil || len(records) != 2 {
+		t.Fatalf("回滚后应能用旧口令读取全部记录: %v %v", records, err)
+	}
+	leftovers, _ := filepath.Glob(filepath.Join(dir, "history", "translate", "*.json.*"))
+	if _, err := os.Stat(reopened.keyFilePath() + ".new"); !os.IsNotExist(err) || len(leftovers) != 0 {
+		t.Errorf("回滚后不应残留临时文件: %v", leftovers)
+	}
+}
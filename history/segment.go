@@ -0,0 +1,123 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// segmentPattern 分段名称：<类型>/<日期>.json，同时用于防止路径穿越
+var segmentPattern = regexp.MustCompile(`^(translate|explain)/\d{4}-\d{2}-\d{2}\.json$`)
+
+// ValidSegment 判断分段名称是否合法
+func ValidSegment(name string) bool {
+	return segmentPattern.MatchString(name)
+}
+
+// Segments 返回全部历史记录分段（即每天一个的记录文件）的相对路径，形如 "translate/2024-01-15.json"
+func (h *HistoryService) Segments() ([]string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	files, err := h.allRecordFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := path.Join(h.storagePath, "history") + "/"
+	var segments []string
+	for _, filePath := range files {
+		name := strings.TrimPrefix(filePath, prefix)
+		if ValidSegment(name) {
+			segments = append(segments, name)
+		}
+	}
+	return segments, nil
+}
+
+// ReadSegment 读取分段中的记录，分段不存在时返回空
+func (h *HistoryService) ReadSegment(name string) ([]*HistoryRecord, error) {
+	if !ValidSegment(name) {
+		return nil, fmt.Errorf("history: invalid segment %q", name)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.readRecords(h.segmentPath(name))
+}
+
+// UpdateSegment 在持有锁的情况下读取分段、调用 update 并写回，返回写入的记录
+func (h *HistoryService) UpdateSegment(name string, update func([]*HistoryRecord) []*HistoryRecord) ([]*HistoryRecord, error) {
+	if !ValidSegment(name) {
+		return nil, fmt.Errorf("history: invalid segment %q", name)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.encrypted && h.key == nil {
+		return nil, ErrLocked
+	}
+
+	filePath := h.segmentPath(name)
+	records, err := h.readRecords(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	records = update(records)
+	if err := os.MkdirAll(path.Dir(filePath), 0755); err != nil {
+		return nil, err
+	}
+	if err := h.writeRecords(filePath, records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// EncodeSegment 按本地存储的格式（开启加密时为密文）编码记录，用于上传到远端
+func (h *HistoryService) EncodeSegment(records []*HistoryRecord) ([]byte, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return h.encode(data)
+}
+
+// DecodeSegment 解码 EncodeSegment 的输出，兼容未加密的数据
+func (h *HistoryService) DecodeSegment(data []byte) ([]*HistoryRecord, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	data, err := h.decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*HistoryRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		normalizeRecord(record)
+	}
+	return records, nil
+}
+
+// StoragePath 历史记录存储目录
+func (h *HistoryService) StoragePath() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.storagePath
+}
+
+func (h *HistoryService) segmentPath(name string) string {
+	return path.Join(h.storagePath, "history", name)
+}
@@ -0,0 +1,139 @@
+package history
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// DayCount 每日查询次数
+type DayCount struct {
+	Date  string `json:"date"`
+	Count int    `json:"count"`
+}
+
+// WordCount 词语及其查询次数
+type WordCount struct {
+	Text  string `json:"text"`
+	Count int    `json:"count"`
+}
+
+// Stats 历史记录统计结果
+type Stats struct {
+	TotalLookups         int            `json:"total_lookups"`
+	LookupsPerDay        []DayCount     `json:"lookups_per_day"`
+	TopWords             []WordCount    `json:"top_words"`
+	LanguagePairs        map[string]int `json:"language_pairs"` // 形如 "en->zh"
+	Providers            map[string]int `json:"providers"`
+	AverageResultLength  float64        `json:"average_result_length"` // 按字符数计算
+	VocabularyCandidates []WordCount    `json:"vocabulary_candidates"` // 查询次数超过阈值的翻译词语
+}
+
+// ComputeStats 统计历史记录，topN 为高频词数量，查询次数超过 candidateThreshold 的翻译词语会被标记为单词本候选
+func ComputeStats(records []*HistoryRecord, topN, candidateThreshold int) *Stats {
+	stats := &Stats{
+		LookupsPerDay:        []DayCount{},
+		TopWords:             []WordCount{},
+		LanguagePairs:        map[string]int{},
+		Providers:            map[string]int{},
+		VocabularyCandidates: []WordCount{},
+	}
+
+	perDay := map[string]int{}
+	words := map[string]*WordCount{}
+	translateWords := map[string]*WordCount{}
+	resultLength := 0
+	resultCount := 0
+
+	for _, record := range records {
+		// 合并后的记录按查询次数计数，旧记录没有 count 字段视为 1 次
+		count := max(record.Count, 1)
+		stats.TotalLookups += count
+		perDay[record.Timestamp.Format("2006-01-02")] += count
+
+		if record.Provider != "" {
+			stats.Providers[record.Provider] += count
+		}
+
+		if record.Result != "" {
+			resultLength += utf8.RuneCountInString(record.Result)
+			resultCount++
+		}
+
+		key := normalizeText(record.SourceText)
+		if key == "" {
+			continue
+		}
+		countWord(words, key, record.SourceText, count)
+
+		if record.Type == "translate" {
+			stats.LanguagePairs[record.FromLang+"->"+record.ToLang] += count
+			countWord(translateWords, key, record.SourceText, count)
+		}
+	}
+
+	for date, count := range perDay {
+		stats.LookupsPerDay = append(stats.LookupsPerDay, DayCount{Date: date, Count: count})
+	}
+	sort.Slice(stats.LookupsPerDay, func(i, j int) bool {
+		return stats.LookupsPerDay[i].Date < stats.LookupsPerDay[j].Date
+	})
+
+	stats.TopWords = sortedWordCounts(words)
+	if topN > 0 && len(stats.TopWords) > topN {
+		stats.TopWords = stats.TopWords[:topN]
+	}
+
+	for _, wc := range sortedWordCounts(translateWords) {
+		if wc.Count > candidateThreshold {
+			stats.VocabularyCandidates = append(stats.VocabularyCandidates, wc)
+		}
+	}
+
+	if resultCount > 0 {
+		stats.AverageResultLength = float64(resultLength) / float64(resultCount)
+	}
+
+	return stats
+}
+
+// Stats 读取全部翻译和解释历史并统计
+func (h *HistoryService) Stats(topN, candidateThreshold int) (*Stats, error) {
+	var records []*HistoryRecord
+	for _, recordType := range []string{"translate", "explain"} {
+		typeRecords, err := h.LoadRecords(recordType)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, typeRecords...)
+	}
+	return ComputeStats(records, topN, candidateThreshold), nil
+}
+
+// normalizeText 统计时忽略大小写和首尾空白
+func normalizeText(text string) string {
+	return strings.ToLower(strings.TrimSpace(text))
+}
+
+func countWord(words map[string]*WordCount, key, text string, count int) {
+	if wc, ok := words[key]; ok {
+		wc.Count += count
+		return
+	}
+	words[key] = &WordCount{Text: strings.TrimSpace(text), Count: count}
+}
+
+// sortedWordCounts 按次数降序排列，次数相同按文本排序
+func sortedWordCounts(words map[string]*WordCount) []WordCount {
+	list := make([]WordCount, 0, len(words))
+	for _, wc := range words {
+		list = append(list, *wc)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		return list[i].Text < list[j].Text
+	})
+	return list
+}
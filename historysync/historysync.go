@@ -0,0 +1,480 @@
+// Package historysync 将历史记录分段同步到 WebDAV 或 S3 兼容的远端存储，多台机器共享一份历史记录
+package historysync
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"handy-translate/config"
+	"handy-translate/history"
+)
+
+var (
+	// ErrNotExist 远端对象不存在
+	ErrNotExist = errors.New("historysync: object does not exist")
+	// ErrKeyMismatch 远端的历史记录使用另一份密钥（或本地未加密），需调用 AdoptRemoteKey 以口令改用远端的密钥
+	ErrKeyMismatch = errors.New("historysync: remote history uses a different key")
+)
+
+// keyObject 远端保存密钥文件的对象名，开启加密时所有机器共用这份密钥文件中的盐
+const keyObject = "key.json"
+
+// Object 远端对象，Name 为历史记录分段名称
+type Object struct {
+	Name string
+	ETag string
+}
+
+// Remote 远端存储
+type Remote interface {
+	List(ctx context.Context) ([]Object, error)
+	Get(ctx context.Context, name string) (data []byte, etag string, err error)
+	Put(ctx context.Context, name string, data []byte) (etag string, err error)
+}
+
+// NewRemote 根据配置创建远端存储
+func NewRemote(cfg config.SyncConfig) (Remote, error) {
+	switch cfg.Type {
+	case "webdav":
+		return NewWebDAV(cfg.URL, cfg.Username, cfg.Password), nil
+	case "s3":
+		return NewS3(cfg.URL, cfg.Bucket, cfg.Region, cfg.AccessKey, cfg.SecretKey, cfg.Prefix), nil
+	default:
+		return nil, fmt.Errorf("historysync: unknown remote type %q", cfg.Type)
+	}
+}
+
+// Result 一次同步的结果
+type Result struct {
+	Pulled   int       `json:"pulled"`  // 本地有更新的分段数
+	Pushed   int       `json:"pushed"`  // 上传的分段数
+	Skipped  int       `json:"skipped"` // 两端都未变化的分段数
+	LastSync time.Time `json:"last_sync"`
+}
+
+// segmentState 上次同步完成时分段的状态
+type segmentState struct {
+	Hash string `json:"hash"` // 合并后记录的哈希，此时两端内容一致
+	ETag string `json:"etag"` // 远端对象的 ETag
+}
+
+// state 同步记录，保存在 storage_path/history/sync_state.json
+type state struct {
+	LastSync time.Time               `json:"last_sync"`
+	KeySalt  string                  `json:"key_salt,omitempty"` // 上次同步时远端密钥文件的盐，未加密时为空
+	Segments map[string]segmentState `json:"segments"`
+}
+
+var (
+	globalMu     sync.Mutex
+	globalSyncer *Syncer            // 全局同步器实例，未开启同步时为 nil
+	stopRun      context.CancelFunc // 停止当前同步器的定期同步
+)
+
+// Global 返回当前的同步器，未开启同步时返回 nil
+func Global() *Syncer {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	return globalSyncer
+}
+
+// Start 按配置创建全局同步器并开始定期同步，启动时和 [sync] 修改后调用。
+// 之前的同步器先停止；未开启同步或创建失败时全局同步器为 nil
+func Start(h *history.HistoryService, cfg config.SyncConfig) error {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+
+	if stopRun != nil {
+		stopRun()
+		stopRun = nil
+	}
+	globalSyncer = nil
+	if !cfg.Enabled {
+		return nil
+	}
+
+	remote, err := NewRemote(cfg)
+	if err != nil {
+		return err
+	}
+	globalSyncer = NewSyncer(h, remote)
+	if cfg.Interval > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		stopRun = cancel
+		go globalSyncer.Run(ctx, time.Duration(cfg.Interval)*time.Minute)
+	}
+	return nil
+}
+
+// Syncer 历史记录同步器
+type Syncer struct {
+	mu      sync.Mutex
+	history *history.HistoryService
+	remote  Remote
+}
+
+// NewSyncer 创建同步器
+func NewSyncer(h *history.HistoryService, remote Remote) *Syncer {
+	return &Syncer{history: h, remote: remote}
+}
+
+// Sync 双向同步：拉取远端有变化的分段与本地按记录 ID 合并，再把合并结果推送到远端
+func (s *Syncer) Sync(ctx context.Context) (*Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.history.Locked() {
+		return nil, history.ErrLocked
+	}
+
+	st, err := s.loadState()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.syncKey(ctx, st); err != nil {
+		return nil, err
+	}
+
+	remoteObjects, err := s.remote.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("列出远端分段失败: %w", err)
+	}
+	remoteETags := map[string]string{}
+	for _, obj := range remoteObjects {
+		if history.ValidSegment(obj.Name) {
+			remoteETags[obj.Name] = obj.ETag
+		}
+	}
+
+	localSegments, err := s.history.Segments()
+	if err != nil {
+		return nil, err
+	}
+
+	names := map[string]bool{}
+	for _, name := range localSegments {
+		names[name] = true
+	}
+	for name := range remoteETags {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	result := &Result{}
+	for _, name := range sorted {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		remoteETag, inRemote := remoteETags[name]
+		if err := s.syncSegment(ctx, st, name, inRemote, remoteETag, result); err != nil {
+			// 保存已完成分段的状态，下次从失败处继续
+			s.saveState(st)
+			return nil, fmt.Errorf("同步分段 %s 失败: %w", name, err)
+		}
+	}
+
+	st.LastSync = time.Now()
+	result.LastSync = st.LastSync
+	return result, s.saveState(st)
+}
+
+// LastSync 上次成功同步的时间
+func (s *Syncer) LastSync() (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, err := s.loadState()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return st.LastSync, nil
+}
+
+// syncKey 确认两端使用同一份密钥：远端没有密钥文件时上传本地的；上次同步后本机更换了口令时上传新的，
+// 远端分段会在之后的同步中以新密钥重新上传；其他情况下盐不同则返回 ErrKeyMismatch
+func (s *Syncer) syncKey(ctx context.Context, st *state) error {
+	local, err := s.history.KeyFile()
+	if err != nil {
+		return err
+	}
+	localSalt := ""
+	if local != nil {
+		if localSalt, err = history.KeyFileSalt(local); err != nil {
+			return err
+		}
+	}
+
+	remote, _, err := s.remote.Get(ctx, keyObject)
+	switch {
+	case errors.Is(err, ErrNotExist):
+		if local == nil {
+			return nil
+		}
+	case err != nil:
+		return fmt.Errorf("获取远端密钥文件失败: %w", err)
+	default:
+		remoteSalt, err := history.KeyFileSalt(remote)
+		if err != nil {
+			return err
+		}
+		if remoteSalt == localSalt {
+			st.KeySalt = localSalt
+			return nil
+		}
+		if localSalt == "" || remoteSalt != st.KeySalt {
+			return ErrKeyMismatch
+		}
+		slog.Info("historysync: 本机已更换口令，上传新的密钥文件")
+	}
+
+	if _, err := s.remote.Put(ctx, keyObject, local); err != nil {
+		return fmt.Errorf("上传密钥文件失败: %w", err)
+	}
+	if st.KeySalt != localSalt {
+		// 密钥变化后重新比较全部分段，以新密钥重新上传
+		st.Segments = map[string]segmentState{}
+	}
+	st.KeySalt = localSalt
+	return nil
+}
+
+// AdoptRemoteKey 以口令改用远端的密钥文件：本地记录用远端的密钥重新加密，之后的同步会重新比较全部分段。
+// 用于第二台机器首次同步，或其他机器更换口令之后
+func (s *Syncer) AdoptRemoteKey(ctx context.Context, passphrase string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, _, err := s.remote.Get(ctx, keyObject)
+	if err != nil {
+		if errors.Is(err, ErrNotExist) {
+			return errors.New("historysync: remote has no key file")
+		}
+		return err
+	}
+	salt, err := history.KeyFileSalt(data)
+	if err != nil {
+		return err
+	}
+	if err := s.history.AdoptKey(data, passphrase); err != nil {
+		return err
+	}
+
+	st, err := s.loadState()
+	if err != nil {
+		return err
+	}
+	st.KeySalt = salt
+	st.Segments = map[string]segmentState{}
+	return s.saveState(st)
+}
+
+func (s *Syncer) syncSegment(ctx context.Context, st *state, name string, inRemote bool, remoteETag string, result *Result) error {
+	local, err := s.history.ReadSegment(name)
+	if err != nil {
+		return err
+	}
+	localHash := hashRecords(local)
+	prev, synced := st.Segments[name]
+
+	remoteChanged := inRemote && (!synced || remoteETag == "" || prev.ETag != remoteETag)
+	if synced && !remoteChanged && inRemote && prev.Hash == localHash {
+		result.Skipped++
+		return nil
+	}
+
+	// 远端有变化时拉取，否则远端内容与上次同步的结果一致
+	var remote []*history.HistoryRecord
+	remoteHash := prev.Hash
+	stale := false
+	if remoteChanged {
+		data, etag, err := s.remote.Get(ctx, name)
+		if err != nil && !errors.Is(err, ErrNotExist) {
+			return err
+		}
+		if err == nil {
+			remote, err = s.history.DecodeSegment(data)
+			if errors.Is(err, history.ErrWrongKey) {
+				// 更换口令前上传的分段，其中的记录已在当时合并进上传它的机器，以本地内容覆盖
+				slog.Warn("historysync: 远端分段使用旧密钥，以本地内容覆盖", slog.String("segment", name))
+				remote, stale = nil, true
+			} else if err != nil {
+				return err
+			}
+			remoteETag = etag
+		}
+		remoteHash = hashRecords(remote)
+	} else if !inRemote {
+		remoteHash = ""
+	}
+
+	merged, err := s.history.UpdateSegment(name, func(records []*history.HistoryRecord) []*history.HistoryRecord {
+		return MergeByID(records, remote)
+	})
+	if err != nil {
+		return err
+	}
+	mergedHash := hashRecords(merged)
+	if mergedHash != localHash {
+		result.Pulled++
+	}
+
+	if mergedHash != remoteHash || (stale && len(merged) > 0) {
+		data, err := s.history.EncodeSegment(merged)
+		if err != nil {
+			return err
+		}
+		if remoteETag, err = s.remote.Put(ctx, name, data); err != nil {
+			return err
+		}
+		result.Pushed++
+	}
+
+	st.Segments[name] = segmentState{Hash: mergedHash, ETag: remoteETag}
+	return nil
+}
+
+// MergeByID 按记录 ID 合并两端的记录：同一 ID 取 last_seen 较新的一份，次数按设备合并，结果取并集。
+// 合并满足交换律、结合律和幂等性，两端以任意顺序同步都会得到相同结果
+func MergeByID(a, b []*history.HistoryRecord) []*history.HistoryRecord {
+	byID := map[string]*history.HistoryRecord{}
+	for _, list := range [][]*history.HistoryRecord{a, b} {
+		for _, record := range list {
+			existing, ok := byID[record.ID]
+			if !ok {
+				copied := *record
+				copied.Results = append([]string(nil), record.Results...)
+				if record.Counts != nil {
+					copied.Counts = make(map[string]int, len(record.Counts))
+					for device, n := range record.Counts {
+						copied.Counts[device] = n
+					}
+				}
+				byID[record.ID] = &copied
+				continue
+			}
+			mergeSameID(existing, record)
+		}
+	}
+
+	merged := make([]*history.HistoryRecord, 0, len(byID))
+	for _, record := range byID {
+		merged = append(merged, record)
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		if !merged[i].FirstSeen.Equal(merged[j].FirstSeen) {
+			return merged[i].FirstSeen.Before(merged[j].FirstSeen)
+		}
+		return merged[i].ID < merged[j].ID
+	})
+	return merged
+}
+
+func mergeSameID(dst, src *history.HistoryRecord) {
+	newer := src.LastSeen.After(dst.LastSeen) ||
+		(src.LastSeen.Equal(dst.LastSeen) && src.Result > dst.Result)
+	if newer {
+		dst.LastSeen = src.LastSeen
+		dst.Result = src.Result
+		dst.Provider = src.Provider
+	}
+	if src.FirstSeen.Before(dst.FirstSeen) {
+		dst.FirstSeen = src.FirstSeen
+		dst.Timestamp = src.Timestamp
+	}
+	history.MergeCounts(dst, src)
+
+	for _, result := range src.Results {
+		found := false
+		for _, existing := range dst.Results {
+			if existing == result {
+				found = true
+				break
+			}
+		}
+		if !found {
+			dst.Results = append(dst.Results, result)
+		}
+	}
+	sort.Strings(dst.Results)
+}
+
+// hashRecords 记录内容的哈希（按明文计算，与是否加密无关）
+func hashRecords(records []*history.HistoryRecord) string {
+	if len(records) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(records)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Run 按间隔定期同步，ctx 取消时退出
+func (s *Syncer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			result, err := s.Sync(ctx)
+			if err != nil {
+				slog.Error("historysync: 自动同步失败", slog.Any("err", err))
+				continue
+			}
+			slog.Info("historysync: 自动同步完成",
+				slog.Int("pulled", result.Pulled),
+				slog.Int("pushed", result.Pushed))
+		}
+	}
+}
+
+func (s *Syncer) statePath() string {
+	return path.Join(s.history.StoragePath(), "history", "sync_state.json")
+}
+
+func (s *Syncer) loadState() (*state, error) {
+	st := &state{Segments: map[string]segmentState{}}
+
+	data, err := os.ReadFile(s.statePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return st, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, fmt.Errorf("解析同步记录失败: %w", err)
+	}
+	if st.Segments == nil {
+		st.Segments = map[string]segmentState{}
+	}
+	return st, nil
+}
+
+func (s *Syncer) saveState(st *state) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path.Dir(s.statePath()), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(s.statePath(), data, 0644)
+}
@@ -0,0 +1,250 @@
+package historysync
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3 S3 兼容的远端存储（AWS S3、MinIO 等），使用 path-style 地址和 Signature V4 签名
+type S3 struct {
+	endpoint  string
+	bucket    string
+	region    string
+	accessKey string
+	secretKey string
+	prefix    string
+	client    *http.Client
+	now       func() time.Time
+}
+
+// NewS3 创建 S3 远端存储，prefix 为对象键前缀，例如 "handy-translate/"
+func NewS3(endpoint, bucket, region, accessKey, secretKey, prefix string) *S3 {
+	if region == "" {
+		region = "us-east-1"
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return &S3{
+		endpoint:  strings.TrimRight(endpoint, "/"),
+		bucket:    bucket,
+		region:    region,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		prefix:    prefix,
+		client:    &http.Client{Timeout: 30 * time.Second},
+		now:       time.Now,
+	}
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key  string `xml:"Key"`
+		ETag string `xml:"ETag"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List 列出远端全部分段（ListObjectsV2）
+func (s *S3) List(ctx context.Context) ([]Object, error) {
+	var objects []Object
+	token := ""
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", s.prefix)
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := s.do(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("ListObjectsV2: %s: %s", resp.Status, body)
+		}
+
+		var result listBucketResult
+		if err := xml.Unmarshal(body, &result); err != nil {
+			return nil, err
+		}
+		for _, c := range result.Contents {
+			objects = append(objects, Object{
+				Name: strings.TrimPrefix(c.Key, s.prefix),
+				ETag: c.ETag,
+			})
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// Get 下载分段
+func (s *S3) Get(ctx context.Context, name string) ([]byte, string, error) {
+	resp, err := s.do(ctx, http.MethodGet, s.prefix+name, nil, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, "", ErrNotExist
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("GetObject %s: %s: %s", name, resp.Status, data)
+	}
+	return data, resp.Header.Get("ETag"), nil
+}
+
+// Put 上传分段
+func (s *S3) Put(ctx context.Context, name string, data []byte) (string, error) {
+	resp, err := s.do(ctx, http.MethodPut, s.prefix+name, nil, data)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("PutObject %s: %s: %s", name, resp.Status, body)
+	}
+	return resp.Header.Get("ETag"), nil
+}
+
+func (s *S3) do(ctx context.Context, method, key string, query url.Values, body []byte) (*http.Response, error) {
+	canonicalURI := "/" + s.bucket
+	if key != "" {
+		canonicalURI += "/" + uriEncode(key, false)
+	}
+	canonicalQuery := canonicalQueryString(query)
+
+	rawURL := s.endpoint + canonicalURI
+	if canonicalQuery != "" {
+		rawURL += "?" + canonicalQuery
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	s.sign(req, canonicalURI, canonicalQuery, body)
+	return s.client.Do(req)
+}
+
+// sign AWS Signature Version 4
+func (s *S3) sign(req *http.Request, canonicalURI, canonicalQuery string, body []byte) {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI,
+		canonicalQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	signingKey = hmacSHA256(signingKey, s.region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature))
+}
+
+// canonicalQueryString 按键排序并编码查询参数
+func canonicalQueryString(query url.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode 按 SigV4 要求编码，encodeSlash 为 false 时保留路径分隔符
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,194 @@
+package historysync
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// segmentDirs 远端的分段目录，与本地 history 目录结构一致
+var segmentDirs = []string{"translate", "explain"}
+
+// WebDAV WebDAV 远端存储，URL 指向存放历史记录的目录
+type WebDAV struct {
+	baseURL  string
+	username string
+	password string
+	client   *http.Client
+
+	mu      sync.Mutex
+	created map[string]bool // 已确认存在的目录
+}
+
+// NewWebDAV 创建 WebDAV 远端存储
+func NewWebDAV(baseURL, username, password string) *WebDAV {
+	return &WebDAV{
+		baseURL:  strings.TrimRight(baseURL, "/") + "/",
+		username: username,
+		password: password,
+		client:   &http.Client{Timeout: 30 * time.Second},
+		created:  map[string]bool{},
+	}
+}
+
+type multistatus struct {
+	Responses []struct {
+		Href     string `xml:"href"`
+		Propstat []struct {
+			Prop struct {
+				ETag         string `xml:"getetag"`
+				ResourceType struct {
+					Collection *struct{} `xml:"collection"`
+				} `xml:"resourcetype"`
+			} `xml:"prop"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:getetag/><d:resourcetype/></d:prop></d:propfind>`
+
+// List 列出远端全部分段
+func (w *WebDAV) List(ctx context.Context) ([]Object, error) {
+	var objects []Object
+	for _, dir := range segmentDirs {
+		resp, err := w.do(ctx, "PROPFIND", dir+"/", strings.NewReader(propfindBody), map[string]string{
+			"Depth":        "1",
+			"Content-Type": "application/xml",
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusNotFound {
+			continue
+		}
+		if resp.StatusCode != http.StatusMultiStatus {
+			return nil, fmt.Errorf("PROPFIND %s: %s", dir, resp.Status)
+		}
+
+		var ms multistatus
+		if err := xml.Unmarshal(body, &ms); err != nil {
+			return nil, err
+		}
+		for _, r := range ms.Responses {
+			href, err := url.PathUnescape(r.Href)
+			if err != nil {
+				href = r.Href
+			}
+
+			isDir, etag := false, ""
+			for _, ps := range r.Propstat {
+				if ps.Prop.ResourceType.Collection != nil {
+					isDir = true
+				}
+				if ps.Prop.ETag != "" {
+					etag = ps.Prop.ETag
+				}
+			}
+			if isDir || strings.HasSuffix(href, "/") {
+				continue
+			}
+			objects = append(objects, Object{Name: dir + "/" + path.Base(href), ETag: etag})
+		}
+	}
+	return objects, nil
+}
+
+// Get 下载分段
+func (w *WebDAV) Get(ctx context.Context, name string) ([]byte, string, error) {
+	resp, err := w.do(ctx, http.MethodGet, name, nil, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, "", ErrNotExist
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("GET %s: %s", name, resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, resp.Header.Get("ETag"), nil
+}
+
+// Put 上传分段，必要时先创建目录
+func (w *WebDAV) Put(ctx context.Context, name string, data []byte) (string, error) {
+	if err := w.mkcol(ctx, ""); err != nil {
+		return "", err
+	}
+	if dir := path.Dir(name); dir != "." {
+		if err := w.mkcol(ctx, dir+"/"); err != nil {
+			return "", err
+		}
+	}
+
+	resp, err := w.do(ctx, http.MethodPut, name, bytes.NewReader(data), map[string]string{
+		"Content-Type": "application/octet-stream",
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusNoContent {
+		return "", fmt.Errorf("PUT %s: %s", name, resp.Status)
+	}
+	return resp.Header.Get("ETag"), nil
+}
+
+// mkcol 创建目录，目录已存在时服务端返回 405
+func (w *WebDAV) mkcol(ctx context.Context, dir string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.created[dir] {
+		return nil
+	}
+
+	resp, err := w.do(ctx, "MKCOL", dir, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated, http.StatusOK, http.StatusMethodNotAllowed:
+		w.created[dir] = true
+		return nil
+	default:
+		return fmt.Errorf("MKCOL %s: %s", dir, resp.Status)
+	}
+}
+
+func (w *WebDAV) do(ctx context.Context, method, name string, body io.Reader, headers map[string]string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, w.baseURL+name, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	if w.username != "" {
+		req.SetBasicAuth(w.username, w.password)
+	}
+	return w.client.Do(req)
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
 	"embed"
 	_ "embed"
+	"flag"
 	"fmt"
 	"log"
 	"log/slog"
@@ -10,6 +12,10 @@ import (
 
 	"handy-translate/config"
 	"handy-translate/history"
+	"handy-translate/historysync"
+	"handy-translate/ocr"
+	"handy-translate/state"
+	"handy-translate/vocabulary"
 	"handy-translate/window/screenshot"
 	"handy-translate/window/toolbar"
 	"handy-translate/window/translate"
@@ -25,17 +31,28 @@ var iconlogo []byte
 
 var app *application.App
 
-var fromLang, toLang = "auto", "zh"
-
 var projectName = "handy-translate"
 
+var configPath = flag.String("config", "", "配置文件路径，默认依次查找环境变量 "+config.EnvConfigPath+"、用户配置目录和程序所在目录")
+
 func main() {
+	flag.Parse()
+
 	app = application.New(application.Options{
 		Name: projectName,
 		Services: []application.Service{
 			application.NewService(&App{}),
 		},
 		Icon: iconlogo,
+		// 退出时结束常驻的 OCR 进程，历史记录未解锁时记录丢失的暂存记录数
+		OnShutdown: func() {
+			if history.GlobalHistoryService != nil {
+				if n := history.GlobalHistoryService.Pending(); n > 0 {
+					log.Printf("历史记录未解锁，%d 条暂存记录未保存", n)
+				}
+			}
+			ocr.Close()
+		},
 		Assets: application.AssetOptions{
 			Handler: application.AssetFileServerFS(assets),
 		},
@@ -64,8 +81,9 @@ func main() {
 
 		if dataSlice, ok := event.Data.([]interface{}); ok {
 			if len(dataSlice) >= 2 {
-				fromLang = fmt.Sprintf("%v", dataSlice[0])
-				toLang = fmt.Sprintf("%v", dataSlice[1])
+				fromLang := fmt.Sprintf("%v", dataSlice[0])
+				toLang := fmt.Sprintf("%v", dataSlice[1])
+				setLanguages(fromLang, toLang)
 				app.Logger.Info("translateLang",
 					slog.String("fromLang", fromLang),
 					slog.String("toLang", toLang))
@@ -84,6 +102,7 @@ func main() {
 	// 系统托盘
 	systemTray := app.SystemTray.New()
 	myMenu := app.Menu.New()
+	trayMenu = myMenu
 
 	myMenu.Add("翻译").OnClick(func(ctx *application.Context) {
 		if translate.Window == nil {
@@ -100,28 +119,79 @@ func main() {
 	})
 
 	myMenu.Add("截图").OnClick(func(ctx *application.Context) {
-		screenshot.ScreenshotFullScreen()
-		base64Image := screenshot.ScreenshotFullScreen()
-		app.Event.Emit("screenshotBase64", base64Image)
+		startScreenshot()
 	})
 
+	profileMenu = myMenu.AddSubmenu("配置方案")
+
 	myMenu.Add("退出").OnClick(func(ctx *application.Context) {
-		app.Quit()
+		// 加密的历史记录未解锁时，暂存的记录只在内存中，退出前提醒
+		n := history.GlobalHistoryService.Pending()
+		if n == 0 {
+			app.Quit()
+			return
+		}
+		dialog := application.QuestionDialog().
+			SetTitle("历史记录未解锁").
+			SetMessage(fmt.Sprintf("有 %d 条历史记录暂存在内存中，解锁历史记录后才会保存，现在退出会丢失这些记录。", n))
+		dialog.AddButton("仍然退出").OnClick(app.Quit)
+		dialog.SetCancelButton(dialog.AddButton("取消"))
+		dialog.Show()
 	})
 
-	systemTray.SetMenu(myMenu)
-	systemTray.SetIcon(iconlogo)
-
 	systemTray.OnClick(func() {
 		toolbar.Window.Show()
 	})
 
 	// 初始化文件和鼠标事件
-	config.Init(projectName)
+	if err := config.Init(projectName, *configPath); err != nil {
+		log.Fatalf("加载配置失败: %v", err)
+	}
+
+	// 恢复上次的语言和工具栏模式
+	state.GlobalStateService = state.NewStateService()
+
+	// 托盘菜单中的配置方案依赖配置，加载配置后再设置托盘菜单
+	buildProfileMenu(config.Get())
+	systemTray.SetMenu(myMenu)
+	systemTray.SetIcon(iconlogo)
+
+	// 语言和工具栏模式在切换配置方案时已保存，只在没有保存的状态时（如首次启动）应用当前配置方案
+	if id := config.Get().Profile; id != "" && !state.GlobalStateService.Restored() {
+		applyProfile(id, config.Get().Profiles[id])
+	}
 
 	// 初始化历史记录服务
 	history.GlobalHistoryService = history.NewHistoryService()
 
+	// 初始化历史记录同步
+	if err := historysync.Start(history.GlobalHistoryService, config.Get().Sync); err != nil {
+		app.Logger.Error("初始化历史记录同步失败", slog.Any("err", err))
+	}
+
+	// 初始化单词本服务
+	vocabulary.GlobalVocabularyService = vocabulary.NewVocabularyService()
+
+	// 配置文件修改后自动生效
+	config.Subscribe(func(old, new *config.Config) {
+		history.GlobalHistoryService.SetEnabled(new.History.Enabled)
+		if old.History.StoragePath != new.History.StoragePath || old.History.Encrypted != new.History.Encrypted {
+			storagePath := config.ResolvePath(new.History.StoragePath)
+			history.GlobalHistoryService.SetStorage(storagePath, new.History.Encrypted)
+			vocabulary.GlobalVocabularyService.SetStoragePath(storagePath)
+		}
+		if old.Sync != new.Sync {
+			if err := historysync.Start(history.GlobalHistoryService, new.Sync); err != nil {
+				app.Logger.Error("重新初始化历史记录同步失败", slog.Any("err", err))
+			}
+		}
+		app.Event.Emit("config_changed", new.TranslateWay)
+		if profilesChanged(old, new) {
+			refreshProfileMenu(new)
+		}
+	})
+	go config.Watch(context.Background(), 2*time.Second)
+
 	go processHook()
 
 	err := app.Run()
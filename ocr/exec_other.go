@@ -0,0 +1,14 @@
+//go:build !windows
+
+package ocr
+
+import "os/exec"
+
+// 识别程序的默认文件名
+const (
+	rapidOCRName  = "RapidOCR-json"
+	tesseractName = "tesseract"
+)
+
+// hideWindow 其他平台运行控制台程序不会弹出窗口
+func hideWindow(cmd *exec.Cmd) {}
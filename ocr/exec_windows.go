@@ -0,0 +1,23 @@
+package ocr
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// 识别程序的默认文件名
+const (
+	rapidOCRName  = "RapidOCR-json.exe"
+	tesseractName = "tesseract.exe"
+)
+
+// createNoWindow CREATE_NO_WINDOW，不为控制台程序创建窗口
+const createNoWindow = 0x08000000
+
+// hideWindow 运行识别程序时不弹出控制台窗口
+func hideWindow(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		HideWindow:    true,
+		CreationFlags: createNoWindow,
+	}
+}
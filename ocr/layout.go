@@ -0,0 +1,261 @@
+package ocr
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 版面分析使用的阈值，均相对于行高的中位数
+const (
+	spanningRatio    = 0.6 // 宽度超过整体宽度的该比例时视为横跨多栏（如标题）
+	columnGapRatio   = 1.0 // 栏间空白至少为行高的倍数
+	sameLineRatio    = 0.5 // 两段文字垂直方向重叠超过较矮者高度的该比例时视为同一行
+	paragraphGap     = 0.8 // 行间距超过行高的倍数时视为新段落
+	shortLineRatio   = 0.7 // 行宽小于栏宽的该比例且以句末标点结尾时视为段落结束
+	wordSpaceRatio   = 0.3 // 同一行两段文字的间距超过行高的该比例时以空格连接
+	sentenceEndMarks = ".!?。！？:：;；"
+)
+
+// rect 文字块的外接矩形
+type rect struct {
+	minX, minY, maxX, maxY int
+}
+
+func (r rect) width() int   { return r.maxX - r.minX }
+func (r rect) height() int  { return r.maxY - r.minY }
+func (r rect) centerX() int { return (r.minX + r.maxX) / 2 }
+func (r rect) centerY() int { return (r.minY + r.maxY) / 2 }
+
+func (r rect) union(o rect) rect {
+	return rect{min(r.minX, o.minX), min(r.minY, o.minY), max(r.maxX, o.maxX), max(r.maxY, o.maxY)}
+}
+
+type layoutBlock struct {
+	rect
+	text string
+}
+
+// layoutLine 一行文字
+type layoutLine struct {
+	rect
+	blocks []layoutBlock
+}
+
+// LayoutText 按版面重建文字：丢弃置信度低于 minScore 的文字块，识别多栏，
+// 按阅读顺序（栏从左到右、栏内从上到下）排列，合并同一行的文字块，
+// 同一段落中的换行合并为一行（连字符断开的单词重新拼接），段落之间换行
+func (r OCRResult) LayoutText(minScore float64) string {
+	var blocks []layoutBlock
+	for _, b := range r.Blocks {
+		text := strings.TrimSpace(b.Text)
+		if text == "" || b.Score < minScore {
+			continue
+		}
+		blocks = append(blocks, layoutBlock{rect: boxRect(b.Box), text: text})
+	}
+	if len(blocks) == 0 {
+		return ""
+	}
+
+	lineHeight := medianHeight(blocks)
+	var paragraphs []string
+	for _, region := range readingRegions(blocks, lineHeight) {
+		paragraphs = append(paragraphs, layoutParagraphs(region, lineHeight)...)
+	}
+	return strings.Join(paragraphs, "\n")
+}
+
+func boxRect(box [4][2]int) rect {
+	r := rect{box[0][0], box[0][1], box[0][0], box[0][1]}
+	for _, p := range box[1:] {
+		r = r.union(rect{p[0], p[1], p[0], p[1]})
+	}
+	return r
+}
+
+func medianHeight(blocks []layoutBlock) int {
+	heights := make([]int, len(blocks))
+	for i, b := range blocks {
+		heights[i] = b.height()
+	}
+	sort.Ints(heights)
+	return max(heights[len(heights)/2], 1)
+}
+
+// readingRegions 将文字块按阅读顺序分为若干区域，每个区域为一栏中连续的文字。
+// 横跨多栏的文字块（如标题）将页面分为上下几部分，每部分中的栏从左到右排列
+func readingRegions(blocks []layoutBlock, lineHeight int) [][]layoutBlock {
+	page := blocks[0].rect
+	for _, b := range blocks[1:] {
+		page = page.union(b.rect)
+	}
+
+	var narrow, spanning []layoutBlock
+	for _, b := range blocks {
+		if float64(b.width()) > spanningRatio*float64(page.width()) {
+			spanning = append(spanning, b)
+		} else {
+			narrow = append(narrow, b)
+		}
+	}
+	columns := detectColumns(narrow, lineHeight)
+	if len(columns) <= 1 {
+		return [][]layoutBlock{blocks}
+	}
+
+	// 以横跨多栏的文字块为界，从上到下分段
+	sort.Slice(spanning, func(i, j int) bool { return spanning[i].minY < spanning[j].minY })
+	var regions [][]layoutBlock
+	top := page.minY - 1
+	for i := 0; i <= len(spanning); i++ {
+		bottom := page.maxY + 1
+		if i < len(spanning) {
+			bottom = spanning[i].centerY()
+		}
+		for _, col := range columns {
+			var region []layoutBlock
+			for _, b := range narrow {
+				if cy := b.centerY(); cy > top && cy <= bottom && b.centerX() >= col.minX && b.centerX() <= col.maxX {
+					region = append(region, b)
+				}
+			}
+			if len(region) > 0 {
+				regions = append(regions, region)
+			}
+		}
+		if i < len(spanning) {
+			regions = append(regions, []layoutBlock{spanning[i]})
+			top = bottom
+		}
+	}
+	return regions
+}
+
+// detectColumns 将文字块在水平方向上的投影合并为若干栏，栏间空白至少为 columnGapRatio 倍行高
+func detectColumns(blocks []layoutBlock, lineHeight int) []rect {
+	if len(blocks) == 0 {
+		return nil
+	}
+	sorted := append([]layoutBlock(nil), blocks...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].minX < sorted[j].minX })
+
+	minGap := int(columnGapRatio * float64(lineHeight))
+	columns := []rect{sorted[0].rect}
+	for _, b := range sorted[1:] {
+		last := &columns[len(columns)-1]
+		if b.minX-last.maxX < minGap {
+			*last = last.union(b.rect)
+		} else {
+			columns = append(columns, b.rect)
+		}
+	}
+	return columns
+}
+
+// layoutParagraphs 将一个区域中的文字块合并为行，再将行合并为段落
+func layoutParagraphs(blocks []layoutBlock, lineHeight int) []string {
+	lines := groupLines(blocks)
+
+	column := lines[0].rect
+	for _, l := range lines[1:] {
+		column = column.union(l.rect)
+	}
+
+	var paragraphs []string
+	var current string
+	for i, line := range lines {
+		text := joinLine(line, lineHeight)
+		if i == 0 {
+			current = text
+			continue
+		}
+		if newParagraph(lines[i-1], line, current, column, lineHeight) {
+			paragraphs = append(paragraphs, current)
+			current = text
+			continue
+		}
+		current = joinWrapped(current, text)
+	}
+	return append(paragraphs, current)
+}
+
+// groupLines 按垂直位置将文字块合并为行，行内按水平位置排序
+func groupLines(blocks []layoutBlock) []layoutLine {
+	sorted := append([]layoutBlock(nil), blocks...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].centerY() < sorted[j].centerY() })
+
+	var lines []layoutLine
+	for _, b := range sorted {
+		if n := len(lines); n > 0 && sameLine(lines[n-1].rect, b.rect) {
+			lines[n-1].blocks = append(lines[n-1].blocks, b)
+			lines[n-1].rect = lines[n-1].union(b.rect)
+			continue
+		}
+		lines = append(lines, layoutLine{rect: b.rect, blocks: []layoutBlock{b}})
+	}
+	for _, l := range lines {
+		sort.Slice(l.blocks, func(i, j int) bool { return l.blocks[i].minX < l.blocks[j].minX })
+	}
+	return lines
+}
+
+func sameLine(line, b rect) bool {
+	overlap := min(line.maxY, b.maxY) - max(line.minY, b.minY)
+	return float64(overlap) > sameLineRatio*float64(min(line.height(), b.height()))
+}
+
+// joinLine 连接同一行的文字块，间距较大时以空格分隔，中日韩文字之间不加空格
+func joinLine(line layoutLine, lineHeight int) string {
+	var b strings.Builder
+	for i, block := range line.blocks {
+		if i > 0 {
+			gap := block.minX - line.blocks[i-1].maxX
+			if float64(gap) > wordSpaceRatio*float64(lineHeight) && needsSpace(b.String(), block.text) {
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteString(block.text)
+	}
+	return b.String()
+}
+
+// newParagraph 判断 line 是否开始新段落：行间距较大，或上一行较短且以句末标点结尾
+func newParagraph(prev, line layoutLine, prevText string, column rect, lineHeight int) bool {
+	if float64(line.minY-prev.maxY) > paragraphGap*float64(lineHeight) {
+		return true
+	}
+	last, _ := utf8.DecodeLastRuneInString(prevText)
+	short := float64(prev.width()) < shortLineRatio*float64(column.width())
+	return short && strings.ContainsRune(sentenceEndMarks, last)
+}
+
+// joinWrapped 合并同一段落中被换行断开的两行：以连字符断开的单词直接拼接，
+// 中日韩文字之间不加空格，其他情况以空格连接
+func joinWrapped(prev, next string) string {
+	if strings.HasSuffix(prev, "-") {
+		before, _ := utf8.DecodeLastRuneInString(strings.TrimSuffix(prev, "-"))
+		first, _ := utf8.DecodeRuneInString(next)
+		if unicode.IsLetter(before) && unicode.IsLower(first) {
+			return strings.TrimSuffix(prev, "-") + next
+		}
+	}
+	if !needsSpace(prev, next) {
+		return prev + next
+	}
+	return prev + " " + next
+}
+
+// needsSpace 两段文字之间是否需要空格，中文、日文或全角标点相邻时不需要（韩文以空格分词，仍需要）
+func needsSpace(prev, next string) bool {
+	last, _ := utf8.DecodeLastRuneInString(prev)
+	first, _ := utf8.DecodeRuneInString(next)
+	return !isCJK(last) && !isCJK(first)
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana) ||
+		(r >= 0x3000 && r <= 0x303F) || // 中日韩标点
+		(r >= 0xFF00 && r <= 0xFFEF) // 全角字符
+}
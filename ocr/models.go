@@ -0,0 +1,191 @@
+package ocr
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"handy-translate/config"
+)
+
+// ErrUnknownModelSet 配置的模型组不在 configs.txt 中
+var ErrUnknownModelSet = errors.New("ocr: unknown model set")
+
+// ModelSetAuto 根据翻译的源语言自动选择模型组
+const ModelSetAuto = "auto"
+
+// modelSetsFile 模型目录中列出模型组的文件
+const modelSetsFile = "configs.txt"
+
+// ModelSet 一组识别模型：文字检测、方向分类、文字识别模型和识别字典，文件名相对于模型目录
+type ModelSet struct {
+	Name      string `json:"name"`
+	Det       string `json:"det"`
+	Cls       string `json:"cls"`
+	Rec       string `json:"rec"`
+	Keys      string `json:"keys"`
+	Available bool   `json:"available"` // 模型文件是否齐全
+}
+
+// args RapidOCR-json 使用该模型组的命令行参数
+func (m ModelSet) args(dir string) []string {
+	return []string{
+		"--models=" + dir,
+		"--det=" + m.Det,
+		"--cls=" + m.Cls,
+		"--rec=" + m.Rec,
+		"--keys=" + m.Keys,
+	}
+}
+
+// language 模型组识别的语言，根据识别模型的文件名判断
+func (m ModelSet) language() string {
+	rec := strings.ToLower(m.Rec)
+	for _, l := range []struct{ keyword, lang string }{
+		{"chinese_cht", "cht"},
+		{"japan", "ja"},
+		{"korean", "ko"},
+		{"cyrillic", "ru"},
+		{"_en_", "en"},
+		{"ch_", "zh"},
+	} {
+		if strings.Contains(rec, l.keyword) {
+			return l.lang
+		}
+	}
+	return ""
+}
+
+// ParseModelSets 解析 configs.txt：每组模型之间以空行分隔，
+// 依次为名称、检测模型、方向分类模型、识别模型和字典
+func ParseModelSets(r io.Reader) ([]ModelSet, error) {
+	var sets []ModelSet
+	var fields []string
+	flush := func() error {
+		if len(fields) == 0 {
+			return nil
+		}
+		if len(fields) != 5 {
+			return fmt.Errorf("ocr: 模型组 %q 应为 5 行，实际为 %d 行", fields[0], len(fields))
+		}
+		sets = append(sets, ModelSet{Name: fields[0], Det: fields[1], Cls: fields[2], Rec: fields[3], Keys: fields[4]})
+		fields = nil
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if line == "" {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		fields = append(fields, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return sets, nil
+}
+
+// LoadModelSets 读取模型目录中的 configs.txt，并检查每组模型的文件是否齐全
+func LoadModelSets(dir string) ([]ModelSet, error) {
+	f, err := os.Open(filepath.Join(dir, modelSetsFile))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sets, err := ParseModelSets(f)
+	if err != nil {
+		return nil, err
+	}
+	for i, m := range sets {
+		sets[i].Available = true
+		for _, name := range []string{m.Det, m.Cls, m.Rec, m.Keys} {
+			if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+				sets[i].Available = false
+				break
+			}
+		}
+	}
+	return sets, nil
+}
+
+// ModelSets 当前配置下可选的模型组，模型目录为 ocr.models_dir，
+// 未配置时为 RapidOCR-json 所在目录下的 models
+func ModelSets() ([]ModelSet, error) {
+	cfg := config.Get().OCR
+	var path string
+	if cfg.ModelsDir == "" {
+		p, err := findExecutable(cfg.Path, rapidOCRName)
+		if err != nil {
+			return nil, fmt.Errorf("ocr: RapidOCR-json not found: %w", err)
+		}
+		path = p
+	}
+	return LoadModelSets(modelsDir(cfg, path))
+}
+
+// modelsDir 模型目录，配置的相对路径相对于配置文件所在目录
+func modelsDir(cfg config.OCRConfig, executable string) string {
+	if cfg.ModelsDir != "" {
+		return config.ResolvePath(cfg.ModelsDir)
+	}
+	return filepath.Join(filepath.Dir(executable), "models")
+}
+
+// pickModelSet 根据源语言选择模型组，没有对应语言或模型文件不全时返回 false
+func pickModelSet(sets []ModelSet, lang string) (ModelSet, bool) {
+	lang = normalizeLanguage(lang)
+	if lang == "" {
+		return ModelSet{}, false
+	}
+	for _, m := range sets {
+		if m.Available && m.language() == lang {
+			return m, true
+		}
+	}
+	return ModelSet{}, false
+}
+
+// normalizeLanguage 将各翻译服务的语言代码统一为模型组的语言，自动检测或不支持的语言返回空
+func normalizeLanguage(lang string) string {
+	switch strings.ToLower(lang) {
+	case "zh", "zh-chs", "zh-cn", "zh_cn", "zh-hans":
+		return "zh"
+	case "cht", "zh-cht", "zh-tw", "zh_tw", "zh-hant":
+		return "cht"
+	case "en":
+		return "en"
+	case "ja", "jp":
+		return "ja"
+	case "ko", "kor":
+		return "ko"
+	case "ru", "uk", "be", "bg", "sr":
+		return "ru"
+	}
+	return ""
+}
+
+type languageKey struct{}
+
+// WithLanguage 在 ctx 中记录翻译的源语言，ocr.model_set 为 auto 时据此选择模型组
+func WithLanguage(ctx context.Context, lang string) context.Context {
+	return context.WithValue(ctx, languageKey{}, lang)
+}
+
+func languageFrom(ctx context.Context) string {
+	lang, _ := ctx.Value(languageKey{}).(string)
+	return lang
+}
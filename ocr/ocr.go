@@ -0,0 +1,237 @@
+// Package ocr 截图文字识别，通过 Engine 接口调用 RapidOCR-json、Tesseract 等外部识别程序
+package ocr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"handy-translate/config"
+)
+
+// ErrUnknownEngine 配置中的识别引擎未注册
+var ErrUnknownEngine = errors.New("ocr: unknown engine")
+
+// DefaultEngine 未配置识别引擎时使用的引擎
+const DefaultEngine = "rapidocr"
+
+// defaultTimeout 未配置超时时单次识别的超时时间
+const defaultTimeout = 30 * time.Second
+
+// Block 识别出的一段文字，Box 为四个顶点的坐标（左上、右上、右下、左下）
+type Block struct {
+	Box   [4][2]int `json:"box"`
+	Score float64   `json:"score"` // 置信度 0~1
+	Text  string    `json:"text"`
+}
+
+// OCRResult 识别结果，Blocks 为引擎输出的顺序
+type OCRResult struct {
+	Blocks []Block `json:"blocks"`
+}
+
+// Text 识别出的文字，每段一行
+func (r OCRResult) Text() string {
+	lines := make([]string, 0, len(r.Blocks))
+	for _, b := range r.Blocks {
+		lines = append(lines, b.Text)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Engine 文字识别引擎
+type Engine interface {
+	Recognize(ctx context.Context, img image.Image) (OCRResult, error)
+}
+
+// Factory 根据配置创建识别引擎
+type Factory func(cfg config.OCRConfig) (Engine, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{}
+)
+
+// Register 注册识别引擎，名称即配置中的 ocr.engine
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = factory
+}
+
+// Engines 已注册的识别引擎
+func Engines() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New 根据配置创建识别引擎
+func New(cfg config.OCRConfig) (Engine, error) {
+	name := cfg.Engine
+	if name == "" {
+		name = DefaultEngine
+	}
+
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownEngine, name)
+	}
+	return factory(cfg)
+}
+
+var (
+	mu        sync.Mutex
+	engine    Engine
+	engineCfg config.OCRConfig // 创建 engine 时使用的配置，配置变化后重新创建
+)
+
+// Current 返回当前配置的识别引擎，ocr 配置变化后重新创建
+func Current() (Engine, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	cfg := config.Get().OCR
+	if engine != nil && cfg == engineCfg {
+		return engine, nil
+	}
+
+	e, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	closeEngine()
+	engine, engineCfg = e, cfg
+	return engine, nil
+}
+
+// Close 结束当前识别引擎的常驻进程，程序退出时调用
+func Close() {
+	mu.Lock()
+	defer mu.Unlock()
+	closeEngine()
+	engine = nil
+}
+
+func closeEngine() {
+	if c, ok := engine.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			slog.Error("关闭识别引擎失败", slog.Any("err", err))
+		}
+	}
+}
+
+// Recognize 使用当前配置的识别引擎识别图片，识别前按 ocr.preprocess 预处理，
+// 结果中的坐标为相对于 img 左上角的原图坐标
+func Recognize(ctx context.Context, img image.Image) (OCRResult, error) {
+	e, err := Current()
+	if err != nil {
+		return OCRResult{}, err
+	}
+
+	cfg := config.Get().OCR
+	timeout := defaultTimeout
+	if cfg.Timeout > 0 {
+		timeout = time.Duration(cfg.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	processed, scale := Preprocess(img, cfg.Preprocess)
+	result, err := e.Recognize(ctx, processed)
+	if err != nil {
+		return OCRResult{}, err
+	}
+	result.scale(scale)
+	return result, nil
+}
+
+// scale 将放大后图片上的坐标换算回原图坐标
+func (r OCRResult) scale(factor int) {
+	if factor <= 1 {
+		return
+	}
+	for i := range r.Blocks {
+		for j := range r.Blocks[i].Box {
+			r.Blocks[i].Box[j][0] /= factor
+			r.Blocks[i].Box[j][1] /= factor
+		}
+	}
+}
+
+// findExecutable 查找识别程序：配置了路径时使用配置的路径（相对路径相对于配置文件所在目录），
+// 否则依次在程序所在目录、当前目录和 PATH 中查找 name
+func findExecutable(path, name string) (string, error) {
+	if path != "" {
+		if !strings.ContainsAny(path, `/\`) {
+			return exec.LookPath(path)
+		}
+		path = config.ResolvePath(path)
+		if _, err := os.Stat(path); err != nil {
+			return "", err
+		}
+		return path, nil
+	}
+
+	var dirs []string
+	if exe, err := os.Executable(); err == nil {
+		dirs = append(dirs, filepath.Dir(exe))
+	}
+	dirs = append(dirs, ".")
+	for _, dir := range dirs {
+		candidate := filepath.Join(dir, name)
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate, nil
+		}
+	}
+	return exec.LookPath(name)
+}
+
+// encodePNG 在内存中将图片编码为 PNG，截图内容不会写入磁盘
+func encodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// runCommand 运行识别程序，stdin 作为标准输入，返回标准输出；进程退出码非 0 时返回包含标准错误的错误
+func runCommand(ctx context.Context, stdin []byte, path string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	hideWindow(cmd)
+
+	var stdout, stderr strings.Builder
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(path), ctx.Err())
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s: %w: %s", filepath.Base(path), err, msg)
+		}
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	return []byte(stdout.String()), nil
+}
@@ -0,0 +1,531 @@
+package ocr
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"handy-translate/config"
+)
+
+// update 重新生成 testdata 中的预期图片：go test ./ocr -update
+var update = flag.Bool("update", false, "更新 testdata 中的预期图片")
+
+func TestParseRapidOCR(t *testing.T) {
+	output := "OCR init completed.\r\n" +
+		`{"code":100,"data":[{"box":[[10,5],[90,5],[90,25],[10,25]],"score":0.98,"text":"Hello"},` +
+		`{"box":[[10,30],[90,30],[90,50],[10,50]],"score":0.95,"text":"世界"}]}` + "\r\n"
+
+	result, err := parseRapidOCR([]byte(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Block{
+		{Box: [4][2]int{{10, 5}, {90, 5}, {90, 25}, {10, 25}}, Score: 0.98, Text: "Hello"},
+		{Box: [4][2]int{{10, 30}, {90, 30}, {90, 50}, {10, 50}}, Score: 0.95, Text: "世界"},
+	}
+	if !reflect.DeepEqual(result.Blocks, want) {
+		t.Errorf("识别结果错误: %+v", result.Blocks)
+	}
+	if got := result.Text(); got != "Hello\n世界" {
+		t.Errorf("Text() = %q", got)
+	}
+
+	result, err = parseRapidOCR([]byte(`{"code":101,"data":"No text found in image."}`))
+	if err != nil || len(result.Blocks) != 0 {
+		t.Errorf("没有文字时应返回空结果: %+v %v", result, err)
+	}
+
+	_, err = parseRapidOCR([]byte(`{"code":200,"data":"Image path dose not exist."}`))
+	if err == nil || !strings.Contains(err.Error(), "Image path dose not exist.") {
+		t.Errorf("应返回 RapidOCR-json 的错误信息，实际 %v", err)
+	}
+
+	if _, err := parseRapidOCR([]byte("OCR init completed.\n")); err == nil {
+		t.Error("没有 JSON 结果时应返回错误")
+	}
+}
+
+func TestParseTesseractTSV(t *testing.T) {
+	output := strings.Join([]string{
+		"level\tpage_num\tblock_num\tpar_num\tline_num\tword_num\tleft\ttop\twidth\theight\tconf\ttext",
+		"1\t1\t0\t0\t0\t0\t0\t0\t200\t100\t-1\t",
+		"4\t1\t1\t1\t1\t0\t10\t5\t120\t20\t-1\t",
+		"5\t1\t1\t1\t1\t1\t10\t5\t50\t20\t96\tHello",
+		"5\t1\t1\t1\t1\t2\t70\t6\t60\t18\t90\tworld",
+		"5\t1\t1\t1\t2\t1\t10\t30\t40\t20\t80\tnext",
+		"5\t1\t1\t1\t2\t2\t60\t30\t10\t20\t-1\t ",
+	}, "\n")
+
+	result, err := parseTesseractTSV([]byte(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Blocks) != 2 {
+		t.Fatalf("应按行合并为 2 段，实际 %+v", result.Blocks)
+	}
+	first := result.Blocks[0]
+	if first.Text != "Hello world" || first.Box != [4][2]int{{10, 5}, {130, 5}, {130, 25}, {10, 25}} || first.Score != 0.93 {
+		t.Errorf("第一行错误: %+v", first)
+	}
+	if result.Blocks[1].Text != "next" {
+		t.Errorf("第二行错误: %+v", result.Blocks[1])
+	}
+}
+
+// setConfigPath 临时修改配置文件路径（相对路径按配置文件所在目录解析），测试结束后恢复
+func setConfigPath(t *testing.T, path string) {
+	t.Helper()
+	old := config.Path
+	config.Path = path
+	t.Cleanup(func() { config.Path = old })
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(config.OCRConfig{Engine: "missing"}); !errors.Is(err, ErrUnknownEngine) {
+		t.Errorf("未注册的引擎应返回 ErrUnknownEngine，实际 %v", err)
+	}
+	if got := Engines(); !reflect.DeepEqual(got, []string{"rapidocr", "tesseract"}) {
+		t.Errorf("Engines() = %v", got)
+	}
+
+	dir := t.TempDir()
+	setConfigPath(t, filepath.Join(dir, "config.toml"))
+	if err := os.WriteFile(filepath.Join(dir, "ocr-bin"), nil, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := New(config.OCRConfig{Engine: "tesseract", Path: "./ocr-bin", Language: "eng"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tess := e.(*Tesseract); tess.Path != filepath.Join(dir, "ocr-bin") || tess.Language != "eng" {
+		t.Errorf("相对路径应相对于配置文件所在目录: %+v", tess)
+	}
+	if _, err := New(config.OCRConfig{Path: "./missing"}); err == nil {
+		t.Error("识别程序不存在时应返回错误")
+	}
+}
+
+// TestHelperProcess 模拟 RapidOCR-json 的常驻进程，由 newFakeWorker 启动，不是真正的测试。
+// image_path 为 crash 时退出，为 hang 时不返回结果，其他情况返回 "<image_path>@<pid>"；
+// 发送 image_base64 时返回 "<宽>x<高>@<pid>"
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("OCR_FAKE_WORKER") != "1" {
+		return
+	}
+
+	fmt.Println("OCR init completed.")
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var req rapidRequest
+		json.Unmarshal(scanner.Bytes(), &req)
+		switch req.ImagePath {
+		case "crash":
+			os.Exit(2)
+		case "hang":
+			time.Sleep(time.Hour)
+		}
+		if req.ImageBase64 != "" {
+			data, _ := base64.StdEncoding.DecodeString(req.ImageBase64)
+			img, err := png.Decode(bytes.NewReader(data))
+			if err != nil {
+				fmt.Printf("{\"code\":203,\"data\":%q}\n", err.Error())
+				continue
+			}
+			req.ImagePath = fmt.Sprintf("%dx%d", img.Bounds().Dx(), img.Bounds().Dy())
+		}
+		text := fmt.Sprintf("%s@%d", req.ImagePath, os.Getpid())
+		data, _ := json.Marshal([]Block{{Score: 1, Text: text}})
+		fmt.Printf("{\"code\":100,\"data\":%s}\n", data)
+	}
+	os.Exit(0)
+}
+
+func newFakeWorker(t *testing.T) *Worker {
+	t.Setenv("OCR_FAKE_WORKER", "1")
+	w := NewWorker(os.Args[0], "-test.run=^TestHelperProcess$")
+	t.Cleanup(func() { w.Close() })
+	return w
+}
+
+// recognizeFake 返回识别结果中的 image_path 和进程 pid
+func recognizeFake(ctx context.Context, w *Worker, imagePath string) (string, string, error) {
+	line, err := w.Do(ctx, rapidRequest{ImagePath: imagePath})
+	if err != nil {
+		return "", "", err
+	}
+	result, err := parseRapidOCR(line)
+	if err != nil {
+		return "", "", err
+	}
+	path, pid, _ := strings.Cut(result.Text(), "@")
+	return path, pid, nil
+}
+
+func TestWorker(t *testing.T) {
+	w := newFakeWorker(t)
+	ctx := context.Background()
+
+	_, pid, err := recognizeFake(ctx, w, "a.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, pid2, err := recognizeFake(ctx, w, "b.png"); err != nil || pid2 != pid {
+		t.Errorf("应复用同一个进程: %s %s %v", pid, pid2, err)
+	}
+
+	// 并发请求依次处理，每个请求拿到自己的结果
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			want := fmt.Sprintf("%d.png", i)
+			if got, _, err := recognizeFake(ctx, w, want); err != nil || got != want {
+				t.Errorf("并发请求的结果错误: want %s got %s %v", want, got, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// 进程崩溃后重新启动
+	if _, _, err := recognizeFake(ctx, w, "crash"); err == nil {
+		t.Error("进程崩溃时应返回错误")
+	}
+	_, restarted, err := recognizeFake(ctx, w, "c.png")
+	if err != nil || restarted == pid {
+		t.Errorf("崩溃后应启动新进程: %s %s %v", pid, restarted, err)
+	}
+
+	// 超时后结束进程，下一个请求重新启动
+	timeoutCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+	if _, _, err := recognizeFake(timeoutCtx, w, "hang"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("超时应返回 DeadlineExceeded，实际 %v", err)
+	}
+	if got, pid3, err := recognizeFake(ctx, w, "d.png"); err != nil || got != "d.png" || pid3 == restarted {
+		t.Errorf("超时后应启动新进程: %s %s %v", got, pid3, err)
+	}
+
+	w.Close()
+	if _, _, err := recognizeFake(ctx, w, "e.png"); !errors.Is(err, ErrWorkerClosed) {
+		t.Errorf("关闭后应返回 ErrWorkerClosed，实际 %v", err)
+	}
+}
+
+func TestRapidOCRInMemory(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	t.Setenv("TEMP", tmp)
+
+	r := &RapidOCR{worker: newFakeWorker(t)}
+	// 截图裁剪出的子图，原点不在 (0, 0)
+	crop := image.NewRGBA(image.Rect(0, 0, 40, 30)).SubImage(image.Rect(10, 5, 30, 25))
+	result, err := r.Recognize(context.Background(), crop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size, _, _ := strings.Cut(result.Text(), "@"); size != "20x20" {
+		t.Errorf("应发送裁剪后的图片，实际 %s", size)
+	}
+
+	if entries, _ := os.ReadDir(tmp); len(entries) != 0 {
+		t.Errorf("识别时不应写入临时文件: %v", entries)
+	}
+}
+
+func TestModelSets(t *testing.T) {
+	f, err := os.Open("../models/configs.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	sets, err := ParseModelSets(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sets) != 7 || sets[2].Name != "English" || sets[2].Rec != "rec_en_PP-OCRv3_infer.onnx" {
+		t.Fatalf("解析 configs.txt 结果不对: %+v", sets)
+	}
+	if _, err := ParseModelSets(strings.NewReader("English\r\ndet.onnx\r\n")); err == nil {
+		t.Error("行数不足的模型组应返回错误")
+	}
+
+	// 模型目录中只有中文 V3 和日文模型
+	dir := t.TempDir()
+	setConfigPath(t, filepath.Join(dir, "config.toml"))
+	models := filepath.Join(dir, "models")
+	if err := os.Mkdir(models, 0755); err != nil {
+		t.Fatal(err)
+	}
+	configs, _ := os.ReadFile("../models/configs.txt")
+	os.WriteFile(filepath.Join(models, "configs.txt"), configs, 0644)
+	for _, name := range []string{"ch_PP-OCRv3_det_infer.onnx", "ch_ppocr_mobile_v2.0_cls_infer.onnx",
+		"ch_PP-OCRv3_rec_infer.onnx", "dict_chinese.txt", "rec_japan_PP-OCRv3_infer.onnx", "dict_japan.txt"} {
+		os.WriteFile(filepath.Join(models, name), nil, 0644)
+	}
+	os.WriteFile(filepath.Join(dir, "ocr-bin"), nil, 0755)
+
+	if sets, err = LoadModelSets(models); err != nil {
+		t.Fatal(err)
+	}
+	var available []string
+	for _, m := range sets {
+		if m.Available {
+			available = append(available, m.Name)
+		}
+	}
+	if !reflect.DeepEqual(available, []string{"简体中文(V3)", "日本語"}) {
+		t.Errorf("可用的模型组 = %v", available)
+	}
+
+	for lang, want := range map[string]string{"zh": "简体中文(V3)", "zh-CHS": "简体中文(V3)", "jp": "日本語", "ja": "日本語"} {
+		if m, ok := pickModelSet(sets, lang); !ok || m.Name != want {
+			t.Errorf("源语言 %s 应选择 %s，实际 %s", lang, want, m.Name)
+		}
+	}
+	for _, lang := range []string{"auto", "kor", "fr", ""} {
+		if m, ok := pickModelSet(sets, lang); ok {
+			t.Errorf("源语言 %s 没有可用的模型组，实际选择 %s", lang, m.Name)
+		}
+	}
+
+	cfg := config.OCRConfig{Path: "./ocr-bin", ModelsDir: "models"}
+	for name, wantErr := range map[string]bool{"日本語": false, "English": true, "Klingon": true} {
+		cfg.ModelSet = name
+		e, err := New(cfg)
+		if (err != nil) != wantErr {
+			t.Errorf("模型组 %s: err = %v", name, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		r := e.(*RapidOCR)
+		want := []string{"--models=" + models, "--det=ch_PP-OCRv3_det_infer.onnx", "--cls=ch_ppocr_mobile_v2.0_cls_infer.onnx",
+			"--rec=rec_japan_PP-OCRv3_infer.onnx", "--keys=dict_japan.txt"}
+		if !reflect.DeepEqual(r.worker.args, want) {
+			t.Errorf("RapidOCR-json 参数 = %v", r.worker.args)
+		}
+		r.Close()
+	}
+	cfg.ModelSet = "Klingon"
+	if _, err := New(cfg); !errors.Is(err, ErrUnknownModelSet) {
+		t.Errorf("未知的模型组应返回 ErrUnknownModelSet，实际 %v", err)
+	}
+
+	cfg.ModelSet = ModelSetAuto
+	e, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := e.(*RapidOCR)
+	defer r.Close()
+	if w := r.workerFor(WithLanguage(context.Background(), "jp")); w == r.worker || w.args[3] != "--rec=rec_japan_PP-OCRv3_infer.onnx" {
+		t.Errorf("源语言为日语时应使用日文模型: %v", w.args)
+	}
+	if w := r.workerFor(WithLanguage(context.Background(), "auto")); w != r.worker {
+		t.Error("源语言为 auto 时应使用默认模型")
+	}
+}
+
+// block 根据左上角坐标、宽高创建文字块
+func block(x, y, w, h int, text string, score float64) Block {
+	return Block{Box: [4][2]int{{x, y}, {x + w, y}, {x + w, y + h}, {x, y + h}}, Score: score, Text: text}
+}
+
+func TestLayoutText(t *testing.T) {
+	cases := []struct {
+		name   string
+		blocks []Block
+		want   string
+	}{
+		{
+			name: "同一行的文字块按水平位置合并",
+			blocks: []Block{
+				block(120, 11, 60, 20, "world", 0.9),
+				block(10, 10, 100, 20, "Hello", 0.9),
+			},
+			want: "Hello world",
+		},
+		{
+			name: "换行的句子合并为一段，连字符断开的单词重新拼接",
+			blocks: []Block{
+				block(10, 10, 300, 20, "The scheduler multi-", 0.9),
+				block(10, 34, 300, 20, "plexes goroutines onto", 0.9),
+				block(10, 58, 120, 20, "threads.", 0.9),
+				block(10, 100, 300, 20, "Each goroutine has", 0.9),
+				block(10, 124, 200, 20, "its own stack.", 0.9),
+			},
+			want: "The scheduler multiplexes goroutines onto threads.\nEach goroutine has its own stack.",
+		},
+		{
+			name: "中文换行不加空格，短行以句号结尾时分段",
+			blocks: []Block{
+				block(10, 10, 300, 20, "协程是一种用户态的", 0.9),
+				block(10, 34, 100, 20, "轻量级线程。", 0.9),
+				block(10, 58, 300, 20, "由运行时调度。", 0.9),
+			},
+			want: "协程是一种用户态的轻量级线程。\n由运行时调度。",
+		},
+		{
+			name: "两栏按先左栏后右栏排列，横跨两栏的标题在前",
+			blocks: []Block{
+				block(10, 10, 500, 24, "Title", 0.9),
+				block(300, 50, 200, 20, "right one", 0.9),
+				block(10, 50, 200, 20, "left one", 0.9),
+				block(10, 74, 200, 20, "left two", 0.9),
+				block(300, 74, 200, 20, "right two", 0.9),
+			},
+			want: "Title\nleft one left two\nright one right two",
+		},
+		{
+			name: "丢弃置信度低的文字块",
+			blocks: []Block{
+				block(10, 10, 100, 20, "keep", 0.9),
+				block(120, 10, 30, 20, "~#", 0.2),
+			},
+			want: "keep",
+		},
+	}
+
+	for _, c := range cases {
+		if got := (OCRResult{Blocks: c.blocks}).LayoutText(0.5); got != c.want {
+			t.Errorf("%s:\n got %q\nwant %q", c.name, got, c.want)
+		}
+	}
+	if got := (OCRResult{}).LayoutText(0.5); got != "" {
+		t.Errorf("没有文字时应返回空，实际 %q", got)
+	}
+}
+
+// darkScreenshot 深色主题下的小截图：深色背景上的浅色文字笔画，原点不在 (0, 0)
+func darkScreenshot() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 64; x++ {
+			img.Set(x, y, color.RGBA{30, 34, 40, 255})
+		}
+	}
+	text := color.RGBA{220, 220, 200, 255}
+	for x := 8; x < 56; x += 8 {
+		for y := 6; y < 16; y++ {
+			img.Set(x, y, text)
+			img.Set(x+1, y, color.RGBA{120, 122, 120, 255}) // 抗锯齿的边缘
+		}
+		for dx := 0; dx < 5; dx++ {
+			img.Set(x+dx, 6+dx, text)
+		}
+	}
+	return img.SubImage(image.Rect(2, 2, 62, 18))
+}
+
+func TestPreprocess(t *testing.T) {
+	cases := []struct {
+		name  string
+		cfg   config.PreprocessConfig
+		scale int
+	}{
+		{"none", config.PreprocessConfig{}, 1},
+		{"upscale", config.PreprocessConfig{MinHeight: 48}, 3},
+		{"gray_invert", config.PreprocessConfig{Grayscale: true, InvertDark: true}, 1},
+		{"full", config.PreprocessConfig{MinHeight: 48, Grayscale: true, InvertDark: true, Contrast: 1.5, Binarize: true}, 3},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, scale := Preprocess(darkScreenshot(), c.cfg)
+			if scale != c.scale {
+				t.Errorf("放大倍数 = %d，期望 %d", scale, c.scale)
+			}
+			if b := got.Bounds(); b.Min != (image.Point{}) || b.Dx() != 60*scale || b.Dy() != 16*scale {
+				t.Errorf("处理后的图片范围 = %v", b)
+			}
+
+			golden := filepath.Join("testdata", "preprocess_"+c.name+".png")
+			if *update {
+				if err := writePNG(golden, got); err != nil {
+					t.Fatal(err)
+				}
+			}
+			f, err := os.Open(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			want, err := png.Decode(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !sameImage(got, want) {
+				t.Errorf("处理结果与 %s 不一致，确认无误后使用 -update 更新", golden)
+			}
+		})
+	}
+
+	// 识别结果的坐标换算回原图
+	result := OCRResult{Blocks: []Block{block(30, 12, 90, 24, "text", 1)}}
+	result.scale(3)
+	if want := block(10, 4, 30, 8, "text", 1); result.Blocks[0] != want {
+		t.Errorf("换算后的坐标 = %v，期望 %v", result.Blocks[0].Box, want.Box)
+	}
+
+	// 浅色背景不反色
+	light := image.NewGray(image.Rect(0, 0, 10, 10))
+	for i := range light.Pix {
+		light.Pix[i] = 240
+	}
+	got, _ := Preprocess(light, config.PreprocessConfig{InvertDark: true})
+	if g := got.(*image.Gray); g.Pix[0] != 240 {
+		t.Errorf("浅色背景不应反色，实际亮度 %d", g.Pix[0])
+	}
+
+	// 调试目录中保存每一步的图片
+	dir := t.TempDir()
+	setConfigPath(t, filepath.Join(dir, "config.toml"))
+	Preprocess(darkScreenshot(), config.PreprocessConfig{MinHeight: 48, InvertDark: true, Binarize: true, DebugDir: "debug"})
+	entries, err := os.ReadDir(filepath.Join(dir, "debug"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var steps []string
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".png")
+		steps = append(steps, name[strings.LastIndex(name, "-")+1:])
+	}
+	if want := []string{"input", "upscale", "grayscale", "invert", "binarize"}; !reflect.DeepEqual(steps, want) {
+		t.Errorf("调试图片 = %v，期望 %v", steps, want)
+	}
+}
+
+// sameImage 两张图片的范围和每个像素是否相同
+func sameImage(a, b image.Image) bool {
+	if a.Bounds() != b.Bounds() {
+		return false
+	}
+	for y := a.Bounds().Min.Y; y < a.Bounds().Max.Y; y++ {
+		for x := a.Bounds().Min.X; x < a.Bounds().Max.X; x++ {
+			r1, g1, b1, a1 := a.At(x, y).RGBA()
+			r2, g2, b2, a2 := b.At(x, y).RGBA()
+			if r1 != r2 || g1 != g2 || b1 != b2 || a1 != a2 {
+				return false
+			}
+		}
+	}
+	return true
+}
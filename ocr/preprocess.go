@@ -0,0 +1,210 @@
+package ocr
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"handy-translate/config"
+)
+
+// maxUpscale 小截图最多放大的倍数
+const maxUpscale = 4
+
+// Preprocess 识别前处理截图：放大较小的截图、转为灰度、深色背景反色、调整对比度和二值化，
+// 每一步是否进行由配置决定。返回处理后的图片（原点为 (0, 0)）和放大倍数，识别结果的坐标需除以该倍数
+func Preprocess(img image.Image, cfg config.PreprocessConfig) (image.Image, int) {
+	debug := newDebugSaver(cfg.DebugDir)
+	debug.save("input", img)
+
+	out := toRGBA(img)
+	scale := 1
+	if h := img.Bounds().Dy(); cfg.MinHeight > 0 && h > 0 && h < cfg.MinHeight {
+		scale = min((cfg.MinHeight+h-1)/h, maxUpscale)
+		out = upscale(out, scale)
+		debug.save("upscale", out)
+	}
+
+	contrast := cfg.Contrast > 0 && cfg.Contrast != 1
+	if !cfg.Grayscale && !cfg.InvertDark && !contrast && !cfg.Binarize {
+		return out, scale
+	}
+	gray := toGray(out)
+	debug.save("grayscale", gray)
+
+	if cfg.InvertDark && meanLuma(gray) < 128 {
+		invert(gray)
+		debug.save("invert", gray)
+	}
+	if contrast {
+		adjustContrast(gray, cfg.Contrast)
+		debug.save("contrast", gray)
+	}
+	if cfg.Binarize {
+		binarize(gray, otsuThreshold(gray))
+		debug.save("binarize", gray)
+	}
+	return gray, scale
+}
+
+// toRGBA 复制为原点为 (0, 0) 的 RGBA 图片，截图裁剪出的子图原点不在 (0, 0)
+func toRGBA(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+	return dst
+}
+
+// upscale 按双线性插值放大 scale 倍
+func upscale(src *image.RGBA, scale int) *image.RGBA {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, sw*scale, sh*scale))
+	for y := 0; y < sh*scale; y++ {
+		fy := max((float64(y)+0.5)/float64(scale)-0.5, 0)
+		y0 := int(fy)
+		y1, wy := min(y0+1, sh-1), fy-float64(y0)
+		for x := 0; x < sw*scale; x++ {
+			fx := max((float64(x)+0.5)/float64(scale)-0.5, 0)
+			x0 := int(fx)
+			x1, wx := min(x0+1, sw-1), fx-float64(x0)
+
+			p00, p10 := src.PixOffset(x0, y0), src.PixOffset(x1, y0)
+			p01, p11 := src.PixOffset(x0, y1), src.PixOffset(x1, y1)
+			d := dst.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				top := float64(src.Pix[p00+c])*(1-wx) + float64(src.Pix[p10+c])*wx
+				bottom := float64(src.Pix[p01+c])*(1-wx) + float64(src.Pix[p11+c])*wx
+				dst.Pix[d+c] = uint8(top*(1-wy) + bottom*wy + 0.5)
+			}
+		}
+	}
+	return dst
+}
+
+// toGray 转为灰度图
+func toGray(img image.Image) *image.Gray {
+	b := img.Bounds()
+	dst := image.NewGray(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			dst.SetGray(x, y, color.GrayModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.Gray))
+		}
+	}
+	return dst
+}
+
+func meanLuma(img *image.Gray) float64 {
+	if len(img.Pix) == 0 {
+		return 255
+	}
+	var sum int
+	for _, v := range img.Pix {
+		sum += int(v)
+	}
+	return float64(sum) / float64(len(img.Pix))
+}
+
+func invert(img *image.Gray) {
+	for i, v := range img.Pix {
+		img.Pix[i] = 255 - v
+	}
+}
+
+// adjustContrast 以中间亮度 128 为中心将亮度拉伸 factor 倍
+func adjustContrast(img *image.Gray, factor float64) {
+	for i, v := range img.Pix {
+		img.Pix[i] = uint8(min(max((float64(v)-128)*factor+128, 0), 255) + 0.5)
+	}
+}
+
+// otsuThreshold 大津法：选择使前景和背景类间方差最大的阈值
+func otsuThreshold(img *image.Gray) uint8 {
+	var hist [256]int
+	for _, v := range img.Pix {
+		hist[v]++
+	}
+	total := len(img.Pix)
+	var sumAll float64
+	for i, n := range hist {
+		sumAll += float64(i * n)
+	}
+
+	var best uint8
+	var bestVariance, sumBack float64
+	back := 0
+	for t := 0; t < 256; t++ {
+		back += hist[t]
+		fore := total - back
+		if back == 0 {
+			continue
+		}
+		if fore == 0 {
+			break
+		}
+		sumBack += float64(t * hist[t])
+		meanBack := sumBack / float64(back)
+		meanFore := (sumAll - sumBack) / float64(fore)
+		variance := float64(back) * float64(fore) * (meanBack - meanFore) * (meanBack - meanFore)
+		if variance > bestVariance {
+			bestVariance, best = variance, uint8(t)
+		}
+	}
+	return best
+}
+
+// binarize 亮度大于阈值的为白色，否则为黑色
+func binarize(img *image.Gray, threshold uint8) {
+	for i, v := range img.Pix {
+		if v > threshold {
+			img.Pix[i] = 255
+		} else {
+			img.Pix[i] = 0
+		}
+	}
+}
+
+// debugSaver 将每一步处理后的图片保存为 <时间>-<序号>-<步骤>.png，目录为空时不保存
+type debugSaver struct {
+	dir    string
+	prefix string
+	step   int
+}
+
+func newDebugSaver(dir string) *debugSaver {
+	if dir == "" {
+		return &debugSaver{}
+	}
+	return &debugSaver{dir: config.ResolvePath(dir), prefix: time.Now().Format("20060102-150405.000")}
+}
+
+func (d *debugSaver) save(name string, img image.Image) {
+	if d.dir == "" {
+		return
+	}
+	path := filepath.Join(d.dir, fmt.Sprintf("%s-%d-%s.png", d.prefix, d.step, name))
+	d.step++
+	if err := writePNG(path, img); err != nil {
+		slog.Warn("保存预处理调试图片失败", slog.String("path", path), slog.Any("err", err))
+	}
+}
+
+func writePNG(path string, img image.Image) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
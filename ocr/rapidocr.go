@@ -0,0 +1,177 @@
+package ocr
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+
+	"handy-translate/config"
+)
+
+// RapidOCR-json 返回的状态码
+const (
+	rapidCodeOK     = 100 // 识别成功
+	rapidCodeNoText = 101 // 图片中没有文字
+)
+
+func init() {
+	Register("rapidocr", newRapidOCR)
+}
+
+// RapidOCR 调用 RapidOCR-json（https://github.com/hiroi-sora/RapidOCR-json）识别图片，
+// RapidOCR-json 作为常驻进程运行，模型只在启动时加载一次
+type RapidOCR struct {
+	Path   string
+	worker *Worker // 使用配置的模型组的进程，未配置模型组时使用 RapidOCR-json 的默认模型
+
+	// ocr.model_set 为 auto 时按源语言选择模型组，每个模型组一个进程，第一次使用时启动
+	auto      bool
+	modelsDir string
+	sets      []ModelSet
+	mu        sync.Mutex
+	workers   map[string]*Worker
+}
+
+func newRapidOCR(cfg config.OCRConfig) (Engine, error) {
+	path, err := findExecutable(cfg.Path, rapidOCRName)
+	if err != nil {
+		return nil, fmt.Errorf("ocr: RapidOCR-json not found: %w", err)
+	}
+
+	r := &RapidOCR{Path: path, modelsDir: modelsDir(cfg, path)}
+	var args []string
+	if cfg.ModelsDir != "" {
+		args = []string{"--models=" + r.modelsDir}
+	}
+
+	switch cfg.ModelSet {
+	case "":
+	case ModelSetAuto:
+		if r.sets, err = LoadModelSets(r.modelsDir); err != nil {
+			slog.Warn("读取 OCR 模型组失败，使用默认模型", slog.String("dir", r.modelsDir), slog.Any("err", err))
+		}
+		r.auto = true
+		r.workers = map[string]*Worker{}
+	default:
+		sets, err := LoadModelSets(r.modelsDir)
+		if err != nil {
+			return nil, fmt.Errorf("ocr: 读取模型组失败: %w", err)
+		}
+		i := slices.IndexFunc(sets, func(m ModelSet) bool { return m.Name == cfg.ModelSet })
+		if i < 0 {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownModelSet, cfg.ModelSet)
+		}
+		if !sets[i].Available {
+			return nil, fmt.Errorf("ocr: 模型组 %s 的模型文件不全", cfg.ModelSet)
+		}
+		args = sets[i].args(r.modelsDir)
+	}
+	r.worker = NewWorker(path, args...)
+	return r, nil
+}
+
+// workerFor 选择识别进程：ocr.model_set 为 auto 且 ctx 中的源语言有对应的模型组时使用该模型组的进程
+func (r *RapidOCR) workerFor(ctx context.Context) *Worker {
+	if !r.auto {
+		return r.worker
+	}
+	set, ok := pickModelSet(r.sets, languageFrom(ctx))
+	if !ok {
+		return r.worker
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	w, ok := r.workers[set.Name]
+	if !ok {
+		w = NewWorker(r.Path, set.args(r.modelsDir)...)
+		r.workers[set.Name] = w
+		slog.Info("OCR 使用模型组", slog.String("name", set.Name))
+	}
+	return w
+}
+
+// rapidRequest RapidOCR-json 的请求，image_path 和 image_base64 二选一
+type rapidRequest struct {
+	ImagePath   string `json:"image_path,omitempty"`
+	ImageBase64 string `json:"image_base64,omitempty"`
+}
+
+// Recognize 识别图片，图片以 base64 编码发送，不经过临时文件
+func (r *RapidOCR) Recognize(ctx context.Context, img image.Image) (OCRResult, error) {
+	data, err := encodePNG(img)
+	if err != nil {
+		return OCRResult{}, err
+	}
+
+	line, err := r.workerFor(ctx).Do(ctx, rapidRequest{ImageBase64: base64.StdEncoding.EncodeToString(data)})
+	if err != nil {
+		return OCRResult{}, err
+	}
+	return parseRapidOCR(line)
+}
+
+// Close 结束所有 RapidOCR-json 进程
+func (r *RapidOCR) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, w := range r.workers {
+		w.Close()
+	}
+	return r.worker.Close()
+}
+
+// rapidResponse RapidOCR-json 的输出，识别成功时 data 为文字块数组，否则为错误信息
+type rapidResponse struct {
+	Code int             `json:"code"`
+	Data json.RawMessage `json:"data"`
+}
+
+// parseRapidOCR 解析 RapidOCR-json 的输出。程序启动时会先输出初始化信息，结果为其后第一行 JSON
+func parseRapidOCR(output []byte) (OCRResult, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		var resp rapidResponse
+		if err := json.Unmarshal([]byte(line), &resp); err != nil || resp.Code == 0 {
+			continue
+		}
+		return resp.result()
+	}
+	if err := scanner.Err(); err != nil {
+		return OCRResult{}, err
+	}
+	return OCRResult{}, errors.New("ocr: RapidOCR-json returned no result")
+}
+
+func (resp rapidResponse) result() (OCRResult, error) {
+	switch resp.Code {
+	case rapidCodeOK:
+		var blocks []Block
+		if err := json.Unmarshal(resp.Data, &blocks); err != nil {
+			return OCRResult{}, fmt.Errorf("ocr: 解析 RapidOCR-json 结果失败: %w", err)
+		}
+		return OCRResult{Blocks: blocks}, nil
+	case rapidCodeNoText:
+		return OCRResult{}, nil
+	default:
+		var msg string
+		if err := json.Unmarshal(resp.Data, &msg); err != nil {
+			msg = string(resp.Data)
+		}
+		return OCRResult{}, fmt.Errorf("ocr: RapidOCR-json error %d: %s", resp.Code, msg)
+	}
+}
@@ -0,0 +1,117 @@
+package ocr
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"strconv"
+	"strings"
+
+	"handy-translate/config"
+)
+
+func init() {
+	Register("tesseract", newTesseract)
+}
+
+// Tesseract 调用 Tesseract 命令行（https://github.com/tesseract-ocr/tesseract）识别图片
+type Tesseract struct {
+	Path     string
+	Language string // -l 参数，为空时使用 Tesseract 的默认语言
+}
+
+func newTesseract(cfg config.OCRConfig) (Engine, error) {
+	path, err := findExecutable(cfg.Path, tesseractName)
+	if err != nil {
+		return nil, fmt.Errorf("ocr: tesseract not found: %w", err)
+	}
+	return &Tesseract{Path: path, Language: cfg.Language}, nil
+}
+
+// Recognize 识别图片，图片通过标准输入传给 Tesseract，以 TSV 格式输出，按行合并单词
+func (t *Tesseract) Recognize(ctx context.Context, img image.Image) (OCRResult, error) {
+	data, err := encodePNG(img)
+	if err != nil {
+		return OCRResult{}, err
+	}
+
+	args := []string{"stdin", "stdout"}
+	if t.Language != "" {
+		args = append(args, "-l", t.Language)
+	}
+	args = append(args, "tsv")
+
+	output, err := runCommand(ctx, data, t.Path, args...)
+	if err != nil {
+		return OCRResult{}, err
+	}
+	return parseTesseractTSV(output)
+}
+
+// tesseractLine 同一行中的单词
+type tesseractLine struct {
+	words                    []string
+	left, top, right, bottom int
+	conf                     float64
+}
+
+// parseTesseractTSV 解析 Tesseract 的 TSV 输出，列为：
+// level page_num block_num par_num line_num word_num left top width height conf text
+func parseTesseractTSV(output []byte) (OCRResult, error) {
+	lines := map[string]*tesseractLine{}
+	var order []string
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	header := true
+	for scanner.Scan() {
+		if header {
+			header = false
+			continue
+		}
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 12 || fields[0] != "5" {
+			continue // 只处理单词（level 5）
+		}
+		text := strings.TrimSpace(fields[11])
+		conf, err := strconv.ParseFloat(fields[10], 64)
+		if text == "" || err != nil || conf < 0 {
+			continue
+		}
+
+		var box [4]int
+		for i := range box {
+			if box[i], err = strconv.Atoi(fields[6+i]); err != nil {
+				return OCRResult{}, fmt.Errorf("ocr: 解析 tesseract 输出失败: %q", scanner.Text())
+			}
+		}
+		left, top, right, bottom := box[0], box[1], box[0]+box[2], box[1]+box[3]
+
+		key := strings.Join(fields[1:5], ".")
+		line, ok := lines[key]
+		if !ok {
+			line = &tesseractLine{left: left, top: top, right: right, bottom: bottom}
+			lines[key] = line
+			order = append(order, key)
+		}
+		line.words = append(line.words, text)
+		line.conf += conf
+		line.left, line.top = min(line.left, left), min(line.top, top)
+		line.right, line.bottom = max(line.right, right), max(line.bottom, bottom)
+	}
+	if err := scanner.Err(); err != nil {
+		return OCRResult{}, err
+	}
+
+	var result OCRResult
+	for _, key := range order {
+		line := lines[key]
+		result.Blocks = append(result.Blocks, Block{
+			Box:   [4][2]int{{line.left, line.top}, {line.right, line.top}, {line.right, line.bottom}, {line.left, line.bottom}},
+			Score: line.conf / float64(len(line.words)) / 100,
+			Text:  strings.Join(line.words, " "),
+		})
+	}
+	return result, nil
+}
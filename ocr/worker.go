@@ -0,0 +1,214 @@
+package ocr
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ErrWorkerClosed 识别进程已关闭
+var ErrWorkerClosed = errors.New("ocr: worker closed")
+
+// errWorkerExited 识别进程意外退出，重新启动后重试一次
+var errWorkerExited = errors.New("ocr: worker exited")
+
+// workerQueueSize 排队等待识别的请求数，超过时 Do 阻塞
+const workerQueueSize = 16
+
+// Worker 常驻的识别进程，第一次请求时启动，之后一直复用，避免每次识别都重新加载模型。
+// 请求和结果都是一行 JSON，通过标准输入输出收发（RapidOCR-json 的协议），
+// 并发的请求在队列中依次处理；进程崩溃后下一个请求会重新启动，超时的请求会结束进程
+type Worker struct {
+	path string
+	args []string
+
+	queue     chan *workerRequest
+	done      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+
+	// 以下字段只在 run 中访问
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	lines  chan string   // 标准输出的每一行，进程退出后关闭
+	exited chan struct{} // 结束进程时关闭，通知读取标准输出的 goroutine 退出
+	starts int           // 启动次数，用于日志
+}
+
+type workerRequest struct {
+	ctx     context.Context
+	payload []byte
+	reply   chan workerReply
+}
+
+type workerReply struct {
+	line []byte
+	err  error
+}
+
+// NewWorker 创建识别进程，进程在第一次请求时才启动
+func NewWorker(path string, args ...string) *Worker {
+	w := &Worker{
+		path:    path,
+		args:    args,
+		queue:   make(chan *workerRequest, workerQueueSize),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Do 发送一个请求（编码为一行 JSON），返回进程输出的一行 JSON 结果
+func (w *Worker) Do(ctx context.Context, req any) ([]byte, error) {
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &workerRequest{ctx: ctx, payload: payload, reply: make(chan workerReply, 1)}
+	select {
+	case w.queue <- r:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-w.done:
+		return nil, ErrWorkerClosed
+	}
+
+	select {
+	case reply := <-r.reply:
+		return reply.line, reply.err
+	case <-w.stopped:
+		return nil, ErrWorkerClosed
+	}
+}
+
+// Close 结束识别进程，之后的请求返回 ErrWorkerClosed
+func (w *Worker) Close() error {
+	w.closeOnce.Do(func() {
+		close(w.done)
+	})
+	<-w.stopped
+	return nil
+}
+
+func (w *Worker) run() {
+	defer close(w.stopped)
+	defer w.stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case r := <-w.queue:
+			line, err := w.handle(r)
+			r.reply <- workerReply{line: line, err: err}
+		}
+	}
+}
+
+func (w *Worker) handle(r *workerRequest) ([]byte, error) {
+	if err := r.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		if w.cmd == nil {
+			if err := w.start(); err != nil {
+				return nil, err
+			}
+		}
+
+		line, err := w.roundTrip(r)
+		if errors.Is(err, errWorkerExited) && attempt == 0 {
+			slog.Warn("OCR 进程已退出，重新启动", slog.String("path", w.path))
+			continue
+		}
+		return line, err
+	}
+}
+
+// roundTrip 写入请求并读取结果，跳过进程输出的初始化信息等非 JSON 行
+func (w *Worker) roundTrip(r *workerRequest) ([]byte, error) {
+	if _, err := w.stdin.Write(append(r.payload, '\n')); err != nil {
+		w.stop()
+		return nil, fmt.Errorf("%w: %v", errWorkerExited, err)
+	}
+
+	for {
+		select {
+		case line, ok := <-w.lines:
+			if !ok {
+				w.stop()
+				return nil, errWorkerExited
+			}
+			if line = strings.TrimSpace(line); strings.HasPrefix(line, "{") {
+				return []byte(line), nil
+			}
+		case <-r.ctx.Done():
+			// 进程仍在处理这个请求，结果会与下一个请求错位，直接结束进程
+			w.stop()
+			return nil, r.ctx.Err()
+		case <-w.done:
+			return nil, ErrWorkerClosed
+		}
+	}
+}
+
+func (w *Worker) start() error {
+	cmd := exec.Command(w.path, w.args...)
+	cmd.Dir = filepath.Dir(w.path) // 模型目录相对于识别程序所在目录
+	hideWindow(cmd)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("启动 %s 失败: %w", filepath.Base(w.path), err)
+	}
+
+	lines := make(chan string)
+	exited := make(chan struct{})
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-exited:
+				return
+			}
+		}
+	}()
+
+	w.starts++
+	w.cmd, w.stdin, w.lines, w.exited = cmd, stdin, lines, exited
+	slog.Info("OCR 进程已启动", slog.String("path", w.path), slog.Int("pid", cmd.Process.Pid), slog.Int("starts", w.starts))
+	return nil
+}
+
+// stop 结束当前进程，下一个请求会重新启动
+func (w *Worker) stop() {
+	if w.cmd == nil {
+		return
+	}
+	w.stdin.Close()
+	w.cmd.Process.Kill()
+	close(w.exited)
+	w.cmd.Wait()
+	w.cmd, w.stdin, w.lines, w.exited = nil, nil, nil, nil
+}
@@ -1,9 +1,11 @@
 package windows
 
 import (
+	"errors"
 	"log/slog"
 	"runtime"
 	"syscall"
+	"unsafe"
 
 	"github.com/lxn/win"
 )
@@ -30,6 +32,23 @@ func PressCtrlC() {
 	pressKey(VK_CONTROL, KEYEVENTF_KEYUP)
 }
 
+// GetForegroundWindowTitle 获取前台窗口的标题，用于记录选中文本所在的应用
+func GetForegroundWindowTitle() string {
+	hwnd := win.GetForegroundWindow()
+	if hwnd == 0 {
+		return ""
+	}
+
+	length, _, _ := getWindowTextLength.Call(uintptr(hwnd))
+	if length == 0 {
+		return ""
+	}
+
+	buf := make([]uint16, length+1)
+	getWindowTextW.Call(uintptr(hwnd), uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)))
+	return syscall.UTF16ToString(buf)
+}
+
 // FindWindow 查找窗口
 func FindWindow(windowName string) *Window {
 	runtime.LockOSThread()
@@ -62,3 +81,37 @@ func FindWindow(windowName string) *Window {
 
 	return w
 }
+
+// SetClipboardDIB 将 DIB 格式的图片写入剪贴板
+func SetClipboardDIB(dib []byte) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	if !win.OpenClipboard(0) {
+		return errors.New("windows: OpenClipboard failed")
+	}
+	defer win.CloseClipboard()
+
+	if !win.EmptyClipboard() {
+		return errors.New("windows: EmptyClipboard failed")
+	}
+
+	hMem := win.GlobalAlloc(win.GMEM_MOVEABLE, uintptr(len(dib)))
+	if hMem == 0 {
+		return errors.New("windows: GlobalAlloc failed")
+	}
+	ptr := win.GlobalLock(hMem)
+	if ptr == nil {
+		win.GlobalFree(hMem)
+		return errors.New("windows: GlobalLock failed")
+	}
+	copy(unsafe.Slice((*byte)(ptr), len(dib)), dib)
+	win.GlobalUnlock(hMem)
+
+	// 写入成功后内存归剪贴板所有，不能再释放
+	if win.SetClipboardData(win.CF_DIB, win.HANDLE(hMem)) == 0 {
+		win.GlobalFree(hMem)
+		return errors.New("windows: SetClipboardData failed")
+	}
+	return nil
+}
@@ -31,6 +31,8 @@ var (
 	unhookWindowsHookEx = user32.NewProc("UnhookWindowsHookEx")
 	getMessageW         = user32.NewProc("GetMessageW")
 	keybdEventProc      = user32.NewProc("keybd_event") // 键盘事件函数
+	getWindowTextW      = user32.NewProc("GetWindowTextW")
+	getWindowTextLength = user32.NewProc("GetWindowTextLengthW")
 
 	kernel32         = syscall.NewLazyDLL("kernel32.dll")
 	getModuleHandleW = kernel32.NewProc("GetModuleHandleW")
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"image"
+	"log/slog"
+
+	"handy-translate/config"
+	"handy-translate/ocr"
+	"handy-translate/state"
+	"handy-translate/translate_service"
+)
+
+// overlayBlock 覆盖翻译中的一个文字块，Box 为截图（整个屏幕截图）中的坐标
+type overlayBlock struct {
+	Index       int       `json:"index"`
+	Box         [4][2]int `json:"box"`
+	Text        string    `json:"text"`
+	Translation string    `json:"translation,omitempty"`
+}
+
+// overlayResult overlay_result 事件的数据，每翻译完一批发送一次，最后一批的 Done 为 true；
+// SessionID 为截图会话 ID，前端据此忽略之前的截图的结果
+type overlayResult struct {
+	SessionID string         `json:"session_id"`
+	Blocks    []overlayBlock `json:"blocks"`
+	Done      bool           `json:"done"`
+	Error     string         `json:"error,omitempty"`
+}
+
+// overlayBlocks 将识别结果转换为覆盖翻译的文字块，丢弃置信度低于 minScore 的文字块，
+// 坐标从截图区域内换算为截图坐标
+func overlayBlocks(result ocr.OCRResult, minScore float64, origin image.Point) []overlayBlock {
+	blocks := make([]overlayBlock, 0, len(result.Blocks))
+	for _, b := range result.Blocks {
+		if b.Text == "" || b.Score < minScore {
+			continue
+		}
+		box := b.Box
+		for i := range box {
+			box[i][0] += origin.X
+			box[i][1] += origin.Y
+		}
+		blocks = append(blocks, overlayBlock{Index: len(blocks), Box: box, Text: b.Text})
+	}
+	return blocks
+}
+
+// translateOverlay 按批翻译文字块，每翻译完一批发送一次 overlay_result 事件；
+// 某一批翻译失败时发送错误并停止
+func translateOverlay(sessionID string, blocks []overlayBlock) {
+	if len(blocks) == 0 {
+		app.Event.Emit("overlay_result", overlayResult{SessionID: sessionID, Blocks: []overlayBlock{}, Done: true})
+		return
+	}
+
+	fromLang, toLang := state.GlobalStateService.Languages()
+	translateWay := translate_service.GetTranslateWay(config.Get().TranslateWay)
+	if translateWay == nil {
+		app.Event.Emit("overlay_result", overlayResult{SessionID: sessionID, Blocks: []overlayBlock{}, Done: true, Error: "未设置翻译服务"})
+		return
+	}
+
+	texts := make([]string, len(blocks))
+	for i, b := range blocks {
+		texts[i] = b.Text
+	}
+
+	start := 0
+	batches := translate_service.Batches(texts)
+	for i, batch := range batches {
+		translated, err := translate_service.TranslateLines(translateWay, batch, fromLang, toLang)
+		if err != nil {
+			slog.Error("覆盖翻译失败", slog.Int("batch", i), slog.Any("err", err))
+			app.Event.Emit("overlay_result", overlayResult{SessionID: sessionID, Blocks: []overlayBlock{}, Done: true, Error: err.Error()})
+			return
+		}
+
+		result := overlayResult{SessionID: sessionID, Blocks: blocks[start : start+len(batch)], Done: i == len(batches)-1}
+		for j, t := range translated {
+			result.Blocks[j].Translation = t
+		}
+		app.Event.Emit("overlay_result", result)
+		start += len(batch)
+	}
+	slog.Info("覆盖翻译完成", slog.Int("blocks", len(blocks)), slog.Int("batches", len(batches)))
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"log/slog"
+	"reflect"
+
+	"handy-translate/config"
+	"handy-translate/state"
+
+	"github.com/wailsapp/wails/v3/pkg/application"
+)
+
+// profileMenu 托盘菜单中的配置方案子菜单
+var profileMenu *application.Menu
+
+// trayMenu 托盘菜单，配置方案变化后需要刷新
+var trayMenu *application.Menu
+
+// switchProfile 切换配置方案，保存到配置文件并立即应用语言和工具栏模式
+func switchProfile(id string) error {
+	profile, err := config.SwitchProfile(id)
+	if err != nil {
+		slog.Error("切换配置方案失败", slog.String("profile", id), slog.Any("err", err))
+		return err
+	}
+	applyProfile(id, profile)
+	return nil
+}
+
+// applyProfile 应用配置方案中不保存在配置里的语言和工具栏模式，并通知前端
+func applyProfile(id string, profile config.Profile) {
+	setLanguages(profile.From, profile.To)
+	if profile.Mode != "" {
+		SetToolbarMode(profile.Mode)
+	}
+	fromLang, toLang := state.GlobalStateService.Languages()
+
+	slog.Info("应用配置方案",
+		slog.String("profile", id),
+		slog.String("fromLang", fromLang),
+		slog.String("toLang", toLang),
+		slog.String("mode", GetToolbarMode()))
+
+	app.Event.Emit("profile_changed", map[string]interface{}{
+		"id":            id,
+		"name":          profile.Name,
+		"translate_way": config.Get().TranslateWay,
+		"from":          fromLang,
+		"to":            toLang,
+		"mode":          GetToolbarMode(),
+		"template":      config.Get().ExplainTemplates.DefaultTemplate,
+	})
+}
+
+// buildProfileMenu 根据配置生成配置方案子菜单，当前方案为选中状态
+func buildProfileMenu(c *config.Config) {
+	profileMenu.Clear()
+	if len(c.Profiles) == 0 {
+		profileMenu.Add("未配置").SetEnabled(false)
+	}
+	for _, id := range c.ProfileIDs() {
+		id := id
+		label := c.Profiles[id].Name
+		if label == "" {
+			label = id
+		}
+		profileMenu.AddRadio(label, id == c.Profile).OnClick(func(ctx *application.Context) {
+			switchProfile(id)
+		})
+	}
+}
+
+// refreshProfileMenu 配置方案变化后在主线程中刷新托盘菜单
+func refreshProfileMenu(c *config.Config) {
+	application.InvokeAsync(func() {
+		buildProfileMenu(c)
+		trayMenu.Update()
+	})
+}
+
+// profilesChanged 配置方案或当前方案是否变化，变化时需要刷新托盘菜单
+func profilesChanged(old, new *config.Config) bool {
+	return old.Profile != new.Profile || !reflect.DeepEqual(old.Profiles, new.Profiles)
+}
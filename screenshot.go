@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"image"
+	"log/slog"
+	"time"
+
+	"handy-translate/capture"
+	"handy-translate/config"
+	"handy-translate/ocr"
+	"handy-translate/os_api/windows"
+	"handy-translate/state"
+	"handy-translate/window/pin"
+	"handy-translate/window/screenshot"
+)
+
+// cropSession 裁剪截图会话中选中的区域，选区为截图窗口中两个角的 CSS 像素坐标，
+// 返回的图片范围为截图中的像素坐标
+func cropSession(sessionID string, startX, startY, endX, endY float64) (*capture.Session, image.Image, error) {
+	session, err := screenshot.Sessions.Get(sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	img, err := session.Crop(startX, startY, endX, endY)
+	if err != nil {
+		return nil, nil, err
+	}
+	return session, img, nil
+}
+
+// CopyScreenshotImage 将截图会话中选中的区域复制到剪贴板
+func (a *App) CopyScreenshotImage(sessionID string, startX, startY, endX, endY float64) error {
+	_, img, err := cropSession(sessionID, startX, startY, endX, endY)
+	if err != nil {
+		return err
+	}
+	if err := windows.SetClipboardDIB(capture.EncodeDIB(img)); err != nil {
+		slog.Error("复制截图失败", slog.Any("err", err))
+		return err
+	}
+	return nil
+}
+
+// SaveScreenshot 将截图会话中选中的区域保存到 screenshot.save_dir，文件名带时间，返回文件路径
+func (a *App) SaveScreenshot(sessionID string, startX, startY, endX, endY float64) (string, error) {
+	_, img, err := cropSession(sessionID, startX, startY, endX, endY)
+	if err != nil {
+		return "", err
+	}
+	path, err := capture.Save(img, config.Get().ScreenshotDir(), time.Now())
+	if err != nil {
+		slog.Error("保存截图失败", slog.Any("err", err))
+		return "", err
+	}
+	slog.Info("截图已保存", slog.String("path", path))
+	return path, nil
+}
+
+// CopyScreenshotText 识别截图会话中选中区域的文字并复制到剪贴板，不翻译，返回识别出的文字
+func (a *App) CopyScreenshotText(sessionID string, startX, startY, endX, endY float64) (string, error) {
+	_, img, err := cropSession(sessionID, startX, startY, endX, endY)
+	if err != nil {
+		return "", err
+	}
+
+	fromLang, _ := state.GlobalStateService.Languages()
+	result, err := ocr.Recognize(ocr.WithLanguage(context.Background(), fromLang), img)
+	if err != nil {
+		slog.Error("OCR 识别失败", slog.Any("err", err))
+		return "", err
+	}
+	text := result.LayoutText(config.Get().OCR.MinScore)
+	if !app.Clipboard.SetText(text) {
+		return "", errors.New("复制到剪贴板失败")
+	}
+	return text, nil
+}
+
+// PinScreenshot 将截图会话中选中的区域钉在屏幕上原来的位置，显示为置顶的小窗口
+func (a *App) PinScreenshot(sessionID string, startX, startY, endX, endY float64) error {
+	session, img, err := cropSession(sessionID, startX, startY, endX, endY)
+	if err != nil {
+		return err
+	}
+	encoded, err := screenshot.EncodeBase64(img)
+	if err != nil {
+		return err
+	}
+	b := img.Bounds()
+	pin.Show(encoded, image.Rectangle{Min: session.Capture.ToScreen(b.Min), Max: session.Capture.ToScreen(b.Max)})
+	return nil
+}
@@ -0,0 +1,193 @@
+// Package state 保存运行时状态（语言、工具栏模式），修改后写入 state.json，下次启动时恢复
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"handy-translate/config"
+)
+
+// 截图模式
+const (
+	CaptureText    = "text"    // 识别出的文字合并后整体翻译，结果显示在翻译窗口
+	CaptureOverlay = "overlay" // 按文字块翻译，译文覆盖在截图中原文的位置
+)
+
+// 默认状态，与首次启动时前端的默认选项一致
+const (
+	DefaultFromLang    = "auto"
+	DefaultToLang      = "zh"
+	DefaultToolbarMode = config.ModeTranslate
+	DefaultCaptureMode = CaptureText
+)
+
+// State 运行时状态
+type State struct {
+	FromLang    string `json:"from_lang"`
+	ToLang      string `json:"to_lang"`
+	ToolbarMode string `json:"toolbar_mode"` // "translate" 或 "explain"
+	CaptureMode string `json:"capture_mode"` // "text" 或 "overlay"
+}
+
+// StateService 状态服务，读写都通过互斥锁保护，修改后立即写入文件
+type StateService struct {
+	mu       sync.RWMutex
+	filePath string
+	state    State
+	restored bool // 是否从 state.json 恢复了状态
+}
+
+// NewStateService 创建状态服务，从配置文件所在目录的 state.json 恢复状态，文件不存在或无法解析时使用默认状态
+func NewStateService() *StateService {
+	return newStateService(filepath.Join(filepath.Dir(config.Path), "state.json"))
+}
+
+func newStateService(filePath string) *StateService {
+	s := &StateService{
+		filePath: filePath,
+		state: State{
+			FromLang:    DefaultFromLang,
+			ToLang:      DefaultToLang,
+			ToolbarMode: DefaultToolbarMode,
+			CaptureMode: DefaultCaptureMode,
+		},
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Error("读取状态文件失败", slog.String("path", filePath), slog.Any("err", err))
+		}
+		return s
+	}
+
+	var saved State
+	if err := json.Unmarshal(data, &saved); err != nil {
+		slog.Error("解析状态文件失败，使用默认状态", slog.String("path", filePath), slog.Any("err", err))
+		return s
+	}
+	if saved.FromLang != "" {
+		s.state.FromLang = saved.FromLang
+	}
+	if saved.ToLang != "" {
+		s.state.ToLang = saved.ToLang
+	}
+	if saved.ToolbarMode == config.ModeTranslate || saved.ToolbarMode == config.ModeExplain {
+		s.state.ToolbarMode = saved.ToolbarMode
+	}
+	if saved.CaptureMode == CaptureText || saved.CaptureMode == CaptureOverlay {
+		s.state.CaptureMode = saved.CaptureMode
+	}
+	s.restored = true
+	return s
+}
+
+// Restored 启动时是否从 state.json 恢复了状态，没有恢复时使用的是默认状态
+func (s *StateService) Restored() bool {
+	return s.restored
+}
+
+// Get 获取当前状态
+func (s *StateService) Get() State {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.state
+}
+
+// Languages 获取源语言和目标语言
+func (s *StateService) Languages() (from, to string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.state.FromLang, s.state.ToLang
+}
+
+// SetLanguages 设置源语言和目标语言，为空的保持不变
+func (s *StateService) SetLanguages(from, to string) error {
+	return s.update(func(st *State) error {
+		if from != "" {
+			st.FromLang = from
+		}
+		if to != "" {
+			st.ToLang = to
+		}
+		return nil
+	})
+}
+
+// ToolbarMode 获取工具栏模式
+func (s *StateService) ToolbarMode() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.state.ToolbarMode
+}
+
+// SetToolbarMode 设置工具栏模式
+func (s *StateService) SetToolbarMode(mode string) error {
+	return s.update(func(st *State) error {
+		if mode != config.ModeTranslate && mode != config.ModeExplain {
+			return fmt.Errorf("state: unknown toolbar mode %q", mode)
+		}
+		st.ToolbarMode = mode
+		return nil
+	})
+}
+
+// CaptureMode 获取截图模式
+func (s *StateService) CaptureMode() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.state.CaptureMode
+}
+
+// SetCaptureMode 设置截图模式
+func (s *StateService) SetCaptureMode(mode string) error {
+	return s.update(func(st *State) error {
+		if mode != CaptureText && mode != CaptureOverlay {
+			return fmt.Errorf("state: unknown capture mode %q", mode)
+		}
+		st.CaptureMode = mode
+		return nil
+	})
+}
+
+// update 修改状态并写入文件，写入失败时状态仍然生效，只在本次运行中有效
+func (s *StateService) update(fn func(*State) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := s.state
+	if err := fn(&next); err != nil {
+		return err
+	}
+	if next == s.state {
+		return nil
+	}
+	s.state = next
+	return s.save()
+}
+
+// save 将状态写入文件，调用方需持有锁
+func (s *StateService) save() error {
+	data, err := json.MarshalIndent(s.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.filePath), 0755); err != nil {
+		return err
+	}
+
+	// 先写临时文件再重命名，避免写入中途退出导致文件损坏
+	tmp := s.filePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.filePath)
+}
+
+// 全局状态服务实例
+var GlobalStateService *StateService
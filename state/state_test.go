@@ -0,0 +1,85 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestStatePersist(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "state.json")
+
+	s := newStateService(filePath)
+	if got := s.Get(); got.FromLang != DefaultFromLang || got.ToLang != DefaultToLang || got.ToolbarMode != DefaultToolbarMode || got.CaptureMode != DefaultCaptureMode {
+		t.Errorf("首次启动应使用默认状态，实际 %+v", got)
+	}
+	if s.Restored() {
+		t.Error("没有状态文件时不应视为已恢复")
+	}
+
+	if err := s.SetLanguages("en", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetToolbarMode("explain"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetToolbarMode("unknown"); err == nil {
+		t.Error("未知的工具栏模式应返回错误")
+	}
+	if err := s.SetCaptureMode(CaptureOverlay); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetCaptureMode("unknown"); err == nil {
+		t.Error("未知的截图模式应返回错误")
+	}
+
+	restored := newStateService(filePath)
+	if got := restored.Get(); got != (State{FromLang: "en", ToLang: DefaultToLang, ToolbarMode: "explain", CaptureMode: CaptureOverlay}) {
+		t.Errorf("重启后状态错误: %+v", got)
+	}
+	if !restored.Restored() {
+		t.Error("重启后应从状态文件恢复")
+	}
+}
+
+func TestStateInvalidFile(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(filePath, []byte(`{"from_lang": "ja", "toolbar_mode": "other"`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if s := newStateService(filePath); s.Get().FromLang != DefaultFromLang || s.Restored() {
+		t.Errorf("无法解析的状态文件应使用默认状态，实际 %+v", s.Get())
+	}
+
+	if err := os.WriteFile(filePath, []byte(`{"from_lang": "ja", "toolbar_mode": "other"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := newStateService(filePath).Get(); got.FromLang != "ja" || got.ToolbarMode != DefaultToolbarMode {
+		t.Errorf("无效的工具栏模式应使用默认值，实际 %+v", got)
+	}
+}
+
+func TestStateConcurrent(t *testing.T) {
+	s := newStateService(filepath.Join(t.TempDir(), "state.json"))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			s.SetLanguages("en", "zh")
+			s.SetToolbarMode("explain")
+		}()
+		go func() {
+			defer wg.Done()
+			s.Languages()
+			s.ToolbarMode()
+		}()
+	}
+	wg.Wait()
+
+	if got := s.Get(); got.FromLang != "en" || got.ToolbarMode != "explain" {
+		t.Errorf("并发修改后的状态错误: %+v", got)
+	}
+}
@@ -0,0 +1,25 @@
+// Package apierr 翻译服务接口返回的错误，供各翻译服务和测试连接时区分错误类型
+package apierr
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error 翻译服务接口返回的错误码
+type Error struct {
+	Provider string
+	Code     string
+	Message  string
+	Auth     bool // 鉴权失败：appID 或密钥错误
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: error %s: %s", e.Provider, e.Code, e.Message)
+}
+
+// IsAuth 判断是否为鉴权失败
+func IsAuth(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Auth
+}
@@ -13,6 +13,7 @@ import (
 	"strings"
 
 	"handy-translate/config"
+	"handy-translate/translate_service/apierr"
 )
 
 const Way = "baidu"
@@ -32,6 +33,8 @@ func (b *Baidu) GetName() string {
 }
 
 type translateResult struct {
+	ErrorCode   string        `json:"error_code"`
+	ErrorMsg    string        `json:"error_msg"`
 	From        string        `json:"from"`
 	To          string        `json:"to"`
 	TransResult []TransResult `json:"trans_result"`
@@ -96,6 +99,16 @@ func (b *Baidu) PostQuery(query, fromLang, toLang string) ([]string, error) {
 	prettyResult, _ := json.MarshalIndent(result, "", "    ")
 	slog.Info(string(prettyResult))
 
+	// 52000 表示成功，52003 未授权用户、54001 签名错误
+	if result.ErrorCode != "" && result.ErrorCode != "52000" {
+		return nil, &apierr.Error{
+			Provider: Way,
+			Code:     result.ErrorCode,
+			Message:  result.ErrorMsg,
+			Auth:     result.ErrorCode == "52003" || result.ErrorCode == "54001",
+		}
+	}
+
 	if len(result.TransResult) > 0 {
 		if result.TransResult[0].Dst == result.TransResult[0].Src {
 			return nil, nil
@@ -3,18 +3,21 @@ package baidu
 import (
 	"fmt"
 	"handy-translate/config"
+	"path/filepath"
 	"testing"
 )
 
 func TestBaidu_PostQuery(t *testing.T) {
-	config.Init("handy-translate")
+	t.Setenv(config.EnvConfigPath, filepath.Join(t.TempDir(), "config.toml"))
+	if err := config.Init("handy-translate", ""); err != nil {
+		t.Fatal(err)
+	}
 	source := `hello`
-	var baidu = &Baidu{
-		Translate: config.Translate{
-			Key:   config.Data.Translate[Way].Key,
-			AppID: config.Data.Translate[Way].AppID,
-		},
+	provider, err := config.Get().ResolveTranslate(Way)
+	if err != nil {
+		t.Fatal(err)
 	}
+	var baidu = &Baidu{Translate: provider}
 	target, err := baidu.PostQuery(source, "auto", "zh")
 	fmt.Println(err)
 	fmt.Println(target)
@@ -0,0 +1,93 @@
+package translate_service
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrEmptyResult 翻译服务没有返回错误，但所有译文都为空
+var ErrEmptyResult = errors.New("翻译结果为空")
+
+// 批量翻译时每批的最大行数和字符数
+const (
+	BatchLines = 20
+	BatchChars = 1500
+)
+
+// Batches 将多行文字按顺序分批，每批不超过 BatchLines 行、BatchChars 个字符（单行超过时单独一批）
+func Batches(lines []string) [][]string {
+	var batches [][]string
+	var current []string
+	chars := 0
+	for _, line := range lines {
+		n := len([]rune(line))
+		if len(current) > 0 && (len(current) >= BatchLines || chars+n > BatchChars) {
+			batches = append(batches, current)
+			current, chars = nil, 0
+		}
+		current = append(current, line)
+		chars += n
+	}
+	if len(current) > 0 {
+		batches = append(batches, current)
+	}
+	return batches
+}
+
+// TranslateLines 逐行翻译一批文字，返回与 lines 一一对应的译文。
+// 先将整批以换行连接后一次翻译，译文行数与原文不一致时改为逐行翻译；所有译文都为空时返回 ErrEmptyResult
+func TranslateLines(t Translate, lines []string, from, to string) ([]string, error) {
+	query := make([]string, len(lines))
+	for i, line := range lines {
+		query[i] = strings.ReplaceAll(line, "\n", " ")
+	}
+
+	result, err := postQuery(t, strings.Join(query, "\n"), from, to)
+	if err != nil {
+		return nil, err
+	}
+	if translated := splitLines(result); len(translated) == len(lines) {
+		return translated, nil
+	}
+
+	translated := make([]string, len(lines))
+	empty := true
+	for i, line := range query {
+		result, err := postQuery(t, line, from, to)
+		if err != nil {
+			return nil, err
+		}
+		translated[i] = strings.Join(splitLines(result), " ")
+		empty = empty && translated[i] == ""
+	}
+	if empty && len(lines) > 0 {
+		return nil, ErrEmptyResult
+	}
+	return translated, nil
+}
+
+// postQuery 翻译一段文字，支持流式输出的翻译服务使用流式接口（DeepSeek 只实现了流式翻译）
+func postQuery(t Translate, query, from, to string) ([]string, error) {
+	if st, ok := t.(StreamTranslate); ok {
+		var b strings.Builder
+		err := st.PostQueryStream(query, from, to, func(chunk string) {
+			b.WriteString(chunk)
+		})
+		if err != nil {
+			return nil, err
+		}
+		return []string{b.String()}, nil
+	}
+	return t.PostQuery(query, from, to)
+}
+
+// splitLines 将翻译结果拆分为非空的行
+func splitLines(result []string) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.Join(result, "\n"), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
@@ -5,9 +5,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"handy-translate/config"
+	"handy-translate/translate_service/apierr"
 	"io"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
 )
 
@@ -68,10 +70,17 @@ func (c *Caiyun) PostQuery(query, fromLang, toLang string) ([]string, error) {
 		return nil, err
 	}
 
+	defer resp.Body.Close()
+
 	if resp.StatusCode != 200 {
-		return nil, err
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &apierr.Error{
+			Provider: Way,
+			Code:     strconv.Itoa(resp.StatusCode),
+			Message:  string(body),
+			Auth:     resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden,
+		}
 	}
-	defer resp.Body.Close()
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
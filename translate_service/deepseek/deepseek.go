@@ -2,10 +2,14 @@ package deepseek
 
 import (
 	"context"
-	"log"
+	"io"
+	"net/http"
+	"strconv"
 	"sync"
 
 	"handy-translate/config"
+	"handy-translate/explain"
+	"handy-translate/translate_service/apierr"
 
 	"github.com/tmc/langchaingo/llms"
 	"github.com/tmc/langchaingo/llms/openai"
@@ -15,10 +19,22 @@ import (
 const Way = "deepseek"
 
 var (
-	once sync.Once
-	llm  *openai.LLM
+	mu     sync.Mutex
+	llm    *openai.LLM
+	llmKey string // 创建 llm 时使用的密钥，密钥变化后重新创建
 )
 
+func init() {
+	// 配置文件中的密钥被修改后丢弃旧的客户端
+	config.Subscribe(func(old, new *config.Config) {
+		if old.Translate[Way].Key != new.Translate[Way].Key {
+			mu.Lock()
+			llm = nil
+			mu.Unlock()
+		}
+	})
+}
+
 type Deepseek struct {
 	config.Translate
 }
@@ -38,19 +54,45 @@ func (c *Deepseek) GetName() string {
 	return Way
 }
 
-func (c *Deepseek) GetLLM() *openai.LLM {
-	once.Do(func() {
-		var err error
-		llm, err = openai.New(
-			openai.WithToken(config.Data.Translate[Way].Key),
-			openai.WithModel("deepseek-chat"),
-			openai.WithBaseURL("https://api.deepseek.com"),
-		)
-		if err != nil {
-			log.Fatal(err)
-		}
-	})
-	return llm
+// GetLLM 返回使用当前密钥的客户端
+func (c *Deepseek) GetLLM() (*openai.LLM, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if llm != nil && llmKey == c.Key {
+		return llm, nil
+	}
+
+	client, err := openai.New(
+		openai.WithToken(c.Key),
+		openai.WithModel("deepseek-chat"),
+		openai.WithBaseURL("https://api.deepseek.com"),
+		openai.WithHTTPClient(apiClient{}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	llm, llmKey = client, c.Key
+	return llm, nil
+}
+
+// apiClient 将接口返回的错误状态转换为 apierr.Error。openai 客户端只返回字符串形式的错误，无法区分鉴权失败
+type apiClient struct{}
+
+func (apiClient) Do(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil || resp.StatusCode == http.StatusOK {
+		return resp, err
+	}
+
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return nil, &apierr.Error{
+		Provider: Way,
+		Code:     strconv.Itoa(resp.StatusCode),
+		Message:  string(body),
+		Auth:     resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden,
+	}
 }
 
 func (c *Deepseek) PostQuery(query, fromLang, toLang string) ([]string, error) {
@@ -108,8 +150,13 @@ func (c *Deepseek) PostExplain(query string) (string, error) {
 		return "", err
 	}
 
+	client, err := c.GetLLM()
+	if err != nil {
+		return "", err
+	}
+
 	// 非流式一次性生成
-	resp, err := llms.GenerateFromSinglePrompt(context.Background(), c.GetLLM(), promptValue)
+	resp, err := llms.GenerateFromSinglePrompt(context.Background(), client, promptValue)
 	if err != nil {
 		return "", err
 	}
@@ -138,9 +185,14 @@ func (c *Deepseek) PostQueryStream(query, fromLang, toLang string, callback func
 		return err
 	}
 
+	client, err := c.GetLLM()
+	if err != nil {
+		return err
+	}
+
 	// 流式调用 LLM
 	ctx := context.Background()
-	_, err = c.GetLLM().GenerateContent(ctx, []llms.MessageContent{
+	_, err = client.GenerateContent(ctx, []llms.MessageContent{
 		{
 			Parts: []llms.ContentPart{
 				llms.TextPart(promptValue),
@@ -158,82 +210,74 @@ func (c *Deepseek) PostQueryStream(query, fromLang, toLang string, callback func
 	return err
 }
 
-// PostExplainStream 流式术语解释（支持模板选择）
-func (c *Deepseek) PostExplainStream(query, templateID string, callback func(chunk string)) error {
-	// 获取模板内容
-	templateStr := c.getTemplate(templateID)
-
-	// 定义术语解释模板
-	promptTemplate := prompts.NewPromptTemplate(
-		templateStr,
-		[]string{"text"},
-	)
+// PostExplainStream 流式术语解释（支持模板选择）。模板的系统提示词、少样本示例和渲染后的模板
+// 作为对话消息发送，并使用模板中设置的模型参数
+func (c *Deepseek) PostExplainStream(vars explain.Vars, templateID string, callback func(chunk string)) error {
+	// 获取模板，与模板预览使用相同的渲染方式
+	template := c.getTemplate(templateID)
+	messages, err := template.Messages(vars)
+	if err != nil {
+		return err
+	}
 
-	// 构建输入
-	promptValue, err := promptTemplate.Format(map[string]any{
-		"text": query,
-	})
+	client, err := c.GetLLM()
 	if err != nil {
 		return err
 	}
 
 	// 流式调用 LLM
 	ctx := context.Background()
-	_, err = c.GetLLM().GenerateContent(ctx, []llms.MessageContent{
-		{
-			Parts: []llms.ContentPart{
-				llms.TextPart(promptValue),
-			},
-			Role: llms.ChatMessageTypeHuman,
-		},
-	}, llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error {
+	opts := append(template.CallOptions(), llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error {
 		// 每次接收到数据块时调用回调函数
 		if len(chunk) > 0 {
 			callback(string(chunk))
 		}
 		return nil
 	}))
+	_, err = client.GenerateContent(ctx, messages, opts...)
 
 	return err
 }
 
-// getTemplate 获取提示词模板，支持从配置中读取
-func (c *Deepseek) getTemplate(templateID string) string {
-	// 如果配置为空，使用硬编码的默认模板
-	if len(config.Data.ExplainTemplates.Templates) == 0 {
-		return ""
+// getTemplate 获取解释模板，支持从配置中读取
+func (c *Deepseek) getTemplate(templateID string) explain.Template {
+	explainTemplates := config.Get().ExplainTemplates
+
+	// 如果配置为空，返回空模板
+	if len(explainTemplates.Templates) == 0 {
+		return explain.Template{}
 	}
 
 	// 如果 templateID 为空，使用默认模板
 	if templateID == "" {
-		templateID = config.Data.ExplainTemplates.DefaultTemplate
+		templateID = explainTemplates.DefaultTemplate
 	}
 
 	// 如果默认模板也为空，使用第一个可用模板
 	if templateID == "" {
-		for id := range config.Data.ExplainTemplates.Templates {
+		for id := range explainTemplates.Templates {
 			templateID = id
 			break
 		}
 	}
 
 	// 从配置中获取模板
-	if template, exists := config.Data.ExplainTemplates.Templates[templateID]; exists {
-		return template.Template
+	if template, exists := explainTemplates.Templates[templateID]; exists {
+		return template
 	}
 
 	// 如果指定的模板不存在，尝试使用默认模板
-	if config.Data.ExplainTemplates.DefaultTemplate != "" {
-		if template, exists := config.Data.ExplainTemplates.Templates[config.Data.ExplainTemplates.DefaultTemplate]; exists {
-			return template.Template
+	if explainTemplates.DefaultTemplate != "" {
+		if template, exists := explainTemplates.Templates[explainTemplates.DefaultTemplate]; exists {
+			return template
 		}
 	}
 
 	// 如果都找不到，使用第一个可用模板
-	for _, template := range config.Data.ExplainTemplates.Templates {
-		return template.Template
+	for _, template := range explainTemplates.Templates {
+		return template
 	}
 
-	// 最后的回退：使用硬编码的默认模板
-	return ""
+	// 最后的回退：空模板
+	return explain.Template{}
 }
@@ -2,27 +2,33 @@ package deepseek
 
 import (
 	"handy-translate/config"
+	"handy-translate/translate_service/apierr"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
 
 // TestDeepseek_PostExplainStream 验证术语解释的流式输出是否正常。
-// 需要在 config.toml 中配置 deepseek 的 API Key，未配置时将跳过此测试。
+// 测试使用临时的配置文件，API Key 从环境变量 DEEPSEEK_API_KEY 读取，未设置时将跳过此测试。
 func TestDeepseek_PostExplainStream(t *testing.T) {
-	config.Init("handy-translate")
+	t.Setenv(config.EnvConfigPath, filepath.Join(t.TempDir(), "config.toml"))
+	if err := config.Init("handy-translate", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	provider, err := config.Get().ResolveTranslate(Way)
 
 	// 无可用密钥时跳过
-	if config.Data.Translate[Way].Key == "" {
-		t.Skip("skip: DeepSeek API key is not configured")
+	key := os.Getenv("DEEPSEEK_API_KEY")
+	if err != nil || key == "" {
+		t.Skip("skip: DEEPSEEK_API_KEY is not set")
 	}
+	provider.Key = key
 
-	d := &Deepseek{
-		Translate: config.Translate{
-			Name:  config.Data.Translate[Way].Name,
-			AppID: config.Data.Translate[Way].AppID,
-			Key:   config.Data.Translate[Way].Key,
-		},
-	}
+	d := &Deepseek{Translate: provider}
 
 	// 使用非流式接口以规避底层流解码差异导致的不稳定
 	resp, err := d.PostExplain("CPU")
@@ -33,3 +39,22 @@ func TestDeepseek_PostExplainStream(t *testing.T) {
 		t.Fatalf("expected non-empty explanation, got empty")
 	}
 }
+
+// TestAPIClientError 验证接口返回的错误状态转换为 apierr.Error，测试连接据此区分鉴权失败
+func TestAPIClientError(t *testing.T) {
+	status := http.StatusUnauthorized
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(`{"error":{"message":"invalid api key"}}`))
+	}))
+	defer server.Close()
+
+	for code, auth := range map[int]bool{http.StatusUnauthorized: true, http.StatusTooManyRequests: false} {
+		status = code
+		req, _ := http.NewRequest(http.MethodPost, server.URL, nil)
+		_, err := apiClient{}.Do(req)
+		if err == nil || apierr.IsAuth(err) != auth {
+			t.Errorf("状态码 %d 返回 %v，期望鉴权失败 %v", code, err, auth)
+		}
+	}
+}
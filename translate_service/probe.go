@@ -0,0 +1,121 @@
+package translate_service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"handy-translate/config"
+	"handy-translate/translate_service/apierr"
+)
+
+// ProbeText 测试翻译服务时翻译的固定文本
+const ProbeText = "hello"
+
+// probeTimeout 测试翻译服务的超时时间
+const probeTimeout = 15 * time.Second
+
+// 测试翻译服务失败的类型
+const (
+	ProbeUnsupported   = "unsupported"    // 不支持的翻译服务
+	ProbeNotConfigured = "not_configured" // appID 或密钥未填写、仍是占位值或无法读取
+	ProbeAuth          = "auth"           // 鉴权失败
+	ProbeNetwork       = "network"        // 网络错误
+	ProbeTimeout       = "timeout"        // 超时
+	ProbeEmpty         = "empty_result"   // 翻译结果为空
+	ProbeRemote        = "remote"         // 接口返回的其他错误
+)
+
+// ProbeError 测试翻译服务失败的原因
+type ProbeError struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+func (e *ProbeError) Error() string {
+	return e.Kind + ": " + e.Message
+}
+
+// ProbeResult 测试翻译服务的结果
+type ProbeResult struct {
+	Way       string `json:"way"`
+	Text      string `json:"text"`
+	Result    string `json:"result"`
+	LatencyMS int64  `json:"latency_ms"`
+}
+
+// Probe 使用当前配置翻译 ProbeText，测试翻译服务是否可用，失败时返回 *ProbeError
+func Probe(ctx context.Context, way string) (*ProbeResult, error) {
+	for _, d := range config.Diagnostics() {
+		if strings.HasPrefix(d.Field, "translate."+way+".") {
+			return nil, &ProbeError{Kind: ProbeNotConfigured, Message: d.Field + ": " + d.Message}
+		}
+	}
+
+	if _, err := config.Get().ResolveTranslate(way); err != nil {
+		return nil, &ProbeError{Kind: ProbeNotConfigured, Message: err.Error()}
+	}
+
+	t := GetTranslateWay(way)
+	if t == nil {
+		return nil, &ProbeError{Kind: ProbeUnsupported, Message: fmt.Sprintf("不支持的翻译服务 %q", way)}
+	}
+
+	type outcome struct {
+		result string
+		err    error
+	}
+	done := make(chan outcome, 1)
+	start := time.Now()
+	go func() {
+		result, err := probeQuery(t)
+		done <- outcome{result, err}
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	select {
+	case <-ctx.Done():
+		return nil, &ProbeError{Kind: ProbeTimeout, Message: fmt.Sprintf("%s 内未返回结果", probeTimeout)}
+	case o := <-done:
+		if o.err != nil {
+			return nil, classifyProbeError(o.err)
+		}
+		if strings.TrimSpace(o.result) == "" {
+			return nil, &ProbeError{Kind: ProbeEmpty, Message: "翻译结果为空"}
+		}
+		return &ProbeResult{
+			Way:       way,
+			Text:      ProbeText,
+			Result:    o.result,
+			LatencyMS: time.Since(start).Milliseconds(),
+		}, nil
+	}
+}
+
+// probeQuery 翻译测试文字
+func probeQuery(t Translate) (string, error) {
+	result, err := postQuery(t, ProbeText, "auto", "zh")
+	if err != nil || len(result) == 0 {
+		return "", err
+	}
+	return result[0], nil
+}
+
+func classifyProbeError(err error) *ProbeError {
+	var netErr net.Error
+	switch {
+	case apierr.IsAuth(err):
+		return &ProbeError{Kind: ProbeAuth, Message: err.Error()}
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return &ProbeError{Kind: ProbeTimeout, Message: err.Error()}
+	case errors.As(err, &netErr):
+		return &ProbeError{Kind: ProbeNetwork, Message: err.Error()}
+	default:
+		return &ProbeError{Kind: ProbeRemote, Message: err.Error()}
+	}
+}
@@ -1,9 +1,11 @@
 package translate_service
 
 import (
+	"log/slog"
 	"sync"
 
 	"handy-translate/config"
+	"handy-translate/explain"
 	"handy-translate/translate_service/baidu"
 	"handy-translate/translate_service/caiyun"
 	"handy-translate/translate_service/deepseek"
@@ -19,43 +21,31 @@ type Translate interface {
 type StreamTranslate interface {
 	Translate
 	PostQueryStream(query, sourceLang, targetLang string, callback func(chunk string)) error
-	PostExplainStream(query, templateID string, callback func(chunk string)) error
+	PostExplainStream(vars explain.Vars, templateID string, callback func(chunk string)) error
 }
 
 func GetTranslateWay(way string) Translate {
 	var t Translate
+	provider, err := config.Get().ResolveTranslate(way)
+	if err != nil {
+		slog.Error("读取翻译服务密钥失败", slog.String("way", way), slog.Any("err", err))
+	}
 	switch way {
 	case youdao.Way:
 		t = &youdao.Youdao{
-			Translate: config.Translate{
-				Name:  config.Data.Translate[way].Name,
-				AppID: config.Data.Translate[way].AppID,
-				Key:   config.Data.Translate[way].Key,
-			},
+			Translate: provider,
 		}
 	case caiyun.Way:
 		t = &caiyun.Caiyun{
-			Translate: config.Translate{
-				Name:  config.Data.Translate[way].Name,
-				AppID: config.Data.Translate[way].AppID,
-				Key:   config.Data.Translate[way].Key,
-			},
+			Translate: provider,
 		}
 	case baidu.Way:
 		t = &baidu.Baidu{
-			Translate: config.Translate{
-				Name:  config.Data.Translate[way].Name,
-				AppID: config.Data.Translate[way].AppID,
-				Key:   config.Data.Translate[way].Key,
-			},
+			Translate: provider,
 		}
 	case deepseek.Way:
 		t = &deepseek.Deepseek{
-			Translate: config.Translate{
-				Name:  config.Data.Translate[way].Name,
-				AppID: config.Data.Translate[way].AppID,
-				Key:   config.Data.Translate[way].Key,
-			},
+			Translate: provider,
 		}
 	}
 
@@ -64,6 +54,9 @@ func GetTranslateWay(way string) Translate {
 
 var queryText string
 
+// queryApp 选中文本所在的应用（前台窗口标题）
+var queryApp string
+
 var lk sync.RWMutex
 
 // SetQueryText
@@ -79,3 +72,17 @@ func GetQueryText() string {
 	defer lk.RUnlock()
 	return queryText
 }
+
+// SetQueryApp 记录选中文本所在的应用，用于解释模板的 {{.app}}
+func SetQueryApp(value string) {
+	lk.Lock()
+	queryApp = value
+	lk.Unlock()
+}
+
+// GetQueryApp
+func GetQueryApp() string {
+	lk.RLock()
+	defer lk.RUnlock()
+	return queryApp
+}
@@ -1,10 +1,18 @@
 package translate_service
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
 	"handy-translate/config"
+	"handy-translate/explain"
+	"handy-translate/translate_service/apierr"
 	"handy-translate/translate_service/baidu"
 	"handy-translate/translate_service/youdao"
 
@@ -21,7 +29,10 @@ func TestGetTranslateWay(t *testing.T) {
 }
 
 func TestGetTranslateWayList(t *testing.T) {
-	config.Init("handy-translate")
+	t.Setenv(config.EnvConfigPath, filepath.Join(t.TempDir(), "config.toml"))
+	if err := config.Init("handy-translate", ""); err != nil {
+		t.Fatal(err)
+	}
 	v := GetTranslateWay(baidu.Way)
 	s, err := v.PostQuery("app", "auto", "zh")
 	if err != nil {
@@ -31,7 +42,10 @@ func TestGetTranslateWayList(t *testing.T) {
 }
 
 func TestTranslateYouDao(t *testing.T) {
-	config.Init("handy-translate")
+	t.Setenv(config.EnvConfigPath, filepath.Join(t.TempDir(), "config.toml"))
+	if err := config.Init("handy-translate", ""); err != nil {
+		t.Fatal(err)
+	}
 	v := GetTranslateWay(youdao.Way)
 	s, err := v.PostQuery("test", "auto", "zh")
 	if err != nil {
@@ -39,3 +53,130 @@ func TestTranslateYouDao(t *testing.T) {
 	}
 	fmt.Println(s)
 }
+
+func TestProbeWithoutNetwork(t *testing.T) {
+	if err := config.Init("handy-translate", filepath.Join(t.TempDir(), "config.toml")); err != nil {
+		t.Fatal(err)
+	}
+
+	// 默认配置中的密钥是占位值
+	_, err := Probe(context.Background(), baidu.Way)
+	var probeErr *ProbeError
+	if !errors.As(err, &probeErr) || probeErr.Kind != ProbeNotConfigured {
+		t.Errorf("占位值应返回 not_configured，实际 %v", err)
+	}
+
+	_, err = Probe(context.Background(), "unknown")
+	if !errors.As(err, &probeErr) || probeErr.Kind != ProbeUnsupported {
+		t.Errorf("不支持的翻译服务应返回 unsupported，实际 %v", err)
+	}
+
+	for err, kind := range map[error]string{
+		&apierr.Error{Provider: baidu.Way, Code: "52003", Auth: true}: ProbeAuth,
+		&apierr.Error{Provider: baidu.Way, Code: "54003"}:             ProbeRemote,
+		errors.New("status code: 401"):                                ProbeRemote,
+		&net.OpError{Op: "dial", Err: errors.New("refused")}:          ProbeNetwork,
+		context.DeadlineExceeded:                                      ProbeTimeout,
+	} {
+		if got := classifyProbeError(err); got.Kind != kind {
+			t.Errorf("%v 期望 %s，实际 %s", err, kind, got.Kind)
+		}
+	}
+}
+
+// upperTranslate 将文字转为大写的翻译服务，merge 为 true 时把多行译文合并为一行
+type upperTranslate struct {
+	merge   bool
+	queries []string
+}
+
+func (u *upperTranslate) GetName() string { return "upper" }
+
+func (u *upperTranslate) PostQuery(query, sourceLang, targetLang string) ([]string, error) {
+	u.queries = append(u.queries, query)
+	if u.merge {
+		return []string{strings.ToUpper(strings.ReplaceAll(query, "\n", " "))}, nil
+	}
+	return strings.Split(strings.ToUpper(query), "\n"), nil
+}
+
+func TestTranslateLines(t *testing.T) {
+	lines := []string{"hello", "wrapped\nline", "world"}
+
+	u := &upperTranslate{}
+	got, err := TranslateLines(u, lines, "en", "zh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"HELLO", "WRAPPED LINE", "WORLD"}; !reflect.DeepEqual(got, want) || len(u.queries) != 1 {
+		t.Errorf("整批翻译: %v，请求 %d 次", got, len(u.queries))
+	}
+
+	u = &upperTranslate{merge: true}
+	got, err = TranslateLines(u, lines, "en", "zh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"HELLO", "WRAPPED LINE", "WORLD"}; !reflect.DeepEqual(got, want) || len(u.queries) != 4 {
+		t.Errorf("译文行数不一致时应逐行翻译: %v，请求 %d 次", got, len(u.queries))
+	}
+}
+
+// streamOnlyTranslate 只实现了流式翻译的翻译服务，PostQuery 与 DeepSeek 一样返回空译文
+type streamOnlyTranslate struct {
+	empty bool
+}
+
+func (s *streamOnlyTranslate) GetName() string { return "stream" }
+
+func (s *streamOnlyTranslate) PostQuery(query, sourceLang, targetLang string) ([]string, error) {
+	return []string{"", ""}, nil
+}
+
+func (s *streamOnlyTranslate) PostQueryStream(query, sourceLang, targetLang string, callback func(chunk string)) error {
+	if s.empty {
+		return nil
+	}
+	for _, line := range strings.SplitAfter(strings.ToUpper(query), "\n") {
+		callback(line)
+	}
+	return nil
+}
+
+func (s *streamOnlyTranslate) PostExplainStream(vars explain.Vars, templateID string, callback func(chunk string)) error {
+	return nil
+}
+
+func TestTranslateLinesStream(t *testing.T) {
+	lines := []string{"hello", "world"}
+	got, err := TranslateLines(&streamOnlyTranslate{}, lines, "en", "zh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"HELLO", "WORLD"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("支持流式输出的翻译服务应使用流式接口: %v", got)
+	}
+
+	if _, err := TranslateLines(&streamOnlyTranslate{empty: true}, lines, "en", "zh"); !errors.Is(err, ErrEmptyResult) {
+		t.Errorf("所有译文都为空时应返回 ErrEmptyResult，实际 %v", err)
+	}
+}
+
+func TestBatches(t *testing.T) {
+	lines := make([]string, BatchLines+5)
+	for i := range lines {
+		lines[i] = "line"
+	}
+	if got := Batches(lines); len(got) != 2 || len(got[0]) != BatchLines || len(got[1]) != 5 {
+		t.Errorf("按行数分批错误: %d 批", len(got))
+	}
+
+	long := strings.Repeat("字", BatchChars)
+	got := Batches([]string{"a", long, "b"})
+	if len(got) != 3 || got[1][0] != long {
+		t.Errorf("超长的行应单独一批: %d 批", len(got))
+	}
+	if got := Batches(nil); got != nil {
+		t.Errorf("没有文字时不应分批: %v", got)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"handy-translate/config"
+	"handy-translate/translate_service/apierr"
 	"handy-translate/translate_service/youdao/utils"
 	"handy-translate/translate_service/youdao/utils/authv3"
 )
@@ -43,6 +44,16 @@ func (y *Youdao) PostQuery(query, fromLang, toLang string) ([]string, error) {
 	prettyResult, _ := json.MarshalIndent(string(result), "", "    ")
 	slog.Info("PostQuery", slog.String("prettyResult", string(prettyResult)))
 
+	// 0 表示成功，108 应用ID无效、202 签名检验失败
+	if tr.ErrorCode != "" && tr.ErrorCode != "0" {
+		return nil, &apierr.Error{
+			Provider: Way,
+			Code:     tr.ErrorCode,
+			Message:  "请参考有道智云错误码说明",
+			Auth:     tr.ErrorCode == "108" || tr.ErrorCode == "202",
+		}
+	}
+
 	if len(tr.Translation) == 0 {
 		return nil, nil
 	}
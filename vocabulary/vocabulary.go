@@ -0,0 +1,310 @@
+package vocabulary
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"handy-translate/config"
+	"handy-translate/history"
+
+	"github.com/google/uuid"
+)
+
+// ErrNotFound 单词不存在
+var ErrNotFound = errors.New("vocabulary: entry not found")
+
+// ErrEncryptedHistory 历史记录加密存储时不导入，单词本以明文保存，导入会泄露加密的历史记录
+var ErrEncryptedHistory = errors.New("vocabulary: history is encrypted, import would store it in plaintext")
+
+const (
+	defaultEaseFactor = 2.5 // SM-2 初始难度系数
+	minEaseFactor     = 1.3 // SM-2 难度系数下限
+	maxGrade          = 5   // 评分范围 0~5
+	passGrade         = 3   // 低于该分数视为遗忘，重新开始
+)
+
+// Entry 单词本条目，附带 SM-2 复习计划
+type Entry struct {
+	ID          string    `json:"id"`
+	Word        string    `json:"word"`
+	Translation string    `json:"translation"`
+	FromLang    string    `json:"from_lang"`
+	ToLang      string    `json:"to_lang"`
+	Source      string    `json:"source"` // "toolbar" 或 "history"
+	EaseFactor  float64   `json:"ease_factor"`
+	Interval    int       `json:"interval"` // 复习间隔（天）
+	Repetitions int       `json:"repetitions"`
+	DueAt       time.Time `json:"due_at"`
+	LastReview  time.Time `json:"last_review,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// VocabularyService 单词本服务，数据保存在 storage_path/vocabulary.json
+type VocabularyService struct {
+	mu          sync.Mutex
+	storagePath string
+	entries     []*Entry
+	loaded      bool
+}
+
+// NewVocabularyService 创建单词本服务实例，与历史记录共用存储目录
+func NewVocabularyService() *VocabularyService {
+	return &VocabularyService{
+		storagePath: config.ResolvePath(config.Get().History.StoragePath),
+	}
+}
+
+// SetStoragePath 切换存储目录，history.storage_path 修改后调用，下次访问时从新目录读取
+func (v *VocabularyService) SetStoragePath(storagePath string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if storagePath != v.storagePath {
+		v.storagePath, v.entries, v.loaded = storagePath, nil, false
+	}
+}
+
+// Add 收藏单词，已存在相同单词和语言对时只更新译文，返回条目的副本
+func (v *VocabularyService) Add(word, translation, fromLang, toLang, source string) (*Entry, error) {
+	word = strings.TrimSpace(word)
+	if word == "" {
+		return nil, errors.New("vocabulary: empty word")
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if err := v.load(); err != nil {
+		return nil, err
+	}
+
+	if entry := v.find(word, fromLang, toLang); entry != nil {
+		if translation != "" {
+			entry.Translation = translation
+		}
+		return copyEntry(entry), v.save()
+	}
+
+	now := time.Now()
+	entry := &Entry{
+		ID:          uuid.New().String(),
+		Word:        word,
+		Translation: translation,
+		FromLang:    fromLang,
+		ToLang:      toLang,
+		Source:      source,
+		EaseFactor:  defaultEaseFactor,
+		DueAt:       now,
+		CreatedAt:   now,
+	}
+	v.entries = append(v.entries, entry)
+
+	return copyEntry(entry), v.save()
+}
+
+// Remove 删除单词
+func (v *VocabularyService) Remove(id string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if err := v.load(); err != nil {
+		return err
+	}
+
+	for i, entry := range v.entries {
+		if entry.ID == id {
+			v.entries = append(v.entries[:i], v.entries[i+1:]...)
+			return v.save()
+		}
+	}
+	return ErrNotFound
+}
+
+// List 返回全部单词的副本，按收藏时间排序
+func (v *VocabularyService) List() ([]Entry, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if err := v.load(); err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, len(v.entries))
+	for i, entry := range v.entries {
+		entries[i] = *entry
+	}
+	return entries, nil
+}
+
+// Due 返回在 now 之前到期需要复习的单词的副本，最早到期的排在前面
+func (v *VocabularyService) Due(now time.Time) ([]Entry, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if err := v.load(); err != nil {
+		return nil, err
+	}
+
+	due := []Entry{}
+	for _, entry := range v.entries {
+		if !entry.DueAt.After(now) {
+			due = append(due, *entry)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].DueAt.Before(due[j].DueAt)
+	})
+	return due, nil
+}
+
+// Review 对单词进行一次复习评分（0~5），并按 SM-2 算法安排下一次复习，返回条目的副本
+func (v *VocabularyService) Review(id string, grade int, now time.Time) (*Entry, error) {
+	if grade < 0 || grade > maxGrade {
+		return nil, fmt.Errorf("vocabulary: grade %d out of range 0-%d", grade, maxGrade)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if err := v.load(); err != nil {
+		return nil, err
+	}
+
+	for _, entry := range v.entries {
+		if entry.ID == id {
+			schedule(entry, grade, now)
+			return copyEntry(entry), v.save()
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// ImportHistory 将翻译历史记录导入为新卡片，已存在的单词会被跳过，返回导入数量
+func (v *VocabularyService) ImportHistory(records []*history.HistoryRecord) (int, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if err := v.load(); err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	imported := 0
+	for _, record := range records {
+		if record.Type != "translate" {
+			continue
+		}
+		word := strings.TrimSpace(record.SourceText)
+		if word == "" || v.find(word, record.FromLang, record.ToLang) != nil {
+			continue
+		}
+
+		v.entries = append(v.entries, &Entry{
+			ID:          uuid.New().String(),
+			Word:        word,
+			Translation: record.Result,
+			FromLang:    record.FromLang,
+			ToLang:      record.ToLang,
+			Source:      "history",
+			EaseFactor:  defaultEaseFactor,
+			DueAt:       now,
+			CreatedAt:   now,
+		})
+		imported++
+	}
+
+	if imported == 0 {
+		return 0, nil
+	}
+	return imported, v.save()
+}
+
+// schedule SM-2 复习调度
+func schedule(entry *Entry, grade int, now time.Time) {
+	if grade < passGrade {
+		entry.Repetitions = 0
+		entry.Interval = 1
+	} else {
+		switch entry.Repetitions {
+		case 0:
+			entry.Interval = 1
+		case 1:
+			entry.Interval = 6
+		default:
+			entry.Interval = int(math.Round(float64(entry.Interval) * entry.EaseFactor))
+		}
+		entry.Repetitions++
+	}
+
+	q := float64(maxGrade - grade)
+	entry.EaseFactor += 0.1 - q*(0.08+q*0.02)
+	if entry.EaseFactor < minEaseFactor {
+		entry.EaseFactor = minEaseFactor
+	}
+
+	entry.LastReview = now
+	entry.DueAt = now.AddDate(0, 0, entry.Interval)
+}
+
+// copyEntry 复制条目，返回给调用方的条目在释放锁后使用，不能与 v.entries 共享
+func copyEntry(entry *Entry) *Entry {
+	c := *entry
+	return &c
+}
+
+func (v *VocabularyService) find(word, fromLang, toLang string) *Entry {
+	for _, entry := range v.entries {
+		if strings.EqualFold(entry.Word, word) && entry.FromLang == fromLang && entry.ToLang == toLang {
+			return entry
+		}
+	}
+	return nil
+}
+
+func (v *VocabularyService) filePath() string {
+	return path.Join(v.storagePath, "vocabulary.json")
+}
+
+// load 首次访问时从文件读取单词本
+func (v *VocabularyService) load() error {
+	if v.loaded {
+		return nil
+	}
+
+	data, err := os.ReadFile(v.filePath())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &v.entries); err != nil {
+			return fmt.Errorf("解析单词本文件失败: %w", err)
+		}
+	}
+
+	v.loaded = true
+	return nil
+}
+
+// save 将单词本写回文件
+func (v *VocabularyService) save() error {
+	if err := os.MkdirAll(v.storagePath, 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(v.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(v.filePath(), data, 0644)
+}
+
+// 全局单词本服务实例
+var GlobalVocabularyService *VocabularyService
@@ -0,0 +1,165 @@
+package vocabulary
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"handy-translate/history"
+)
+
+func TestAddAndRemove(t *testing.T) {
+	service := &VocabularyService{storagePath: "./test_data"}
+	defer os.RemoveAll("./test_data")
+
+	entry, err := service.Add("apple", "苹果", "en", "zh", "toolbar")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 重复收藏只更新译文
+	again, err := service.Add("Apple", "苹果公司", "en", "zh", "toolbar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != entry.ID || again.Translation != "苹果公司" {
+		t.Errorf("重复收藏应更新已有条目: %+v", again)
+	}
+
+	// 返回的是副本，修改不影响单词本
+	again.Translation = "修改"
+	if entries, _ := service.List(); entries[0].Translation != "苹果公司" {
+		t.Errorf("修改返回的条目不应影响单词本: %+v", entries[0])
+	}
+
+	// 重新加载后数据仍在
+	reloaded := &VocabularyService{storagePath: "./test_data"}
+	entries, err := reloaded.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("期望 1 个单词，实际 %d", len(entries))
+	}
+
+	if err := reloaded.Remove(entry.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloaded.Remove(entry.ID); err != ErrNotFound {
+		t.Errorf("删除不存在的单词应返回 ErrNotFound，实际 %v", err)
+	}
+
+	// 切换存储目录后从新目录读取
+	service.SetStoragePath(t.TempDir())
+	if entries, err := service.List(); err != nil || len(entries) != 0 {
+		t.Errorf("切换目录后应读取新目录: %+v %v", entries, err)
+	}
+}
+
+func TestReviewSchedule(t *testing.T) {
+	service := &VocabularyService{storagePath: "./test_data"}
+	defer os.RemoveAll("./test_data")
+
+	entry, err := service.Add("latency", "延迟", "en", "zh", "toolbar")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	intervals := []int{1, 6, 16}
+	for i, want := range intervals {
+		entry, err = service.Review(entry.ID, 5, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry.Interval != want {
+			t.Errorf("第 %d 次复习后间隔期望 %d 天，实际 %d", i+1, want, entry.Interval)
+		}
+	}
+
+	// 遗忘后重新开始
+	entry, err = service.Review(entry.ID, 1, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Repetitions != 0 || entry.Interval != 1 {
+		t.Errorf("遗忘后应重置: %+v", entry)
+	}
+	if entry.EaseFactor < minEaseFactor {
+		t.Errorf("难度系数不应低于 %v: %v", minEaseFactor, entry.EaseFactor)
+	}
+
+	due, err := service.Due(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(due) != 0 {
+		t.Errorf("刚复习的单词不应到期")
+	}
+	due, err = service.Due(now.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(due) != 1 {
+		t.Errorf("一天后单词应到期")
+	}
+
+	if _, err := service.Review(entry.ID, 6, now); err == nil {
+		t.Errorf("超出范围的评分应返回错误")
+	}
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	service := &VocabularyService{storagePath: "./test_data"}
+	defer os.RemoveAll("./test_data")
+
+	entry, err := service.Add("apple", "苹果", "en", "zh", "toolbar")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 并发复习和读取，配合 -race 检查返回的条目不与单词本共享
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if _, err := service.Review(entry.ID, 4, time.Now()); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			entries, err := service.List()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if _, err := json.Marshal(entries); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestImportHistory(t *testing.T) {
+	service := &VocabularyService{storagePath: "./test_data"}
+	defer os.RemoveAll("./test_data")
+
+	records := []*history.HistoryRecord{
+		{Type: "translate", SourceText: "hello", Result: "你好", FromLang: "en", ToLang: "zh"},
+		{Type: "translate", SourceText: "hello", Result: "你好", FromLang: "en", ToLang: "zh"},
+		{Type: "explain", SourceText: "CPU", Result: "中央处理器"},
+	}
+
+	n, err := service.ImportHistory(records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("期望导入 1 个单词，实际 %d", n)
+	}
+}
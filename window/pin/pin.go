@@ -0,0 +1,49 @@
+// Package pin 将截图钉在屏幕上：无边框、置顶的小窗口显示截图，可以拖动，按 Esc 关闭
+package pin
+
+import (
+	"fmt"
+	"image"
+	"log/slog"
+	"sync/atomic"
+
+	"github.com/wailsapp/wails/v3/pkg/application"
+)
+
+// pinHTML 钉图窗口的页面，整个窗口可以拖动
+const pinHTML = `<!DOCTYPE html>
+<html>
+<body style="margin:0;overflow:hidden;--wails-draggable:drag;cursor:move">
+<img src="data:image/png;base64,%s" style="display:block;width:100vw;height:100vh" draggable="false">
+</body>
+</html>`
+
+var count atomic.Int64 // 已创建的钉图窗口数，用于窗口名称
+
+// Show 创建钉图窗口，bounds 为截图在虚拟屏幕中的物理像素范围，窗口显示在截图原来的位置
+func Show(pngBase64 string, bounds image.Rectangle) {
+	app := application.Get()
+	dip := app.Screen.PhysicalToDipRect(application.Rect{X: bounds.Min.X, Y: bounds.Min.Y, Width: bounds.Dx(), Height: bounds.Dy()})
+
+	name := fmt.Sprintf("Pin-%d", count.Add(1))
+	w := app.Window.NewWithOptions(application.WebviewWindowOptions{
+		Name:            name,
+		Title:           name,
+		Width:           dip.Width,
+		Height:          dip.Height,
+		InitialPosition: application.WindowXY,
+		X:               dip.X,
+		Y:               dip.Y,
+		Frameless:       true,
+		AlwaysOnTop:     true,
+		DisableResize:   true,
+		HTML:            fmt.Sprintf(pinHTML, pngBase64),
+		KeyBindings: map[string]func(window application.Window){
+			"escape": func(window application.Window) {
+				window.Close()
+			},
+		},
+	})
+	w.Show()
+	slog.Info("截图已钉在屏幕上", slog.String("window", name), slog.Any("bounds", bounds))
+}
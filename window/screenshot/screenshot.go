@@ -3,18 +3,20 @@ package screenshot
 import (
 	"bytes"
 	"encoding/base64"
-	"fmt"
+	"errors"
 	"image"
 	"image/png"
 	"log/slog"
 
+	"handy-translate/capture"
+	"handy-translate/config"
+	"handy-translate/display"
+
 	"github.com/kbinani/screenshot"
 	"github.com/wailsapp/wails/v3/pkg/application"
 	"github.com/wailsapp/wails/v3/pkg/events"
 )
 
-var ScreenshotImg *image.RGBA
-
 var WindowName = "Screenshot"
 
 var Window *application.WebviewWindow
@@ -27,6 +29,8 @@ func NewWindow(app *application.App) {
 		Hidden:          true,
 		KeyBindings: map[string]func(window application.Window){
 			"escape": func(window application.Window) {
+				// 截图窗口关闭后不再引用这次截图
+				Sessions.Clear()
 				window.Hide()
 			},
 			"F12": func(window application.Window) {
@@ -35,6 +39,7 @@ func NewWindow(app *application.App) {
 				}
 			},
 		},
+		Frameless:      true,
 		BackgroundType: application.BackgroundTypeTransparent,
 		URL:            "http://wails.localhost/screenshot.html",
 	})
@@ -47,76 +52,72 @@ func NewWindow(app *application.App) {
 
 }
 
-func ScreenshotFullScreen() string {
-	bounds := screenshot.GetDisplayBounds(0)
-	img, err := screenshot.CaptureRect(bounds)
-
-	if err != nil {
-		// 错误处理，输出错误信息并返回
-		slog.Error("Error capturing screenshot:", err)
-		return ""
+// Sessions 截图会话，截图窗口中的操作通过会话 ID 引用同一张截图
+var Sessions = capture.NewStore(capture.DefaultTTL)
+
+// Displays 获取所有显示器的物理像素范围和缩放比例，无法从 Wails 获取时使用 screenshot 库的结果（缩放比例为 1）
+func Displays() display.Layout {
+	var layout display.Layout
+	if app := application.Get(); app != nil && app.Screen != nil {
+		for _, s := range app.Screen.GetAll() {
+			b := s.PhysicalBounds
+			layout = append(layout, display.Display{
+				Bounds:      image.Rect(b.X, b.Y, b.X+b.Width, b.Y+b.Height),
+				ScaleFactor: float64(s.ScaleFactor),
+			})
+		}
 	}
-	ScreenshotImg = img
-
-	base64Image := encodeImageToBase64(img)
-	if base64Image == "" {
-		// 错误处理，未能生成Base64图像，返回
-		slog.Error("Error encoding image to Base64")
-		return ""
+	if len(layout) > 0 {
+		return layout
 	}
-	return base64Image
-
+	for i := 0; i < screenshot.NumActiveDisplays(); i++ {
+		layout = append(layout, display.Display{Bounds: screenshot.GetDisplayBounds(i), ScaleFactor: 1})
+	}
+	return layout
 }
 
-// CaptureSelectedScreen 截图功能
-func CaptureSelectedScreen(startX, startY, endwidth, endheight int) image.Image {
-	slog.Info("CaptureSelectedScreen",
-		slog.Any("startX", startX),
-		slog.Any("startY", startY),
-		slog.Any("endwidth", endwidth),
-		slog.Any("endheight", endheight))
-
-	// x, y, width, height := startX, startY, endwidth, endheight
-	// 裁剪图片
-	/* 	croppedImg, err := screenshot.Capture(x, y, width, height)
-	   	if err != nil {
-	   		slog.Error("Capture", err)
-	   		return nil
-	   	} */
-
-	// 裁剪图片
-	rect := image.Rect(startX, startY, endwidth, endheight)
-	if ScreenshotImg == nil {
-		bounds := screenshot.GetDisplayBounds(0)
-		img, err := screenshot.CaptureRect(bounds)
-
-		if err != nil {
-			// 错误处理，输出错误信息并返回
-			fmt.Println("Error capturing screenshot:", err)
-			return nil
-		}
-		ScreenshotImg = img
+// CaptureScreen 按 screenshot.area 截图：鼠标所在的显示器，或所有显示器拼接为一张图，cursor 为鼠标的物理坐标
+func CaptureScreen(cursor image.Point) (*capture.Capture, error) {
+	layout := Displays()
+	if len(layout) == 0 {
+		return nil, errors.New("screenshot: no active display")
 	}
 
-	croppedImg := ScreenshotImg.SubImage(rect)
-
-	return croppedImg
+	area := config.Get().Screenshot.Area
+	if area == display.AreaAll && !layout.UniformScale() {
+		slog.Warn("各显示器的缩放比例不同，只截取鼠标所在的显示器", slog.Any("displays", layout))
+	}
+	t := layout.Region(area, cursor)
+	img, err := screenshot.CaptureRect(t.Bounds())
+	if err != nil {
+		return nil, err
+	}
+	return &capture.Capture{Image: img, Transform: t}, nil
 }
 
-// 将图像编码为Base64字符串
-func encodeImageToBase64(img image.Image) string {
-	// 创建一个缓冲区用于保存Base64编码的数据
-	var imgBytes []byte
-	buf := new(bytes.Buffer)
-	err := png.Encode(buf, img)
+// NewSession 截图并创建截图会话，将截图窗口移到截图范围上
+func NewSession(cursor image.Point) (*capture.Session, error) {
+	c, err := CaptureScreen(cursor)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
+	session := Sessions.Add(c)
 
-	imgBytes = buf.Bytes()
-
-	// 使用base64编码图像数据
-	base64Image := base64.StdEncoding.EncodeToString(imgBytes)
+	// 截图窗口覆盖截图范围，窗口中的 CSS 像素按显示器缩放比例对应截图的物理像素；
+	// 截图范围跨越多个显示器时这些显示器的缩放比例相同，按其中任一显示器换算都一致
+	if app := application.Get(); app != nil && app.Screen != nil && Window != nil {
+		b := c.Bounds()
+		Window.SetBounds(app.Screen.PhysicalToDipRect(application.Rect{X: b.Min.X, Y: b.Min.Y, Width: b.Dx(), Height: b.Dy()}))
+	}
+	slog.Info("截图会话已创建", slog.String("id", session.ID), slog.Any("bounds", c.Bounds()), slog.Float64("scale", c.Scale))
+	return session, nil
+}
 
-	return base64Image
+// EncodeBase64 将图片编码为 Base64 的 PNG
+func EncodeBase64(img image.Image) (string, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
 }